@@ -0,0 +1,91 @@
+package gpt
+
+import (
+	"io"
+	"sort"
+)
+
+// An AlignPolicy controls how RealignPartitions aligns partitions.
+type AlignPolicy struct {
+	// The alignment, in logical blocks, that partitions should start at.
+	// If zero, the alignment that Verify expects is used, which is 1MiB,
+	// or 4KiB for a table created by Apple's tools.
+	Align uint64
+
+	// If set, the misaligned partitions are found and checked for room
+	// to move them, but nothing is moved.
+	DryRun bool
+
+	// If set, each move is recorded in a journal at this path, as with
+	// MovePartition.
+	Journal string
+}
+
+// A Realignment is the result of realigning one misaligned partition.
+type Realignment struct {
+	// The index of the partition.
+	Index int
+
+	// The partition's starting LBA before and after it was realigned. To
+	// is the LBA it would be moved to if the policy was a dry run, and
+	// the same as From if it couldn't be realigned.
+	From, To uint64
+
+	// Why the partition couldn't be realigned, or nil if it was (or, for
+	// a dry run, could be).
+	Err error
+}
+
+// Finds the partitions of the table, which was read from dev, that don't
+// start at a multiple of the policy's alignment, and moves each one to the
+// nearest aligned LBA that it fits at. The aligned LBA before the partition's
+// current start is preferred, so that the partition doesn't grow into the
+// free space after it. The partitions' contents are copied with
+// MovePartition, so a partition may be moved over its own location, and the
+// table is written to dev after each move.
+//
+// Returns a Realignment for every misaligned partition, in the order of their
+// starting LBAs, including those which couldn't be moved because there isn't
+// enough free space around them. The error is only set if moving a partition
+// failed, in which case the realignments so far are returned.
+func (t *Table) RealignPartitions(dev io.ReadWriteSeeker, policy AlignPolicy) ([]Realignment, error) {
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+	align := policy.Align
+	if align == 0 {
+		align, _ = t.expectedAlignment()
+	}
+	var misaligned []int
+	for i, e := range t.Entries {
+		if !e.PartitionType.IsZero() && e.StartingLBA%align != 0 {
+			misaligned = append(misaligned, i)
+		}
+	}
+	sort.Slice(misaligned, func(i, j int) bool {
+		return t.Entries[misaligned[i]].StartingLBA < t.Entries[misaligned[j]].StartingLBA
+	})
+
+	var results []Realignment
+	for _, i := range misaligned {
+		start := t.Entries[i].StartingLBA
+		r := Realignment{Index: i, From: start, To: start}
+		before := start - start%align
+		after := before + align
+		if _, err := t.checkMove(i, before); err == nil {
+			r.To = before
+		} else if _, err := t.checkMove(i, after); err == nil {
+			r.To = after
+		} else {
+			r.Err = errorf(CodeNoFreeSpace, "No room to align partition %d to LBA %d or %d", i, before, after)
+		}
+		if r.Err == nil && !policy.DryRun {
+			if err := t.MovePartition(dev, i, r.To, policy.Journal); err != nil {
+				r.To, r.Err = start, err
+				return append(results, r), err
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,176 @@
+package gpt
+
+import (
+	"sort"
+	"strings"
+)
+
+// The location of the slot selection fields that Android bootloaders pack
+// into the GUID specific attribute bits of each slot's boot partition.
+const (
+	androidPriorityShift = 48
+	androidActiveBit     = 1 << 50
+	androidRetryShift    = 51
+	androidSuccessfulBit = 1 << 54
+	androidUnbootableBit = 1 << 55
+
+	// The maximum slot priority and retry count.
+	AndroidMaxPriority   = 3
+	AndroidMaxRetryCount = 7
+)
+
+// An AndroidSlot is a set of partitions which make up one of the A/B slots
+// used by Android for seamless updates.
+type AndroidSlot struct {
+	// The suffix of the partition names in this slot (ie. "_a".)
+	Suffix string
+
+	// The indexes of the partitions in this slot, keyed by their names
+	// without the suffix (ie. "boot", "system".)
+	Partitions map[string]int
+}
+
+// Splits an Android partition name into its base name and slot suffix. ok is
+// false if the name does not have a slot suffix.
+func AndroidSlotName(name string) (base, suffix string, ok bool) {
+	i := strings.LastIndexByte(name, '_')
+	if i <= 0 || i != len(name)-2 || name[i+1] < 'a' || name[i+1] > 'z' {
+		return name, "", false
+	}
+	return name[:i], name[i:], true
+}
+
+// Groups the partitions with slot suffixed names into slots, sorted by suffix.
+// Partitions without a slot suffix are not included in any slot.
+func (t *Table) AndroidSlots() []AndroidSlot {
+	slots := make(map[string]AndroidSlot)
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		base, suffix, ok := AndroidSlotName(e.GetName())
+		if !ok {
+			continue
+		}
+		s, ok := slots[suffix]
+		if !ok {
+			s = AndroidSlot{suffix, make(map[string]int)}
+			slots[suffix] = s
+		}
+		s.Partitions[base] = i
+	}
+
+	var sorted []AndroidSlot
+	for _, s := range slots {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Suffix < sorted[j].Suffix })
+	return sorted
+}
+
+// Returns the slot priority. The bootloader boots the bootable slot with the
+// highest priority.
+func (a GPTPartitionAttribute) AndroidPriority() int {
+	return int(a>>androidPriorityShift) & 0x3
+}
+
+// Returns true if the slot is marked as the active slot.
+func (a GPTPartitionAttribute) AndroidActive() bool {
+	return a&androidActiveBit != 0
+}
+
+// Returns the number of boot attempts remaining for a slot which has not yet
+// booted successfully.
+func (a GPTPartitionAttribute) AndroidRetryCount() int {
+	return int(a>>androidRetryShift) & 0x7
+}
+
+// Returns true if the slot has booted successfully.
+func (a GPTPartitionAttribute) AndroidSuccessful() bool {
+	return a&androidSuccessfulBit != 0
+}
+
+// Returns true if the slot has been marked as unbootable.
+func (a GPTPartitionAttribute) AndroidUnbootable() bool {
+	return a&androidUnbootableBit != 0
+}
+
+// Sets the slot priority, which must be between 0 and AndroidMaxPriority.
+func (a *GPTPartitionAttribute) SetAndroidPriority(priority int) error {
+	if priority < 0 || priority > AndroidMaxPriority {
+		return errorf(CodeInvalidArgument, "Invalid Android slot priority %d", priority)
+	}
+	*a = *a&^(0x3<<androidPriorityShift) | GPTPartitionAttribute(priority)<<androidPriorityShift
+	return nil
+}
+
+// Sets the remaining boot attempts, which must be between 0 and
+// AndroidMaxRetryCount.
+func (a *GPTPartitionAttribute) SetAndroidRetryCount(count int) error {
+	if count < 0 || count > AndroidMaxRetryCount {
+		return errorf(CodeInvalidArgument, "Invalid Android retry count %d", count)
+	}
+	*a = *a&^(0x7<<androidRetryShift) | GPTPartitionAttribute(count)<<androidRetryShift
+	return nil
+}
+
+// Sets whether the slot is the active slot.
+func (a *GPTPartitionAttribute) SetAndroidActive(active bool) {
+	a.setBit(androidActiveBit, active)
+}
+
+// Sets whether the slot has booted successfully.
+func (a *GPTPartitionAttribute) SetAndroidSuccessful(successful bool) {
+	a.setBit(androidSuccessfulBit, successful)
+}
+
+// Sets whether the slot is unbootable.
+func (a *GPTPartitionAttribute) SetAndroidUnbootable(unbootable bool) {
+	a.setBit(androidUnbootableBit, unbootable)
+}
+
+// Makes the slot with the given suffix the active slot, as an update engine
+// does after writing a new system to it. The slot selection attributes are
+// stored on the boot partition of each slot.
+//
+// The new slot is given the maximum priority and retry count, and is marked
+// as bootable but not yet successful. The other slots are marked inactive,
+// and those with a non-zero priority have their priority lowered to 1 so that
+// the bootloader falls back to them if the new slot fails.
+func (t *Table) AndroidSetActiveSlot(suffix string) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	// The slot is looked up first, so that the table is left unchanged
+	// if it doesn't exist.
+	slots := t.AndroidSlots()
+	found := false
+	for _, s := range slots {
+		if _, ok := s.Partitions["boot"]; ok && s.Suffix == suffix {
+			found = true
+		}
+	}
+	if !found {
+		return errorf(CodeNotInUse, "No boot partition for slot \"%v\"", suffix)
+	}
+	for _, s := range slots {
+		i, ok := s.Partitions["boot"]
+		if !ok {
+			continue
+		}
+		a := &t.Entries[i].Attributes
+		if s.Suffix != suffix {
+			a.SetAndroidActive(false)
+			if a.AndroidPriority() > 0 {
+				a.SetAndroidPriority(1)
+			}
+			continue
+		}
+		a.SetAndroidActive(true)
+		a.SetAndroidPriority(AndroidMaxPriority)
+		a.SetAndroidRetryCount(AndroidMaxRetryCount)
+		a.SetAndroidSuccessful(false)
+		a.SetAndroidUnbootable(false)
+	}
+	return nil
+}
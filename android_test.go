@@ -0,0 +1,46 @@
+package gpt_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/driusan/gpt"
+)
+
+func TestAndroidSetActiveSlot(t *testing.T) {
+	table, err := gpt.CreateTable(64<<20, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"boot_a", "boot_b"} {
+		i, err := table.AddPartition(gpt.LinuxFilesystem, 1<<20, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.Entries[i].SetName(name); err != nil {
+			t.Fatal(err)
+		}
+		table.Entries[i].Attributes.SetAndroidPriority(2)
+	}
+	if err := table.AndroidSetActiveSlot("_a"); err != nil {
+		t.Fatal(err)
+	}
+	before := slices.Clone(table.Entries)
+	if err := table.AndroidSetActiveSlot("_c"); gpt.CodeOf(err) != gpt.CodeNotInUse {
+		t.Errorf("AndroidSetActiveSlot of a missing slot: %v", err)
+	}
+	if !slices.Equal(table.Entries, before) {
+		t.Error("AndroidSetActiveSlot of a missing slot changed the other slots")
+	}
+
+	if err := table.AndroidSetActiveSlot("_b"); err != nil {
+		t.Fatal(err)
+	}
+	a, b := table.Entries[0].Attributes, table.Entries[1].Attributes
+	if a.AndroidActive() || a.AndroidPriority() != 1 {
+		t.Errorf("slot _a is active %v with priority %d", a.AndroidActive(), a.AndroidPriority())
+	}
+	if !b.AndroidActive() || b.AndroidPriority() != gpt.AndroidMaxPriority {
+		t.Errorf("slot _b is active %v with priority %d", b.AndroidActive(), b.AndroidPriority())
+	}
+}
@@ -0,0 +1,68 @@
+package gpt
+
+// Apple partition types.
+var (
+	AppleHFS             = registerType("48465300-0000-11AA-AA11-00306543ECAC", "Apple HFS/HFS+", 0xAF00, OSMacOS, CategoryData)
+	AppleUFS             = registerType("55465300-0000-11AA-AA11-00306543ECAC", "Apple UFS", 0xA800, OSMacOS, CategoryData)
+	AppleRAID            = registerType("52414944-0000-11AA-AA11-00306543ECAC", "Apple RAID", 0xAF01, OSMacOS, CategoryRAID)
+	AppleRAIDOffline     = registerType("52414944-5F4F-11AA-AA11-00306543ECAC", "Apple RAID offline", 0xAF02, OSMacOS, CategoryRAID)
+	AppleLabel           = registerType("4C616265-6C00-11AA-AA11-00306543ECAC", "Apple label", 0xAF03, OSMacOS, CategoryMetadata)
+	AppleTVRecovery      = registerType("5265636F-7665-11AA-AA11-00306543ECAC", "AppleTV recovery", 0xAF04, OSMacOS, CategoryRecovery)
+	AppleCoreStorage     = registerType("53746F72-6167-11AA-AA11-00306543ECAC", "Apple Core Storage", 0xAF05, OSMacOS, CategoryVolumeManager)
+	AppleSoftRAIDStatus  = registerType("B6FA30DA-92D2-4A9A-96F1-871EC6486200", "Apple SoftRAID Status", 0xAF06, OSMacOS, CategoryRAID)
+	AppleSoftRAIDScratch = registerType("2E313465-19B9-463F-8126-8A7993773801", "Apple SoftRAID Scratch", 0xAF07, OSMacOS, CategoryRAID)
+	AppleSoftRAIDVolume  = registerType("FA709C7E-65B1-4593-BFD5-E71D61DE9B02", "Apple SoftRAID Volume", 0xAF08, OSMacOS, CategoryRAID)
+	AppleSoftRAIDCache   = registerType("BBBA6DF5-F46F-4A89-8F59-8765B2727503", "Apple SoftRAID Cache", 0xAF09, OSMacOS, CategoryRAID)
+	AppleAPFS            = registerType("7C3457EF-0000-11AA-AA11-00306543ECAC", "Apple APFS", 0xAF0A, OSMacOS, CategoryData)
+	AppleAPFSPreboot     = registerType("69646961-6700-11AA-AA11-00306543ECAC", "Apple APFS Pre-Boot", 0xAF0B, OSMacOS, CategoryBoot)
+	AppleAPFSRecovery    = registerType("52637672-7900-11AA-AA11-00306543ECAC", "Apple APFS Recovery", 0xAF0C, OSMacOS, CategoryRecovery)
+
+	// The Apple Boot partition, which Disk Utility shows as "Recovery
+	// HD".
+	AppleBoot = registerType("426F6F74-0000-11AA-AA11-00306543ECAC", "Recovery HD", 0xAB00, OSMacOS, CategoryRecovery)
+
+	// Apple's ZFS uses the same type GUID as the Solaris /usr partition.
+	AppleZFS = SolarisUsr
+)
+
+// The partition types which are only used by Apple's operating systems.
+var appleTypes = map[GUID]bool{
+	AppleHFS: true, AppleUFS: true, AppleRAID: true, AppleRAIDOffline: true,
+	AppleLabel: true, AppleTVRecovery: true, AppleCoreStorage: true,
+	AppleAPFS: true, AppleAPFSPreboot: true, AppleAPFSRecovery: true,
+	AppleBoot: true,
+}
+
+// Apple's partitioning conventions, from Technical Note TN2166.
+const (
+	// Apple's tools align partitions to 4KiB rather than 1MiB. The EFI
+	// System Partition they create starts at LBA 40.
+	AppleAlignment uint64 = 4096
+
+	// Apple's tools leave 128MiB of free space after each partition, so
+	// that a partition can be converted or grown (ie. to add a Recovery
+	// HD) without moving the partitions after it.
+	AppleGap uint64 = 128 << 20
+)
+
+// Returns true if the table has any partitions of Apple specific types, in
+// which case it was probably created by Apple's tools and follows their
+// conventions.
+func (t *Table) IsApple() bool {
+	for _, e := range t.Entries {
+		if appleTypes[e.PartitionType] {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds a partition of type typ which is at least size bytes long, following
+// Apple's conventions: it's aligned to AppleAlignment, and there's at least
+// AppleGap bytes of free space between it and the partitions around it.
+//
+// Returns the index of the new entry in t.Entries.
+func (t *Table) AddApplePartition(typ GUID, size uint64) (int, error) {
+	bs := t.BlockSize()
+	return t.addPartition(typ, alignUp(size, AppleAlignment), max(AppleAlignment/bs, 1), AppleGap/bs)
+}
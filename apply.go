@@ -0,0 +1,257 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A LayoutChange is a change to a single partition entry which is needed to
+// converge a table to a Layout.
+type LayoutChange struct {
+	// The index of the partition entry.
+	Index int
+
+	// The entry before and after the change. Old is unused for a new
+	// partition, and New is unused for a deleted partition.
+	Old, New GPTPartitionEntry
+}
+
+// Returns true if the change deletes or shrinks a partition, which destroys
+// (some of) its contents.
+func (c LayoutChange) Destructive() bool {
+	if c.Old.PartitionType.IsZero() {
+		return false
+	}
+	return c.New.PartitionType.IsZero() || c.New.EndingLBA < c.Old.EndingLBA
+}
+
+// Returns a line describing the change.
+func (c LayoutChange) String() string {
+	switch {
+	case c.Old.PartitionType.IsZero():
+		return fmt.Sprintf("create partition %d: %s", c.Index, describeEntry(c.New))
+	case c.New.PartitionType.IsZero():
+		return fmt.Sprintf("delete partition %d: %s", c.Index, describeEntry(c.Old))
+	}
+	var fields []string
+	for _, d := range diffEntries(c.Index, c.Old, c.New) {
+		fields = append(fields, fmt.Sprintf("%s %s -> %s", d.Field, d.Old, d.New))
+	}
+	return fmt.Sprintf("change partition %d: %s", c.Index, strings.Join(fields, ", "))
+}
+
+// Returns the changes which would converge the table to the layout l,
+// without modifying the table. See ApplyLayout.
+func (t *Table) PlanLayout(l Layout, align uint64) ([]LayoutChange, error) {
+	changes, _, err := t.planLayout(l, align)
+	return changes, err
+}
+
+// Converges the table to the layout l, returning the changes that were made.
+//
+// Each partition in the layout is matched to an existing partition with the
+// same unique GUID if the spec has one, or otherwise to the first unmatched
+// partition with the same type and name, or failing that the same type.
+// Matched partitions are given the spec's type, attributes and name (if the
+// spec has one), grown in place to the spec's MinSize or shrunk to its
+// MaxSize. Partitions which aren't matched are deleted. New partitions are
+// then created for the remaining specs in the order of the layout, each
+// starting at a multiple of align logical blocks. A new partition takes up as
+// much of the free space following it as it can, up to its MaxSize, while
+// leaving room for the MinSize of the new partitions after it.
+//
+// If the table already matches the layout, no changes are returned. If
+// deleting or shrinking a partition is required and prune isn't set,
+// ErrDestructive is returned along with the changes, and the table isn't
+// modified.
+func (t *Table) ApplyLayout(l Layout, align uint64, prune bool) ([]LayoutChange, error) {
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+	changes, entries, err := t.planLayout(l, align)
+	if err != nil {
+		return nil, err
+	}
+	planned := *t
+	planned.Entries = entries
+	if t.Equal(&planned) {
+		return nil, nil
+	}
+	if !prune {
+		for _, c := range changes {
+			if c.Destructive() {
+				return changes, ErrDestructive
+			}
+		}
+	}
+	t.Entries = entries
+	return changes, nil
+}
+
+// Returns the changes needed to converge the table to l, and the partition
+// entries after making them.
+func (t *Table) planLayout(l Layout, align uint64) ([]LayoutChange, []GPTPartitionEntry, error) {
+	if align == 0 {
+		align = 1
+	}
+	entries := append([]GPTPartitionEntry(nil), t.Entries...)
+	specs := l.Partitions
+	matched := make([]int, len(specs))
+	taken := make(map[int]bool)
+	match := func(want func(s PartitionSpec, e GPTPartitionEntry) bool) {
+		for si, s := range specs {
+			if matched[si] >= 0 {
+				continue
+			}
+			for i, e := range entries {
+				if !e.PartitionType.IsZero() && !taken[i] && want(s, e) {
+					matched[si] = i
+					taken[i] = true
+					break
+				}
+			}
+		}
+	}
+	for si := range matched {
+		matched[si] = -1
+	}
+	match(func(s PartitionSpec, e GPTPartitionEntry) bool {
+		return !s.UUID.IsZero() && s.UUID == e.UniqueParitition
+	})
+	match(func(s PartitionSpec, e GPTPartitionEntry) bool {
+		return s.UUID.IsZero() && s.Type == e.PartitionType && s.Name == e.GetName()
+	})
+	match(func(s PartitionSpec, e GPTPartitionEntry) bool {
+		return s.UUID.IsZero() && s.Type == e.PartitionType
+	})
+
+	for i, e := range entries {
+		if !e.PartitionType.IsZero() && !taken[i] {
+			entries[i] = GPTPartitionEntry{}
+		}
+	}
+
+	tmp := &Table{Primary: t.Primary, Entries: entries, blockSize: t.blockSize}
+	bs := t.BlockSize()
+	for si, s := range specs {
+		i := matched[si]
+		if i < 0 {
+			continue
+		}
+		e := &entries[i]
+		e.PartitionType = s.Type
+		e.Attributes = s.Attributes
+		if s.Name != "" {
+			if err := e.SetName(s.Name); err != nil {
+				return nil, nil, err
+			}
+		}
+		size := e.SizeBytes(bs)
+		switch {
+		case size < s.MinSize:
+			end := e.StartingLBA + BytesToLBAs(s.MinSize, bs) - 1
+			if end > tmp.freeAfter(e.EndingLBA) {
+				return nil, nil, errorf(CodeNoFreeSpace, "Partition %d can not be grown to %d bytes, there isn't enough free space after it", i, s.MinSize)
+			}
+			e.EndingLBA = end
+		case s.MaxSize != 0 && size > s.MaxSize:
+			blocks := s.MaxSize / bs
+			if blocks == 0 {
+				return nil, nil, errorf(CodeDestructive, "Partition %d can not be shrunk to %d bytes", i, s.MaxSize)
+			}
+			e.EndingLBA = e.StartingLBA + blocks - 1
+		}
+	}
+
+	// The blocks to leave free for each new partition after the one being
+	// created.
+	var reserve uint64
+	for si, s := range specs {
+		if matched[si] < 0 {
+			reserve += alignUp(max(BytesToLBAs(s.MinSize, bs), 1), align)
+		}
+	}
+	for si, s := range specs {
+		if matched[si] >= 0 {
+			continue
+		}
+		if s.Type.IsZero() {
+			return nil, nil, errorf(CodeUnknownType, "Can not add a partition with the unused partition type.")
+		}
+		// Prefer entries which weren't used before, so that a deleted
+		// partition's entry isn't reused.
+		idx := -1
+		for i, e := range entries {
+			if !e.PartitionType.IsZero() {
+				continue
+			}
+			if idx < 0 {
+				idx = i
+			}
+			if t.Entries[i].PartitionType.IsZero() {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, nil, errorf(CodeNoFreeEntries, "No unused partition entries.")
+		}
+		blocks := max(BytesToLBAs(s.MinSize, bs), 1)
+		reserve -= alignUp(blocks, align)
+		start, ok := tmp.findFree(blocks, align, 0)
+		if !ok {
+			return nil, nil, errorf(CodeNoFreeSpace, "No free space for a partition of %d blocks.", blocks)
+		}
+		end := start + blocks - 1
+		if limit := tmp.freeAfter(start); limit > end+reserve {
+			want := limit - reserve
+			if s.MaxSize != 0 {
+				want = min(want, start+s.MaxSize/bs-1)
+			}
+			end = max(end, want)
+		}
+
+		guid := s.UUID
+		if guid.IsZero() {
+			var err error
+			if guid, err = NewGUID(); err != nil {
+				return nil, nil, err
+			}
+		}
+		entries[idx] = GPTPartitionEntry{
+			PartitionType:    s.Type,
+			UniqueParitition: guid,
+			StartingLBA:      start,
+			EndingLBA:        end,
+			Attributes:       s.Attributes,
+		}
+		if err := entries[idx].SetName(s.Name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var changes []LayoutChange
+	for i := range entries {
+		old, new := t.Entries[i], entries[i]
+		switch {
+		case old == new:
+		case !old.PartitionType.IsZero() && !new.PartitionType.IsZero() && old.UniqueParitition != new.UniqueParitition:
+			changes = append(changes, LayoutChange{i, old, GPTPartitionEntry{}}, LayoutChange{i, GPTPartitionEntry{}, new})
+		default:
+			changes = append(changes, LayoutChange{i, old, new})
+		}
+	}
+	return changes, entries, nil
+}
+
+// Returns the last LBA which a partition containing lba could be grown to,
+// which is the block before the next partition, or the last usable LBA.
+func (t *Table) freeAfter(lba uint64) uint64 {
+	end := t.Primary.LastUseableLBA
+	for _, e := range t.Entries {
+		if !e.PartitionType.IsZero() && e.StartingLBA > lba && e.StartingLBA-1 < end {
+			end = e.StartingLBA - 1
+		}
+	}
+	return end
+}
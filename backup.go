@@ -0,0 +1,180 @@
+package gpt
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+)
+
+// A Backup is a copy of a disk's partition table, in the same format as the
+// file written by "sgdisk --backup" and read by "sgdisk --load-backup". The
+// file consists of the MBR, the primary header, the backup header and the
+// partition entry array, in that order, each starting on a 512 byte block
+// whatever the logical block size of the disk.
+//
+// BUG(driusan): The backup format doesn't record the logical block size of the
+// disk, so ReadBackup assumes 512 byte blocks, and a backup of a disk with 4096
+// byte blocks can only be restored from the Backup returned by NewBackup.
+type Backup struct {
+	// The contents of the first logical block of the disk, which is
+	// normally a protective MBR.
+	MBR LogicalBlock
+
+	// The partition table.
+	Table *Table
+
+	// If set, the partition entry array is written with exactly as many
+	// bytes as its entries take up, like sgdisk, rather than padded to a
+	// whole number of logical blocks. The two only differ when the array
+	// doesn't fill its last block, and both are read by ReadBackup and
+	// sgdisk. It's set by ReadBackup if the backup wasn't padded, so that a
+	// backup is written back byte for byte the same as it was read.
+	SgdiskCompat bool
+}
+
+// Reads the partition table and MBR from dev to create a backup. If the first
+// logical block of dev doesn't have an MBR boot signature, a protective MBR
+// is created for the backup instead, since sgdisk requires one.
+func NewBackup(dev io.ReadSeeker) (*Backup, error) {
+	t, err := ReadTable(dev)
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{Table: t, MBR: t.MBR.encode()}
+	if !t.MBR.Valid() {
+		b.MBR = protectiveMBR(t.Primary.AltLBA)
+	}
+	return b, nil
+}
+
+// Reads a backup written by WriteTo or by "sgdisk --backup". The checksums
+// of both headers and the partition entry array are verified.
+func ReadBackup(r io.Reader) (*Backup, error) {
+	var blocks [3 * LogicalBlockSize]byte
+	if _, err := io.ReadFull(r, blocks[:]); err != nil {
+		return nil, wrapf(CodeIO, err, "Could not read backup headers: %w", err)
+	}
+	b := &Backup{Table: &Table{}}
+	copy(b.MBR[:], blocks[:LogicalBlockSize])
+	t := b.Table
+	for i, h := range []*GPTHeader{&t.Primary, &t.Backup} {
+		block := blocks[(i+1)*int(LogicalBlockSize):]
+		*h = decodeHeader(block[:LogicalBlockSize])
+		if string(h.Signature[:]) != "EFI PART" {
+			return nil, errorf(CodeInvalidHeader, "Invalid GPT Header \"%v\" in backup", string(h.Signature[:]))
+		}
+		if !validHeaderSize(*h, LogicalBlockSize) || h.computeCRC() != h.HeaderCRC32 {
+			return nil, errorf(CodeHeaderCRC, "Invalid header CRC in backup block %d", i+1)
+		}
+	}
+	if err := t.Primary.Verify(); err != nil {
+		return nil, err
+	}
+	if t.Primary.SizeOfPartitionEntry < 128 {
+		return nil, errorf(CodeInvalidHeader, "Invalid partition entry size %d.", t.Primary.SizeOfPartitionEntry)
+	}
+
+	array, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	size := t.Primary.entryArraySize()
+	if uint64(len(array)) < size {
+		return nil, errorf(CodeEntryArraySize, "Backup is truncated, partition entry array is %d bytes instead of %d", len(array), size)
+	}
+	if crc32.ChecksumIEEE(array[:size]) != t.Primary.PartitionEntryArrayCRC32 {
+		return nil, errorf(CodeArrayCRC, "Invalid partition entry array CRC in backup")
+	}
+	padded := make([]byte, t.Primary.entryArrayBlocks(t.BlockSize())*t.BlockSize())
+	copy(padded, array)
+	b.SgdiskCompat = len(array) < len(padded)
+
+	// Read the entries as if the array started at the first block of
+	// padded.
+	h := t.Primary
+	h.PartitionEntryLBA = 0
+	if t.Entries, err = h.GetPartitions(bytes.NewReader(padded)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Writes the backup to w. The checksums are recomputed, and the backup
+// header is synced with the primary, as they are when writing the table to a
+// disk, but b.Table isn't modified.
+func (b *Backup) WriteTo(w io.Writer) (int64, error) {
+	t := *b.Table
+	t.syncBackup()
+	entries, err := t.encodeEntries()
+	if err != nil {
+		return 0, err
+	}
+	t.updateChecksums(entries)
+	size := t.Primary.entryArraySize()
+	if b.SgdiskCompat {
+		entries = entries[:size]
+	} else {
+		entries = entries[:BytesToLBAs(size, LogicalBlockSize)*LogicalBlockSize]
+	}
+
+	// The headers' padding past the first 512 bytes is zero, so it can be
+	// left out for disks with larger blocks.
+	var written int64
+	for _, data := range [][]byte{b.MBR[:], t.Primary.encode()[:LogicalBlockSize], t.Backup.encode()[:LogicalBlockSize], entries} {
+		n, err := w.Write(data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Restores the backup to dev, writing both the MBR and the table. If the MBR
+// has no boot signature (ie. it's unset), a protective MBR is written. If dev
+// is not the same size as the disk the backup was made from, the backup
+// header is moved to the end of dev and the usable area is resized to match,
+// as long as all of the partitions still fit. It's an error if dev is a block
+// device with a different logical block size than the backup's table.
+func (b *Backup) Restore(dev io.ReadWriteSeeker) error {
+	size, err := deviceSize(dev)
+	if err != nil {
+		return err
+	}
+	t := *b.Table
+	bs := t.BlockSize()
+	if dbs, ok := deviceBlockSize(dev); ok && dbs != bs {
+		return errorf(CodeBlockSize, "Backup is for %d byte logical blocks, the device has %d byte blocks", bs, dbs)
+	}
+	mbr := b.MBR
+	arrayBlocks := t.Primary.entryArrayBlocks(bs)
+	lastLBA := size/bs - 1
+	if size/bs < t.Primary.FirstUseableLBA+arrayBlocks+2 {
+		return errorf(CodeDeviceTooSmall, "Device is too small for the partition table (%d blocks)", size/bs)
+	}
+	if lastLBA != t.Primary.AltLBA {
+		lastUseable := lastLBA - arrayBlocks - 1
+		for i, e := range t.Entries {
+			if !e.PartitionType.IsZero() && e.EndingLBA > lastUseable {
+				return errorf(CodeDeviceTooSmall, "Partition %d (LBA %d-%d) does not fit on the device (last usable LBA %d)", i, e.StartingLBA, e.EndingLBA, lastUseable)
+			}
+		}
+		t.Primary.AltLBA = lastLBA
+		t.Primary.LastUseableLBA = lastUseable
+		if decodeMBR(mbr).IsProtective() {
+			mbr = protectiveMBR(lastLBA)
+		}
+	}
+	if !decodeMBR(mbr).Valid() {
+		mbr = protectiveMBR(lastLBA)
+	}
+
+	p, err := t.writePlan("restore backup")
+	if err != nil {
+		return err
+	}
+	block := make([]byte, bs)
+	copy(block, mbr[:])
+	p.Writes = append([]PlannedWrite{{0, block, "MBR"}}, p.Writes...)
+	return execute(dev, t.logger(), p, SyncNever)
+}
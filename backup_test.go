@@ -0,0 +1,66 @@
+package gpt_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+)
+
+// A backup in the layout that "sgdisk --backup" writes, of a 32MiB disk with
+// 129 partition entries, an ESP and a Linux root partition. The entry array
+// is 16512 bytes, which doesn't fill its last 512 byte block, and sgdisk
+// doesn't pad it.
+const sgdiskBackup = "testdata/sgdisk-129-entries.backup"
+
+func TestSgdiskBackupRoundTrip(t *testing.T) {
+	orig, err := os.ReadFile(sgdiskBackup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := gpt.ReadBackup(bytes.NewReader(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.SgdiskCompat {
+		t.Error("SgdiskCompat isn't set for an unpadded entry array")
+	}
+	var used []gpt.GPTPartitionEntry
+	for _, e := range b.Table.Used() {
+		used = append(used, e)
+	}
+	if len(b.Table.Entries) != 129 || len(used) != 2 {
+		t.Fatalf("read %d entries, %d used", len(b.Table.Entries), len(used))
+	}
+	if e := used[0]; e.PartitionType != gpt.EFISystemPartition || e.StartingLBA != 2048 || e.EndingLBA != 10239 || e.GetName() != "EFI system partition" {
+		t.Errorf("partition 0 is %v", e)
+	}
+	if e := used[1]; e.PartitionType != gpt.LinuxFilesystem || e.StartingLBA != 10240 || e.EndingLBA != 65501 || e.GetName() != "root" {
+		t.Errorf("partition 1 is %v", e)
+	}
+
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(orig)) || !bytes.Equal(buf.Bytes(), orig) {
+		t.Errorf("WriteTo wrote %d bytes which differ from the %d bytes read", n, len(orig))
+	}
+
+	// Restoring the backup to a blank disk of the same size gives the
+	// disk it was made from.
+	img := gpttest.NewImage(make([]byte, 65536*512))
+	if err := b.Restore(img); err != nil {
+		t.Fatal(err)
+	}
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.Equal(b.Table) {
+		t.Error("restored table isn't the table in the backup")
+	}
+}
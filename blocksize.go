@@ -0,0 +1,104 @@
+package gpt
+
+import (
+	"io"
+)
+
+// The logical block sizes that DetectBlockSize looks for a GPT with, in the
+// order they're tried. 2048 is the block size of optical discs.
+var probedBlockSizes = []uint64{512, 4096, 2048}
+
+// Returns the logical block size that the GPT on hd was written for. If hd
+// is a block device whose logical block size can be queried, that size is
+// tried first. Otherwise (ie. for disk images, which don't record the block
+// size of the disk they were taken from) it's found by looking for a valid
+// primary header at LBA 1 for each of 512, 4096 and 2048 byte blocks. A disk
+// imaged from a 4096 byte sector (4Kn) disk has its GPT at byte 4096 rather
+// than 512.
+//
+// If there's a valid header for more than one block size, the one whose
+// backup header is at the end of hd is preferred, since it's the one that's
+// consistent with the size of the disk. Returns an error if there's no valid
+// primary header for any of the block sizes.
+func DetectBlockSize(hd io.ReadSeeker) (uint64, error) {
+	sizes := probedBlockSizes
+	if bs, ok := deviceBlockSize(hd); ok {
+		sizes = append([]uint64{bs}, sizes...)
+	}
+	end, err := deviceSize(hd)
+	if err != nil {
+		return 0, err
+	}
+	found := uint64(0)
+	for _, bs := range sizes {
+		h, ok := headerAt(hd, bs)
+		if !ok {
+			continue
+		}
+		if (h.AltLBA+1)*bs == end {
+			return bs, nil
+		}
+		if found == 0 {
+			found = bs
+		}
+	}
+	if found == 0 {
+		return 0, errorf(CodeBlockSize, "No GPT header found for %v byte logical blocks", probedBlockSizes)
+	}
+	return found, nil
+}
+
+// Returns the header at byte offset off of hd, and whether it's a valid
+// primary header, with a correct checksum and a MyLBA of 1.
+func headerAt(hd io.ReadSeeker, off uint64) (GPTHeader, bool) {
+	var h GPTHeader
+	if _, err := hd.Seek(int64(off), io.SeekStart); err != nil {
+		return h, false
+	}
+	var block LogicalBlock
+	if _, err := io.ReadFull(hd, block[:]); err != nil {
+		return h, false
+	}
+	h = decodeHeader(block[:])
+	return h, string(h.Signature[:]) == "EFI PART" && h.MyLBA == 1 && validHeaderSize(h, LogicalBlockSize) && h.computeCRC() == h.HeaderCRC32
+}
+
+// Returns an error if bs isn't a logical block size that a GPT can be read
+// with, which is a power of two from 512 to 65536 bytes.
+func validBlockSize(bs uint64) error {
+	if bs < 512 || bs > 65536 || bs&(bs-1) != 0 {
+		return errorf(CodeBlockSize, "Invalid logical block size %d", bs)
+	}
+	return nil
+}
+
+// Returns the logical block size that the GPT on hd was written for, as
+// DetectBlockSize finds it, or the default block size of hd if there's no
+// valid primary header.
+func findBlockSize(hd io.ReadSeeker) uint64 {
+	if bs, err := DetectBlockSize(hd); err == nil {
+		return bs
+	}
+	return DeviceBlockSize(hd)
+}
+
+// Returns the logical block size of hd if it's a block device whose block size
+// can be queried, or LogicalBlockSize. Unlike DetectBlockSize, it doesn't
+// need hd to have a GPT, so it's the block size to create a new table with.
+func DeviceBlockSize(hd io.ReadSeeker) uint64 {
+	if bs, ok := deviceBlockSize(hd); ok {
+		return bs
+	}
+	return LogicalBlockSize
+}
+
+// Warns if there's also a GPT for another block size on hd, whose primary
+// header is valid for blocks of bs bytes. Software which assumes the other
+// block size would see a different partition table.
+func verifyBlockSize(hd io.ReadSeeker, bs uint64, r *Report) {
+	for _, other := range probedBlockSizes {
+		if _, ok := headerAt(hd, other); ok && other != bs {
+			r.add(SeverityWarning, errorf(CodeBlockSize, "Disk also has a GPT for %d byte logical blocks, which is seen instead on disks with that block size", other))
+		}
+	}
+}
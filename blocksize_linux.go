@@ -0,0 +1,26 @@
+package gpt
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+// The ioctl from <linux/fs.h> which gets a block device's logical block size.
+const blkSSZGet = 0x1268
+
+// Returns the logical block size of hd, if it's a block device.
+func deviceBlockSize(hd io.ReadSeeker) (uint64, bool) {
+	f, ok := hd.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeDevice == 0 {
+		return 0, false
+	}
+	var size int32
+	if err := ioctl(f.Fd(), blkSSZGet, uintptr(unsafe.Pointer(&size))); err != nil || size <= 0 {
+		return 0, false
+	}
+	return uint64(size), true
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package gpt
+
+import (
+	"io"
+)
+
+// Returns the logical block size of hd, if it's a block device.
+//
+// BUG(driusan): The logical block size of block devices is only queried on
+// Linux. On other operating systems, DetectBlockSize always probes for the
+// GPT.
+func deviceBlockSize(hd io.ReadSeeker) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,41 @@
+package gpt
+
+// FreeBSD partition types.
+var (
+	FreeBSDBoot   = registerType("83BD6B9D-7F41-11DC-BE0B-001560B84F0F", "FreeBSD boot", 0xA501, OSFreeBSD, CategoryBoot)
+	FreeBSDData   = registerType("516E7CB4-6ECF-11D6-8FF8-00022D09712B", "FreeBSD disklabel", 0xA500, OSFreeBSD, CategoryContainer)
+	FreeBSDSwap   = registerType("516E7CB5-6ECF-11D6-8FF8-00022D09712B", "FreeBSD swap", 0xA502, OSFreeBSD, CategorySwap)
+	FreeBSDUFS    = registerType("516E7CB6-6ECF-11D6-8FF8-00022D09712B", "FreeBSD UFS", 0xA503, OSFreeBSD, CategoryData)
+	FreeBSDZFS    = registerType("516E7CBA-6ECF-11D6-8FF8-00022D09712B", "FreeBSD ZFS", 0xA504, OSFreeBSD, CategoryData)
+	FreeBSDVinum  = registerType("516E7CB8-6ECF-11D6-8FF8-00022D09712B", "FreeBSD Vinum/RAID", 0xA505, OSFreeBSD, CategoryRAID)
+	FreeBSDNandFS = registerType("74BA7DD9-A689-11E1-BD04-00E081286ACF", "FreeBSD nandfs", 0xA506, OSFreeBSD, CategoryData)
+)
+
+// NetBSD partition types.
+var (
+	NetBSDSwap         = registerType("49F48D32-B10E-11DC-B99B-0019D1879648", "NetBSD swap", 0xA900, OSNetBSD, CategorySwap)
+	NetBSDFFS          = registerType("49F48D5A-B10E-11DC-B99B-0019D1879648", "NetBSD FFS", 0xA901, OSNetBSD, CategoryData)
+	NetBSDLFS          = registerType("49F48D82-B10E-11DC-B99B-0019D1879648", "NetBSD LFS", 0xA902, OSNetBSD, CategoryData)
+	NetBSDConcatenated = registerType("2DB519C4-B10F-11DC-B99B-0019D1879648", "NetBSD concatenated", 0xA903, OSNetBSD, CategoryRAID)
+	NetBSDEncrypted    = registerType("2DB519EC-B10F-11DC-B99B-0019D1879648", "NetBSD encrypted", 0xA904, OSNetBSD, CategoryEncrypted)
+	NetBSDRAID         = registerType("49F48DAA-B10E-11DC-B99B-0019D1879648", "NetBSD RAID", 0xA905, OSNetBSD, CategoryRAID)
+)
+
+// OpenBSD partition types. OpenBSD keeps all of its filesystems in a
+// disklabel inside a single partition.
+var (
+	OpenBSD = registerType("824CC7A0-36A8-11E3-890A-952519AD3F61", "OpenBSD", 0xA600, OSOpenBSD, CategoryContainer)
+)
+
+// DragonFly BSD partition types.
+var (
+	DragonFlyLabel32 = registerType("9D087404-1CA5-11DC-8817-01301BB8A9F5", "DragonFly label32", 0, OSDragonFly, CategoryContainer)
+	DragonFlyLabel64 = registerType("3D48CE54-1D16-11DC-8696-01301BB8A9F5", "DragonFly label64", 0, OSDragonFly, CategoryContainer)
+	DragonFlySwap    = registerType("9D58FDBD-1CA5-11DC-8817-01301BB8A9F5", "DragonFly swap", 0, OSDragonFly, CategorySwap)
+	DragonFlyUFS1    = registerType("9D94CE7C-1CA5-11DC-8817-01301BB8A9F5", "DragonFly UFS1", 0, OSDragonFly, CategoryData)
+	DragonFlyVinum   = registerType("9DD4478F-1CA5-11DC-8817-01301BB8A9F5", "DragonFly Vinum", 0, OSDragonFly, CategoryRAID)
+	DragonFlyCCD     = registerType("DBD5211B-1CA5-11DC-8817-01301BB8A9F5", "DragonFly CCD", 0, OSDragonFly, CategoryRAID)
+	DragonFlyLegacy  = registerType("BD215AB2-1D16-11DC-8696-01301BB8A9F5", "DragonFly legacy", 0, OSDragonFly, CategoryContainer)
+	DragonFlyHAMMER  = registerType("61DC63AC-6E38-11DC-8513-01301BB8A9F5", "DragonFly HAMMER", 0, OSDragonFly, CategoryData)
+	DragonFlyHAMMER2 = registerType("5CBB9AD1-862D-11DC-A94D-01301BB8A9F5", "DragonFly HAMMER2", 0, OSDragonFly, CategoryData)
+)
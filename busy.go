@@ -0,0 +1,180 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A BusyError is returned by CheckBusy for a partition which is in use by the
+// operating system, so deleting, moving or overwriting it would corrupt
+// whatever is using it.
+type BusyError struct {
+	// The index of the partition in the table.
+	Index int
+
+	// The partition's device node, ie. "/dev/sda2".
+	Device string
+
+	// The mount points of the partition's filesystem, if it's mounted.
+	MountPoints []string
+
+	// What else the partition is in use by, ie. "md RAID array /dev/md0".
+	Users []string
+}
+
+func (e *BusyError) Error() string {
+	var users []string
+	for _, m := range e.MountPoints {
+		users = append(users, "filesystem mounted at "+m)
+	}
+	users = append(users, e.Users...)
+	return fmt.Sprintf("Partition %d (%v) is in use by %s", e.Index, e.Device, strings.Join(users, ", "))
+}
+
+// Returns CodeBusy.
+func (e *BusyError) Code() ErrorCode {
+	return CodeBusy
+}
+
+func (e *BusyError) entry() int {
+	return e.Index
+}
+
+// Returns true if target is ErrBusy.
+func (e *BusyError) Is(target error) bool {
+	return target == ErrBusy
+}
+
+// A SignatureError is returned by CheckOverwrite for a partition which
+// contains a filesystem (or other recognized signature) that would be
+// destroyed.
+type SignatureError struct {
+	// The index of the partition in the table.
+	Index int
+
+	// The filesystem, as returned by ProbeFilesystem.
+	Filesystem string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("Partition %d contains a filesystem (%s)", e.Index, e.Filesystem)
+}
+
+// Returns CodeSignature.
+func (e *SignatureError) Code() ErrorCode {
+	return CodeSignature
+}
+
+func (e *SignatureError) entry() int {
+	return e.Index
+}
+
+// Returns true if target is ErrSignature.
+func (e *SignatureError) Is(target error) bool {
+	return target == ErrSignature
+}
+
+// A ForceLevel selects which of the safety checks made before modifying a
+// partition are overridden. The checks are layered, and each level overrides
+// its own check as well as those of the levels before it, so a program can
+// opt into exactly the risk it accepts.
+type ForceLevel int
+
+const (
+	// Every check is made.
+	ForceNone ForceLevel = iota
+
+	// A table read with Open, rather than OpenRW, may be modified (and
+	// written to a device opened for writing). The disk isn't locked.
+	ForceReadOnly
+
+	// A partition in use by md RAID, LVM, dm-crypt, another
+	// device-mapper device or swap may be modified.
+	ForceBusy
+
+	// A mounted partition may be modified.
+	ForceMounted
+
+	// A partition which contains a recognized filesystem may be
+	// overwritten.
+	ForceSignature
+)
+
+// The names of the force levels, as used by String and ParseForceLevel.
+var forceLevelNames = []string{"none", "read-only", "busy", "mounted", "signature"}
+
+func (l ForceLevel) String() string {
+	if l < 0 || int(l) >= len(forceLevelNames) {
+		return fmt.Sprintf("ForceLevel(%d)", int(l))
+	}
+	return forceLevelNames[l]
+}
+
+// Parses the name of a force level, as returned by ForceLevel.String.
+func ParseForceLevel(s string) (ForceLevel, error) {
+	for i, name := range forceLevelNames {
+		if s == name {
+			return ForceLevel(i), nil
+		}
+	}
+	return ForceNone, errorf(CodeInvalidArgument, "Invalid force level %q, must be one of %s", s, strings.Join(forceLevelNames, ", "))
+}
+
+// Returns a *BusyError if the partition at index of the table, which was read
+// from the whole disk device disk, is in use by the operating system: if
+// it's a mounted filesystem, a member of an md RAID array, an LVM physical
+// volume, an open dm-crypt volume or any other device-mapper device, or
+// active swap. Returns nil if disk isn't a block device, or the operating
+// system has no partition matching the entry.
+//
+// The table's Force level is ignored, see CheckModify.
+func (t *Table) CheckBusy(disk string, index int) error {
+	dev, mounts, holders := t.partitionUsers(disk, index)
+	if len(mounts) == 0 && len(holders) == 0 {
+		return nil
+	}
+	return &BusyError{Index: index, Device: dev, MountPoints: mounts, Users: holders}
+}
+
+// Checks that the partition at index of the table, which was read from the
+// whole disk device disk, can be deleted or moved. The checks are made in
+// the order of the force levels, skipping those overridden by the table's
+// Force level: that the table isn't read only, that the partition isn't in
+// use by another device or swap, and that it isn't mounted. Returns
+// ErrReadOnly or a *BusyError for the first check which fails.
+func (t *Table) CheckModify(disk string, index int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	dev, mounts, holders := t.partitionUsers(disk, index)
+	if len(holders) > 0 && t.Force < ForceBusy {
+		return &BusyError{Index: index, Device: dev, Users: holders}
+	}
+	if len(mounts) > 0 && t.Force < ForceMounted {
+		return &BusyError{Index: index, Device: dev, MountPoints: mounts}
+	}
+	return nil
+}
+
+// Checks that the contents of the partition at index of the table, which
+// was read from dev, the whole disk device disk, can be overwritten. The
+// checks of CheckModify are made, then unless the table's Force level is
+// ForceSignature, the partition is probed for a filesystem with
+// ProbeFilesystem, and a *SignatureError is returned if one is found.
+func (t *Table) CheckOverwrite(dev io.ReadSeeker, disk string, index int) error {
+	if err := t.CheckModify(disk, index); err != nil {
+		return err
+	}
+	if t.Force >= ForceSignature {
+		return nil
+	}
+	fs, err := t.ProbeFilesystem(dev, index)
+	if err != nil {
+		return err
+	}
+	if fs != "" {
+		return &SignatureError{Index: index, Filesystem: fs}
+	}
+	return nil
+}
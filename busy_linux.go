@@ -0,0 +1,118 @@
+package gpt
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Returns the device node of the kernel partition which matches the entry at
+// index of the table, which was read from the whole disk device disk, and
+// what's using it: the mount points of its filesystem, and descriptions of
+// the md RAID arrays, device-mapper devices and swap which hold it. The
+// device is empty if disk isn't a block device, or the kernel has no
+// partition matching the entry.
+func (t *Table) partitionUsers(disk string, index int) (dev string, mounts, holders []string) {
+	if st, err := os.Stat(disk); err != nil || st.Mode()&os.ModeDevice == 0 {
+		return "", nil, nil
+	}
+	dev, err := t.PartitionDevice(disk, index)
+	if err != nil {
+		return "", nil, nil
+	}
+	name := filepath.Base(dev)
+	if devno, err := os.ReadFile(filepath.Join(sysBlockDir, name, "dev")); err == nil {
+		mounts = mountPoints(strings.TrimSpace(string(devno)))
+	}
+	dir, _ := os.ReadDir(filepath.Join(sysBlockDir, name, "holders"))
+	for _, h := range dir {
+		holders = append(holders, describeHolder(h.Name()))
+	}
+	if swapping(dev) {
+		holders = append(holders, "swap")
+	}
+	return dev, mounts, holders
+}
+
+// Returns a description of the block device name which holds a partition,
+// such as "md RAID array /dev/md0" or "dm-crypt volume luks-root".
+func describeHolder(name string) string {
+	if strings.HasPrefix(name, "md") {
+		return "md RAID array /dev/" + name
+	}
+	dm := filepath.Join(sysBlockDir, name, "dm")
+	dmName, err := os.ReadFile(filepath.Join(dm, "name"))
+	if err != nil {
+		return "/dev/" + name
+	}
+	uuid, _ := os.ReadFile(filepath.Join(dm, "uuid"))
+	label := strings.TrimSpace(string(dmName))
+	// The device-mapper UUID starts with the subsystem which created
+	// the device.
+	switch {
+	case strings.HasPrefix(string(uuid), "CRYPT-"):
+		return "dm-crypt volume " + label
+	case strings.HasPrefix(string(uuid), "LVM-"):
+		return "LVM logical volume " + label
+	}
+	return "device-mapper device " + label
+}
+
+// Returns the mount points, from /proc/self/mountinfo, of the filesystems on
+// the device whose major and minor numbers are devno (ie. "8:2"). Bind mounts
+// of the same filesystem are all included.
+func mountPoints(devno string) []string {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var mounts []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 5 && fields[2] == devno {
+			mounts = append(mounts, unescapeMountPoint(fields[4]))
+		}
+	}
+	return mounts
+}
+
+// Replaces the octal escapes (ie. "\040" for a space) which the kernel uses
+// in mountinfo with the characters they represent.
+func unescapeMountPoint(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Returns true if the device dev is in use as swap.
+func swapping(dev string) bool {
+	f, err := os.Open("/proc/swaps")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0] == "Filename" {
+			continue
+		}
+		if swap, err := filepath.EvalSymlinks(fields[0]); err == nil && swap == dev {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package gpt
+
+// Returns the device node of the kernel partition which matches the entry at
+// index of the table, and what's using it.
+//
+// BUG(driusan): Partitions are only detected as mounted or otherwise in use
+// on Linux. On other operating systems, CheckBusy always returns nil.
+func (t *Table) partitionUsers(disk string, index int) (dev string, mounts, holders []string) {
+	return "", nil, nil
+}
@@ -0,0 +1,31 @@
+package gpt
+
+// Ceph partition types, as created by ceph-disk. Each kind of partition has
+// a type for while it's being created, and for when it's encrypted with
+// dm-crypt or LUKS or accessed through multipath.
+var (
+	CephOSD               = registerType("4FBD7E29-9D25-41B8-AFD0-062C0CEFF05D", "Ceph OSD", 0xF800, OSLinux, CategoryData)
+	CephDMCryptOSD        = registerType("4FBD7E29-9D25-41B8-AFD0-5EC00CEFF05D", "Ceph dm-crypt OSD", 0xF801, OSLinux, CategoryEncrypted)
+	CephJournal           = registerType("45B0969E-9B03-4F30-B4C6-B4B80CEFF106", "Ceph journal", 0xF802, OSLinux, CategoryMetadata)
+	CephDMCryptJournal    = registerType("45B0969E-9B03-4F30-B4C6-5EC00CEFF106", "Ceph dm-crypt journal", 0xF803, OSLinux, CategoryEncrypted)
+	CephDiskInCreation    = registerType("89C57F98-2FE5-4DC0-89C1-F3AD0CEFF2BE", "Ceph disk in creation", 0xF804, OSLinux, CategoryData)
+	CephDMCryptInCreation = registerType("89C57F98-2FE5-4DC0-89C1-5EC00CEFF2BE", "Ceph dm-crypt disk in creation", 0xF805, OSLinux, CategoryEncrypted)
+	CephBlock             = registerType("CAFECAFE-9B03-4F30-B4C6-B4B80CEFF106", "Ceph block", 0xF806, OSLinux, CategoryData)
+	CephBlockDB           = registerType("30CD0809-C2B2-499C-8879-2D6B78529876", "Ceph block DB", 0xF807, OSLinux, CategoryMetadata)
+	CephBlockWAL          = registerType("5CE17FCE-4087-4169-B7FF-056CC58473F9", "Ceph block write-ahead log", 0xF808, OSLinux, CategoryMetadata)
+	CephLockbox           = registerType("FB3AABF9-D25F-47CC-BF5E-721D1816496B", "Ceph lockbox for dm-crypt keys", 0xF809, OSLinux, CategoryMetadata)
+	CephMultipathOSD      = registerType("4FBD7E29-8AE0-4982-BF9D-5A8D867AF560", "Ceph multipath OSD", 0xF80A, OSLinux, CategoryData)
+	CephMultipathJournal  = registerType("45B0969E-8AE0-4982-BF9D-5A8D867AF560", "Ceph multipath journal", 0xF80B, OSLinux, CategoryMetadata)
+	CephMultipathBlock1   = registerType("CAFECAFE-8AE0-4982-BF9D-5A8D867AF560", "Ceph multipath block 1", 0xF80C, OSLinux, CategoryData)
+	CephMultipathBlock2   = registerType("7F4A666A-16F3-47A2-8445-152EF4D03F6C", "Ceph multipath block 2", 0xF80D, OSLinux, CategoryData)
+	CephMultipathBlockDB  = registerType("EC6D6385-E346-45DC-BE91-DA2A7C8B3261", "Ceph multipath block DB", 0xF80E, OSLinux, CategoryMetadata)
+	CephMultipathBlockWAL = registerType("01B41E1B-002A-453C-9F17-88793989FF8F", "Ceph multipath block write-ahead log", 0xF80F, OSLinux, CategoryMetadata)
+	CephDMCryptBlock      = registerType("CAFECAFE-9B03-4F30-B4C6-5EC00CEFF106", "Ceph dm-crypt block", 0xF810, OSLinux, CategoryEncrypted)
+	CephDMCryptBlockDB    = registerType("93B0052D-02D9-4D8A-A43B-33A3EE4DFBC3", "Ceph dm-crypt block DB", 0xF811, OSLinux, CategoryEncrypted)
+	CephDMCryptBlockWAL   = registerType("306E8683-4FE2-4330-B7C0-00A917C16966", "Ceph dm-crypt block write-ahead log", 0xF812, OSLinux, CategoryEncrypted)
+	CephLUKSJournal       = registerType("45B0969E-9B03-4F30-B4C6-35865CEFF106", "Ceph dm-crypt LUKS journal", 0xF813, OSLinux, CategoryEncrypted)
+	CephLUKSBlock         = registerType("CAFECAFE-9B03-4F30-B4C6-35865CEFF106", "Ceph dm-crypt LUKS block", 0xF814, OSLinux, CategoryEncrypted)
+	CephLUKSBlockDB       = registerType("166418DA-C469-4022-ADF4-B30AFD37F176", "Ceph dm-crypt LUKS block DB", 0xF815, OSLinux, CategoryEncrypted)
+	CephLUKSBlockWAL      = registerType("86A32090-3647-40B9-BBBD-38D8C573AA86", "Ceph dm-crypt LUKS block write-ahead log", 0xF816, OSLinux, CategoryEncrypted)
+	CephLUKSOSD           = registerType("4FBD7E29-9D25-41B8-AFD0-35865CEFF05D", "Ceph dm-crypt LUKS OSD", 0xF817, OSLinux, CategoryEncrypted)
+)
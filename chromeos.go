@@ -0,0 +1,129 @@
+package gpt
+
+import (
+	"sort"
+)
+
+// ChromeOS partition types.
+var (
+	ChromeOSKernel   = registerType("FE3A2A5D-4F32-41A7-B725-ACCC3285A309", "ChromeOS kernel", 0x7F00, OSChromeOS, CategoryBoot)
+	ChromeOSRoot     = registerType("3CB8E202-3B7E-47DD-8A3C-7FF2A13CFCEC", "ChromeOS root", 0x7F01, OSChromeOS, CategorySystem)
+	ChromeOSReserved = registerType("2E0A753D-9E48-43B0-8337-B15192CB1B5E", "ChromeOS reserved", 0x7F02, OSChromeOS, CategoryReserved)
+	ChromeOSFirmware = registerType("CAB6E88E-ABF3-4102-A07A-D4BB9BE3C1D3", "ChromeOS firmware", 0, OSChromeOS, CategoryFirmware)
+)
+
+// The location of the fields that ChromeOS packs into the GUID specific
+// attribute bits of a ChromeOS kernel partition.
+const (
+	chromeOSPriorityShift   = 48
+	chromeOSTriesShift      = 52
+	chromeOSSuccessfulShift = 56
+
+	// The maximum value of the priority and tries fields.
+	ChromeOSMaxPriority = 15
+	ChromeOSMaxTries    = 15
+)
+
+// Returns the ChromeOS kernel priority. The bootloader tries kernels with a
+// higher priority first, and never boots a kernel with priority 0.
+func (a GPTPartitionAttribute) ChromeOSPriority() int {
+	return int(a>>chromeOSPriorityShift) & 0xF
+}
+
+// Returns the number of times the bootloader will attempt to boot a ChromeOS
+// kernel which has not yet booted successfully.
+func (a GPTPartitionAttribute) ChromeOSTries() int {
+	return int(a>>chromeOSTriesShift) & 0xF
+}
+
+// Returns true if a ChromeOS kernel has booted successfully.
+func (a GPTPartitionAttribute) ChromeOSSuccessful() bool {
+	return a&(1<<chromeOSSuccessfulShift) != 0
+}
+
+// Sets the ChromeOS kernel priority, which must be between 0 and
+// ChromeOSMaxPriority.
+func (a *GPTPartitionAttribute) SetChromeOSPriority(priority int) error {
+	if priority < 0 || priority > ChromeOSMaxPriority {
+		return errorf(CodeInvalidArgument, "Invalid ChromeOS priority %d", priority)
+	}
+	*a = *a&^(0xF<<chromeOSPriorityShift) | GPTPartitionAttribute(priority)<<chromeOSPriorityShift
+	return nil
+}
+
+// Sets the number of ChromeOS kernel boot attempts remaining, which must be
+// between 0 and ChromeOSMaxTries.
+func (a *GPTPartitionAttribute) SetChromeOSTries(tries int) error {
+	if tries < 0 || tries > ChromeOSMaxTries {
+		return errorf(CodeInvalidArgument, "Invalid ChromeOS tries %d", tries)
+	}
+	*a = *a&^(0xF<<chromeOSTriesShift) | GPTPartitionAttribute(tries)<<chromeOSTriesShift
+	return nil
+}
+
+// Sets whether a ChromeOS kernel has booted successfully.
+func (a *GPTPartitionAttribute) SetChromeOSSuccessful(successful bool) {
+	a.setBit(1<<chromeOSSuccessfulShift, successful)
+}
+
+// Makes the ChromeOS kernel partition at index the highest priority kernel,
+// like "cgpt prioritize". If another kernel already has the maximum priority,
+// the priorities of the other kernels are lowered, preserving their relative
+// order.
+//
+// If tries is 0 the kernel is marked as having booted successfully. Otherwise,
+// it's marked as not yet successful and the bootloader will attempt to boot it
+// tries times before falling back to the next kernel.
+func (t *Table) ChromeOSSetActive(index, tries int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.Entries) || t.Entries[index].PartitionType != ChromeOSKernel {
+		return errorf(CodeInvalidArgument, "Partition %d is not a ChromeOS kernel partition", index)
+	}
+	if tries < 0 || tries > ChromeOSMaxTries {
+		return errorf(CodeInvalidArgument, "Invalid ChromeOS tries %d", tries)
+	}
+
+	// Find the distinct priorities of the other bootable kernels.
+	var others []int
+	maxPriority := 0
+	for i, e := range t.Entries {
+		if i == index || e.PartitionType != ChromeOSKernel {
+			continue
+		}
+		if p := e.Attributes.ChromeOSPriority(); p > 0 {
+			others = append(others, i)
+			if p > maxPriority {
+				maxPriority = p
+			}
+		}
+	}
+
+	priority := maxPriority + 1
+	if priority > ChromeOSMaxPriority {
+		// Renumber the other kernels from ChromeOSMaxPriority-1 down,
+		// keeping kernels with equal priority equal.
+		priority = ChromeOSMaxPriority
+		sort.SliceStable(others, func(i, j int) bool {
+			return t.Entries[others[i]].Attributes.ChromeOSPriority() > t.Entries[others[j]].Attributes.ChromeOSPriority()
+		})
+		next, last := ChromeOSMaxPriority, -1
+		for _, i := range others {
+			a := &t.Entries[i].Attributes
+			if p := a.ChromeOSPriority(); p != last {
+				last = p
+				if next > 1 {
+					next--
+				}
+			}
+			a.SetChromeOSPriority(next)
+		}
+	}
+
+	a := &t.Entries[index].Attributes
+	a.SetChromeOSPriority(priority)
+	a.SetChromeOSTries(tries)
+	a.SetChromeOSSuccessful(tries == 0)
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/driusan/gpt"
+)
+
+// Adds a partition to the table on disk, as described by args.
+func add(disk string, args []string) {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	esp := flags.String("esp", "", "add an EFI System Partition of the given size")
+	typ := flags.String("type", "", "add a partition of the given type")
+	sizeFlag := flags.String("size", "", "the size of the partition added with --type")
+	name := flags.String("name", "", "the name of the new partition")
+	flags.Parse(args)
+
+	var g gpt.GUID
+	var size uint64
+	var err error
+	switch {
+	case *esp != "" && *typ != "":
+		log.Fatalln("Only one of --esp and --type can be given")
+	case *esp != "":
+		size, err = parseSize(*esp)
+	case *typ != "":
+		if *sizeFlag == "" {
+			log.Fatalln("--type requires --size")
+		}
+		if g, err = gpt.ParseType(*typ); err != nil {
+			log.Fatalln(err.Error())
+		}
+		size, err = parseSize(*sizeFlag)
+	default:
+		log.Fatalln("add requires a partition to add (ie. --esp 512M)")
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	var i int
+	if *esp != "" {
+		i, err = table.AddESP(size)
+	} else {
+		i, err = table.AddPartition(g, size, partitionAlignment(table))
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := table.Entries[i].SetName(*name); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := table.Write(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	p := table.Entries[i]
+	fmt.Printf("Added %s at index %d (LBA %d-%d)\n", p.PartitionType.HumanString(), i, p.StartingLBA, p.EndingLBA)
+}
+
+// Returns the alignment, in logical blocks, of partitions added to table with
+// --type, which is 1MiB.
+func partitionAlignment(table *gpt.Table) uint64 {
+	return (1 << 20) / table.BlockSize()
+}
+
+// Parses a size in bytes, with an optional binary suffix K, M, G or T.
+func parseSize(s string) (uint64, error) {
+	mult := uint64(1)
+	switch suffix := strings.ToUpper(s[len(s)-1:]); suffix {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	case "T":
+		mult = 1 << 40
+	}
+	if mult != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid size %q", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Converges the table on disk to the YAML layout named by args.
+func apply(disk string, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing layout file")
+	}
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	prune := flags.Bool("prune", false, "allow deleting and shrinking partitions")
+	force := forceOption(flags, "with --prune, delete and shrink partitions even if they're in use")
+	flags.Parse(args[1:])
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	layout, err := gpt.ParseLayout(in)
+	in.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if planned, err := table.PlanLayout(layout, partitionAlignment(table)); err == nil && *prune {
+		for _, c := range planned {
+			if c.Destructive() {
+				checkModify(disk, dev, table, *force, false, c.Index)
+			}
+		}
+	}
+	changes, err := table.ApplyLayout(layout, partitionAlignment(table), *prune)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	switch {
+	case errors.Is(err, gpt.ErrDestructive):
+		log.Fatalln("Refusing to delete or shrink partitions without --prune")
+	case err != nil:
+		log.Fatalln(err.Error())
+	case len(changes) == 0:
+		fmt.Println("NOCHANGE: disk already matches the layout")
+		return
+	}
+	if err := table.Write(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Saves a backup of the table on disk to the file named by args.
+func backup(disk string, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing backup file")
+	}
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	sgdisk := flags.Bool("sgdisk", false, "don't pad the partition entry array, like sgdisk")
+	flags.Parse(args[1:])
+
+	f, dev := openDisk(disk, os.O_RDONLY)
+	defer f.Close()
+
+	b, err := gpt.NewBackup(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	b.SgdiskCompat = *sgdisk
+	out, err := os.Create(args[0])
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if _, err := b.WriteTo(out); err != nil {
+		out.Close()
+		log.Fatalln(err.Error())
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+// Restores the table on disk from the backup file or sfdisk dump named by
+// args.
+func restore(disk string, args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	sfdisk := flags.String("from-sfdisk", "", "restore from an sfdisk dump")
+	flags.Parse(args)
+	if *sfdisk == "" && flags.NArg() != 1 || *sfdisk != "" && flags.NArg() != 0 {
+		log.Fatalln("Usage: restore file or restore --from-sfdisk dump")
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	b := &gpt.Backup{}
+	var err error
+	if *sfdisk != "" {
+		b.Table, err = readSfdisk(*sfdisk, dev)
+	} else {
+		b, err = readBackup(flags.Arg(0))
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := b.Restore(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("Restored table with %d partitions\n", countPartitions(b.Table))
+}
+
+// Returns the number of used entries in the table.
+func countPartitions(t *gpt.Table) int {
+	n := 0
+	for _, e := range t.Entries {
+		if !e.PartitionType.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// Reads the backup file named name.
+func readBackup(name string) (*gpt.Backup, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return gpt.ReadBackup(in)
+}
+
+// Reads the sfdisk dump named name into a table for dev.
+func readSfdisk(name string, dev io.Seeker) (*gpt.Table, error) {
+	size, err := dev.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return gpt.ParseSfdisk(in, uint64(size))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/driusan/gpt"
+)
+
+// Creates a new, empty GPT on disk, as described by args.
+func create(disk string, args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	entries := flags.Uint("entries", 128, "the number of partition entries")
+	sectorSize := flags.Uint64("sector-size", 0, "the logical block size to create the GPT for (default the disk's)")
+	force := forceOption(flags, "replace an existing GPT")
+	flags.Parse(args)
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	if old, err := gpt.ReadTable(dev); err == nil {
+		checkModify(disk, dev, old, *force, false)
+		if gpt.ForceLevel(*force) < gpt.ForceSignature {
+			log.Fatalf("%v already has a GPT, use --force to replace it", disk)
+		}
+	}
+	size, err := dev.Seek(0, io.SeekEnd)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	bs := *sectorSize
+	if bs == 0 {
+		bs = gpt.DeviceBlockSize(dev)
+	}
+	table, err := gpt.CreateTable(uint64(size), uint32(*entries), gpt.WithSectorSize(bs))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := table.WriteNew(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("Created GPT with disk GUID %v, usable LBA %d-%d\n", table.Primary.Disk, table.Primary.FirstUseableLBA, table.Primary.LastUseableLBA)
+}
+
+// Changes the number of partition entries of the table on disk, as described
+// by args.
+func resizeEntries(disk string, args []string) {
+	if len(args) != 1 {
+		log.Fatalln("Usage: entries n")
+	}
+	n, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		log.Fatalf("Invalid number of entries %q", args[0])
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := table.ResizeEntryArray(uint32(n)); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := table.Write(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("Partition table now has %d entries, usable LBA %d-%d\n", table.Primary.MaxNumberPartitionEntries, table.Primary.FirstUseableLBA, table.Primary.LastUseableLBA)
+}
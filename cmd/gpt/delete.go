@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/driusan/gpt"
+)
+
+// Deletes the partition given by args from the table on disk.
+func deletePartition(disk string, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Usage: delete index [--force]")
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("Invalid partition index %q", args[0])
+	}
+	flags := flag.NewFlagSet("delete", flag.ExitOnError)
+	force := forceOption(flags, "delete the partition even if it's in use")
+	flags.Parse(args[1:])
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	var p gpt.GPTPartitionEntry
+	if index >= 0 && index < len(table.Entries) {
+		p = table.Entries[index]
+		checkModify(disk, dev, table, *force, false, index)
+	}
+	if err := table.DeletePartition(index); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := table.Write(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("Deleted %s at index %d (LBA %d-%d)\n", p.PartitionType.HumanString(), index, p.StartingLBA, p.EndingLBA)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Prints the differences between the tables of the two disks, images,
+// backups or sfdisk dumps named by args, and exits with status 1 if there are
+// any.
+func diff(args []string) {
+	if len(args) != 2 {
+		log.Fatalln("Usage: diff disk|backup|dump disk|backup|dump")
+	}
+	a, err := loadTable(args[0])
+	if err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+	b, err := loadTable(args[1])
+	if err != nil {
+		log.Fatalf("%s: %v", args[1], err)
+	}
+	diffs := gpt.DiffTables(a, b)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Reads the table from name, which may be a disk or disk image, a backup made
+// by the backup action or "sgdisk --backup", or a dump made by
+// "sfdisk --dump".
+func loadTable(name string) (*gpt.Table, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 3*gpt.LogicalBlockSize)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(head), []byte("label:")):
+		defer f.Close()
+		return gpt.ParseSfdisk(f, 0)
+	case isBackup(head):
+		defer f.Close()
+		b, err := gpt.ReadBackup(f)
+		if err != nil {
+			return nil, err
+		}
+		return b.Table, nil
+	}
+	f.Close()
+
+	disk, dev := openDisk(name, os.O_RDONLY)
+	defer disk.Close()
+	return readTable(dev)
+}
+
+// Returns true if head, the start of a file, is the start of a backup. Unlike
+// a disk, a backup has a second header in the block after the primary header.
+func isBackup(head []byte) bool {
+	sig := []byte("EFI PART")
+	bs := int(gpt.LogicalBlockSize)
+	return len(head) == 3*bs && bytes.HasPrefix(head[bs:], sig) && bytes.HasPrefix(head[2*bs:], sig)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/ewf"
+	"github.com/driusan/gpt/qcow2"
+	"github.com/driusan/gpt/vhd"
+	"github.com/driusan/gpt/vmdk"
+)
+
+// The byte offset of the GPT within the disk.
+var offset = flag.Int64("offset", 0, "byte offset of the disk within the file")
+
+// The lock on the disk opened for writing by openDisk, which main releases
+// when the action is done.
+var diskLock *gpt.DeviceLock
+
+// Whether to log every read and write of the GPT.
+var debug = flag.Bool("debug", false, "log every read and write of the GPT to stderr")
+
+// Reads the GPT from dev, logging to stderr if -debug was given.
+func readTable(dev io.ReadSeeker) (*gpt.Table, error) {
+	var logger *slog.Logger
+	if *debug {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	t, err := gpt.ReadTable(dev, gpt.WithLogger(logger))
+	if err == nil && t.FromBackup {
+		fmt.Fprintln(os.Stderr, "warning: the primary GPT header is invalid, using the backup header")
+	}
+	return t, err
+}
+
+// A --force option. Given alone, it overrides every safety check, otherwise
+// its value is the name of the gpt.ForceLevel to use, ie. --force=mounted.
+type forceFlag gpt.ForceLevel
+
+// Adds a --force option to flags.
+func forceOption(flags *flag.FlagSet, usage string) *forceFlag {
+	f := new(forceFlag)
+	flags.Var(f, "force", usage)
+	return f
+}
+
+func (f *forceFlag) String() string {
+	return gpt.ForceLevel(*f).String()
+}
+
+func (f *forceFlag) Set(s string) error {
+	switch s {
+	case "true":
+		*f = forceFlag(gpt.ForceSignature)
+		return nil
+	case "false":
+		*f = forceFlag(gpt.ForceNone)
+		return nil
+	}
+	l, err := gpt.ParseForceLevel(s)
+	*f = forceFlag(l)
+	return err
+}
+
+func (f *forceFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Sets the table's Force level to force, and exits the program if any of the
+// partitions at indexes of the table, which was read from dev on disk, can't
+// be modified at that level. If overwrite is set, the partitions' contents
+// must also be safe to overwrite. If no indexes are given, every partition in
+// use is checked.
+func checkModify(disk string, dev io.ReadSeeker, table *gpt.Table, force forceFlag, overwrite bool, indexes ...int) {
+	table.Force = gpt.ForceLevel(force)
+	if len(indexes) == 0 {
+		for i, e := range table.Entries {
+			if !e.PartitionType.IsZero() {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	for _, i := range indexes {
+		var err error
+		if overwrite {
+			err = table.CheckOverwrite(dev, disk, i)
+		} else {
+			err = table.CheckModify(disk, i)
+		}
+		if err == nil {
+			continue
+		}
+		// The level needed to override the check which failed.
+		need := gpt.ForceSignature
+		var busy *gpt.BusyError
+		switch {
+		case errors.Is(err, gpt.ErrReadOnly):
+			need = gpt.ForceReadOnly
+		case errors.As(err, &busy) && len(busy.MountPoints) > 0:
+			need = gpt.ForceMounted
+		case errors.As(err, &busy):
+			need = gpt.ForceBusy
+		}
+		log.Fatalf("%v, use --force=%v to override", err, need)
+	}
+}
+
+// Opens disk with the given os.OpenFile flags, and returns both the file
+// and the device that the GPT should be read from, which is relative to the
+// -offset flag. Disk images are detected automatically, and the device is
+// the virtual disk inside them. If the disk is opened for writing, it's
+// locked until the action is done. Exits the program if the disk can't be
+// opened.
+func openDisk(disk string, flags int) (gpt.BlockDevice, io.ReadWriteSeeker) {
+	if flags&os.O_RDWR != 0 && (disk == "-" || isURL(disk)) {
+		log.Fatalf("%v can only be read, not modified", disk)
+	}
+	if flags&os.O_RDWR != 0 {
+		lock, err := gpt.LockDevice(disk)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		diskLock = lock
+	}
+	f, err := openFile(disk, flags)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if *offset == 0 {
+		return f, f
+	}
+	dev, err := gpt.NewOffsetDevice(f, *offset, 0)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	return f, dev
+}
+
+// Returns true if disk is an HTTP or HTTPS URL rather than a file.
+func isURL(disk string) bool {
+	return strings.HasPrefix(disk, "http://") || strings.HasPrefix(disk, "https://")
+}
+
+// Opens the disk image on stdin. If stdin is a raw image file it's read
+// directly, otherwise it's copied to a temporary file first, since the GPT
+// can't be read without seeking. Image formats such as qcow2 are detected
+// either way.
+func openStdin() (gpt.BlockDevice, error) {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode().IsRegular() {
+		format, ok := stdinFormat(fi.Size())
+		if !ok {
+			return os.Stdin, nil
+		}
+		// The formats open images by name, which the file is still
+		// reachable by where there's a /dev/stdin.
+		if _, err := os.Stat("/dev/stdin"); err == nil {
+			return format.Open("/dev/stdin", false)
+		}
+	}
+	tmp, err := os.CreateTemp("", "gpt-stdin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, os.Stdin); err != nil {
+		return nil, fmt.Errorf("Could not read stdin: %v", err)
+	}
+	// The copy is opened again so that image formats such as qcow2 are
+	// detected.
+	return openFile(tmp.Name(), os.O_RDONLY)
+}
+
+// Opens disk, detecting its format from its magic number. disk may also be
+// "-" for stdin or an HTTP URL, which are read only.
+func openFile(disk string, flags int) (gpt.BlockDevice, error) {
+	switch {
+	case disk == "-":
+		return openStdin()
+	case isURL(disk):
+		return gpt.OpenHTTP(disk)
+	}
+	if flags&os.O_RDWR != 0 {
+		return gpt.OpenImageRW(disk)
+	}
+	return gpt.OpenImage(disk)
+}
+
+// The disk image formats which openFile detects.
+var imageFormats = []gpt.ImageFormat{qcow2.Format, vmdk.Format, vhd.Format, ewf.Format}
+
+// Registers the disk image formats which openFile detects.
+func registerImageFormats() {
+	for _, f := range imageFormats {
+		gpt.RegisterImageFormat(f)
+	}
+}
+
+// Returns the format of the image on stdin, which is a file of size bytes,
+// if it's in one of the detected formats rather than a raw image.
+func stdinFormat(size int64) (gpt.ImageFormat, bool) {
+	for _, f := range imageFormats {
+		if f.Match(os.Stdin, size) {
+			return f, true
+		}
+	}
+	return gpt.ImageFormat{}, false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Adds a partition filling the largest free region of the table on disk, or
+// every free region, as described by args.
+func fill(disk string, args []string) {
+	flags := flag.NewFlagSet("fill", flag.ExitOnError)
+	typ := flags.String("type", "", "the type of the new partitions")
+	name := flags.String("name", "", "the name of the new partitions")
+	all := flags.Bool("all", false, "fill every free region instead of the largest")
+	flags.Parse(args)
+	if *typ == "" {
+		log.Fatalln("fill requires a partition type (ie. --type linux)")
+	}
+	g, err := gpt.ParseType(*typ)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	added, err := table.FillFree(g, partitionAlignment(table), *all)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	for _, i := range added {
+		if err := table.Entries[i].SetName(*name); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+	if err := table.Write(dev); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	for _, i := range added {
+		p := table.Entries[i]
+		fmt.Printf("Added %s at index %d (LBA %d-%d)\n", p.PartitionType.HumanString(), i, p.StartingLBA, p.EndingLBA)
+	}
+}
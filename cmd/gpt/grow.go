@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Grows the last partition on disk to fill the disk.
+func grow(disk string, args []string) {
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	switch err := table.GrowLastPartition(dev); err {
+	case nil:
+	case gpt.ErrNoChange:
+		fmt.Println("NOCHANGE: last partition already fills the disk")
+		return
+	default:
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("Last usable LBA is now %d\n", table.Primary.LastUseableLBA)
+}
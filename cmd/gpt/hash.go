@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// The hash algorithms accepted by the hash action.
+var hashAlgorithms = map[string]crypto.Hash{
+	"md5":    crypto.MD5,
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha512": crypto.SHA512,
+}
+
+// Prints the hash of the contents of the partition given by args.
+func hash(disk string, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing partition index")
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("Invalid partition index %q", args[0])
+	}
+	flags := flag.NewFlagSet("hash", flag.ExitOnError)
+	algorithm := flags.String("algorithm", "sha256", "hash algorithm (md5, sha1, sha256 or sha512)")
+	progress := flags.Bool("progress", false, "print the progress of reading the partition to stderr")
+	rateLimit := flags.String("rate-limit", "0", "maximum bytes per second to read (suffixes K, M, G and T are accepted)")
+	flags.Parse(args[1:])
+	h, ok := hashAlgorithms[*algorithm]
+	if !ok {
+		log.Fatalf("Unknown hash algorithm %q", *algorithm)
+	}
+	rate, err := parseSize(*rateLimit)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	f, dev := openDisk(disk, os.O_RDONLY)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if *progress {
+		table.Progress = printProgress(os.Stderr)
+	}
+	table.RateLimit = rate
+	sum, err := table.HashPartition(dev, index, h)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("%x  %s partition %d\n", sum, disk, index)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Lists the disks on the machine, or checks the disks on the machine or in
+// the inventories named by args for duplicate GUIDs.
+func list(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the inventory of the disks as JSON")
+	dups := flags.Bool("check-duplicates", false, "report GUIDs used by more than one disk or partition")
+	flags.Parse(args)
+	if flags.NArg() > 0 && !*dups {
+		log.Fatalln("Inventory files can only be given with --check-duplicates")
+	}
+
+	var inventories []*gpt.Inventory
+	if flags.NArg() > 0 {
+		for _, name := range flags.Args() {
+			inv, err := readInventory(name)
+			if err != nil {
+				log.Fatalf("%s: %v", name, err)
+			}
+			inventories = append(inventories, inv)
+		}
+	} else {
+		inv, err := gpt.TakeInventory(context.Background())
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		inventories = append(inventories, inv)
+	}
+
+	switch {
+	case *dups:
+		found := gpt.FindDuplicates(inventories)
+		for _, d := range found {
+			fmt.Printf("%s is used by:\n", d.GUID)
+			for _, u := range d.Uses {
+				fmt.Printf("\t%s\n", u)
+			}
+		}
+		if len(found) > 0 {
+			os.Exit(1)
+		}
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(inventories[0]); err != nil {
+			log.Fatalln(err.Error())
+		}
+	default:
+		for _, d := range inventories[0].Disks {
+			fmt.Printf("%s %s %d partitions\n", d.Path, d.GUID, len(d.Partitions))
+		}
+	}
+}
+
+// Reads an inventory printed by "list --json".
+func readInventory(name string) (*gpt.Inventory, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var inv gpt.Inventory
+	if err := json.NewDecoder(f).Decode(&inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
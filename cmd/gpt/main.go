@@ -1,87 +1,256 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	//"io"
-	"encoding/binary"
 	"fmt"
-
-	"github.com/driusan/gpt"
 )
 
 func main() {
-	// TODO: Read this from the command line
-	if len(os.Args) < 3 {
+	registerImageFormats()
+	flag.Parse()
+	args := flag.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff":
+			diff(args[1:])
+			return
+		case "list":
+			list(args[1:])
+			return
+		case "serve":
+			serve(args[1:])
+			return
+		}
+	}
+	if len(args) < 2 {
 		fmt.Fprintf(os.Stderr,
-			`Usage: %s disk action
+			`Usage: %s [-offset bytes] [-debug] disk action
+       %s [-offset bytes] [-debug] diff a b
+       %s list [--json] [--check-duplicates [inventory...]]
+       %s serve [--listen address] [--max-level level]
 
 disk is the file of the block device on your operating system (ie. /dev/sda)
-and action is the subcommand to run.
+or a disk image (raw, qcow2, VMDK, VHD, VHDX or EWF) and action is the subcommand
+to run. disk may also be - to read a disk image from stdin, or an HTTP or
+HTTPS URL of a raw disk image on a server which supports range requests, in
+which case only the parts of the image that are needed are downloaded. Neither
+can be modified. If -offset is given, the disk is read starting at that byte offset of
+the file (ie. for an image embedded in another file.) If -debug is given,
+every read and write of the GPT is logged to stderr.
 
-Valid actions are:
-	verify	verifies that the installed GPT table is valid
-	show  	shows the GPT table currently installed
+diff prints the differences between the partition tables of a and b, each of
+which may be a disk, a disk image, a backup made by the backup action or
+"sgdisk --backup", or a dump made by "sfdisk --dump". It exits with status 1
+if there are any differences.
 
-Note that only 512 logical block sizes are currently supported.
-`, os.Args[0])
-		os.Exit(2)
-	}
+list prints the disks on this machine which have a GPT, with their disk GUID
+and number of partitions. Options:
+	--json	print the disks and their partitions as a JSON inventory
+	--check-duplicates	report disk and partition GUIDs which are used
+				more than once, on this machine or across the
+				inventories printed by "list --json" on
+				other machines
 
-	// Open the block device
-	f, err := os.Open(os.Args[1])
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-	defer f.Close()
+serve serves the partition tables of the disks on this machine over HTTP as
+JSON, for inventory systems. Nothing is written to the disks. The endpoints
+are /disks (the inventory printed by "list --json"), /disks/{dev}/table
+and /disks/{dev}/verify, where dev is the name of a disk (ie. sda). The
+verify endpoint takes the optional query parameters level and names, which
+work like the options of the verify action. Options:
+	--listen address	the address to listen on (default :8080)
+	--max-level level	the most thorough verification level that
+				clients may request (default partitions)
 
-	// Attempt to read one block. This is the protective MBR and gets
-	// thrown away. TODO: Actually read the MBR
-	header := gpt.GPTHeader{}
-	err = binary.Read(f, binary.LittleEndian, &header)
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
+Valid actions are:
+	verify	verifies that the installed GPT table is valid. Options:
+		--names	also check for partition names that may cause
+			problems with firmware or udev
+		--fix-guids	replace zero and duplicate GUIDs with new
+				random GUIDs
+		--level level	how thoroughly to check the disk, one of
+				header, checksums, backup, partitions
+				(the default), contents or deep
+		--deep	read every block of every partition and
+				report the blocks which can't be read
+				(the same as --level deep)
+		--free-space	with --deep, also read the free space
+		--repair	apply the suggested repairs for checksum
+				errors
+		--progress	print the progress of reading partitions
+		--strict	treat warnings (such as misaligned partitions)
+				as errors
+		--round-trip	also check that writing the table back
+				unmodified would not change any sectors
+		--json	print the findings as JSON, with a stable code
+			for each problem
+	show  	shows the GPT table currently installed. Options:
+		--format gpt|gdisk|header	the output format. gdisk
+					prints the same output as
+					"sgdisk -p", header prints every
+					field of both headers
+		--template text	print each partition with a Go text/template,
+				ie. '{{.Index}} {{.Name}} {{.TypeName}}'.
+				The fields of gpt.GPTPartitionEntry and
+				Index, Name, TypeName, AttributeNames and
+				Table are available
+	create	creates a new, empty GPT and protective MBR. Options:
+		--entries n	the number of partition entries (128 by
+				default, more can be used for disks with
+				many partitions)
+		--sector-size n	the logical block size in bytes to
+				create the GPT for (the disk's block size,
+				or 512 for images, by default)
+		--force	replace an existing GPT
+	entries	changes the number of partition entries, moving the usable
+		area to make room for more entries or to reclaim the space
+		of unused entries. Usage: entries n
+	add   	adds a partition to the GPT table. Options:
+		--esp size	add an EFI System Partition of size bytes
+				(suffixes K, M, G and T are accepted)
+		--type type	add a partition of type, which may be a
+				GUID, an sfdisk short hand (ie. "L"), a
+				gdisk code (ie. "8300"), a systemd-repart
+				type (ie. "home") or a name shown by
+				"show" (ie. "Linux LVM")
+		--size size	the size of the partition added with --type
+		--name name	the name of the new partition
+	delete	deletes a partition from the GPT table, leaving its contents
+		on the disk. Usage: delete index [--force]. Partitions
+		which are mounted or otherwise in use by the operating
+		system (ie. by LVM, md RAID, dm-crypt or swap) are only
+		deleted with --force
+	fill  	adds a partition filling the largest free region of the
+		disk. Options:
+		--type type	the type of the partition, as for add
+		--name name	the name of the partition
+		--all	add a partition to every free region instead
+	apply 	changes the partitions to match a YAML layout, printing
+		each change. Usage: apply layout.yaml [options]. See
+		gpt.ParseLayout for the format. Options:
+		--prune	allow deleting partitions which aren't in the
+			layout, and shrinking partitions to fit it
+		--force	with --prune, delete and shrink partitions
+			even if they're in use by the operating system
+	hash  	prints the hash of the contents of a partition. Usage:
+		hash index [options]. Options:
+		--algorithm name	md5, sha1, sha256 (the default) or
+					sha512
+		--progress	print the progress of reading the partition
+		--rate-limit size	read at most size bytes per second
+					(suffixes K, M, G and T are accepted)
+	move  	moves a partition, copying its contents. Usage:
+		move index lba [options]. Options:
+		--journal file	record the progress of the move in file,
+				so that it can be resumed or rolled back
+				if it's interrupted
+		--resume	finish the interrupted move recorded in the
+				journal (index and lba are not needed)
+		--rollback	undo the interrupted move recorded in the
+				journal
+		--progress	print the progress of the move
+		--force	move the partition even if it's in use by
+			the operating system
+	realign	moves partitions which aren't aligned to the nearest aligned
+		LBA that they fit at. Options:
+		--align blocks	the alignment in logical blocks (1MiB, or
+				4KiB for disks partitioned by Apple's
+				tools, by default)
+		--dry-run	only print the partitions which would be
+				moved
+		--journal file	record the progress of each move in file,
+				as for move
+		--progress	print the progress of each move
+		--force	move partitions even if they're in use by
+			the operating system
+	shred 	erases the contents of a partition by overwriting it with
+		random data and then zeros. Usage: shred index [options].
+		Options:
+		--passes n	the number of passes of random data (1 by
+				default)
+		--discard	discard the partition's blocks (or punch a
+				hole in an image) instead
+		--crypto	overwrite the header and key slots of a LUKS
+				encrypted partition instead, making it
+				impossible to decrypt
+		--progress	print the progress of erasing the partition
+		--force	erase the partition even if it's in use by
+			the operating system or contains a filesystem
+	report	prints a Digital Forensics XML (DFXML) report of the disk,
+		with both headers and the byte offset and length of every
+		partition. Options:
+		--hash algorithms	also hash each partition with the
+					comma separated algorithms (md5,
+					sha1, sha256 or sha512)
+		--progress	print the progress of hashing the partitions
+	grow  	moves the backup GPT to the end of the disk, and grows the
+		last partition to fill the disk
+	backup	saves the GPT and MBR to a file in the format used by
+		"sgdisk --backup". Usage: backup file [options]. Options:
+		--sgdisk	don't pad the partition entry array to a
+				whole sector, exactly like sgdisk
+	restore	restores the GPT and MBR from a backup made by backup or
+		"sgdisk --backup". Usage: restore file or
+		restore --from-sfdisk dump. Options:
+		--from-sfdisk dump	create the GPT from a dump made by
+					"sfdisk --dump" instead
+	watch 	prints a line whenever the GPT changes. Options:
+		--once	exit after the first change, with status 1 if the
+			new GPT can't be read
 
-	// Read the real GPT header
-	err = binary.Read(f, binary.LittleEndian, &header)
-	if err != nil {
-		log.Fatalf("%v", err)
+The --force option of the actions which modify partitions overrides every
+safety check when given alone. --force=level only overrides the checks up
+to level, which is one of read-only, busy (in use by LVM, md RAID, dm-crypt
+or swap), mounted or signature (contains a filesystem, or for create, an
+existing GPT).
+
+The logical block size of a disk or image is detected from where its GPT
+header is found, so tables for 512, 2048 and 4096 byte logical blocks are all
+supported.
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+		os.Exit(2)
 	}
 
-	switch cmd := os.Args[2]; cmd {
-	case "verify":
-		err = header.Verify()
-		if err != nil {
-			log.Fatalln(err.Error())
-		}
-		fmt.Printf("GPT appears to be valid.\n")
-		os.Exit(0)
+	// diskLock is only set once the action opens the disk.
+	defer func() { diskLock.Unlock() }()
+	switch args[1] {
+	case "create":
+		create(args[0], args[2:])
+	case "entries":
+		resizeEntries(args[0], args[2:])
+	case "add":
+		add(args[0], args[2:])
 	case "show":
-		// Still need to verify the header
-		err = header.Verify()
-		if err != nil {
-			log.Fatalln(err.Error())
-		}
-
-		// Print a header line with the same formatting width as the
-		// print statements
-
-		partitions, err := header.GetPartitions(f)
-		if err != nil {
-			log.Fatalln(err.Error())
-		}
-
-		fmt.Printf("%11s %11s %5s %s\n", "Start", "Size", "Index", "Contents")
-		for i, p := range partitions {
-			if p.PartitionType != gpt.ZeroGUID {
-				if name := p.GetName(); name != "" {
-					fmt.Printf("%11d %11d %5d %s (Part name: %s)\n", p.StartingLBA, p.Size(), i, p.PartitionType.HumanString(), name)
-				} else {
-					fmt.Printf("%11d %11d %5d %s\n", p.StartingLBA, p.Size(), i, p.PartitionType.HumanString())
-				}
-			}
-		}
+		show(args[0], args[2:])
+	case "verify":
+		verify(args[0], args[2:])
+	case "hash":
+		hash(args[0], args[2:])
+	case "move":
+		move(args[0], args[2:])
+	case "realign":
+		realign(args[0], args[2:])
+	case "grow":
+		grow(args[0], args[2:])
+	case "backup":
+		backup(args[0], args[2:])
+	case "restore":
+		restore(args[0], args[2:])
+	case "watch":
+		watch(args[0], args[2:])
+	case "delete":
+		deletePartition(args[0], args[2:])
+	case "fill":
+		fill(args[0], args[2:])
+	case "apply":
+		apply(args[0], args[2:])
+	case "shred":
+		shred(args[0], args[2:])
+	case "report":
+		report(args[0], args[2:])
+	default:
+		log.Fatalf("Unknown action %q", args[1])
 	}
-
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/driusan/gpt"
+)
+
+// Moves a partition on disk, or resumes or rolls back an interrupted move, as
+// requested by args.
+func move(disk string, args []string) {
+	var index int
+	var lba uint64
+	positional := len(args) >= 2 && !strings.HasPrefix(args[0], "-")
+	if positional {
+		var err error
+		if index, err = strconv.Atoi(args[0]); err != nil {
+			log.Fatalf("Invalid partition index %q", args[0])
+		}
+		if lba, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			log.Fatalf("Invalid LBA %q", args[1])
+		}
+		args = args[2:]
+	}
+	flags := flag.NewFlagSet("move", flag.ExitOnError)
+	journal := flags.String("journal", "", "record the progress of the move in this file")
+	resume := flags.Bool("resume", false, "finish the interrupted move recorded in the journal")
+	rollback := flags.Bool("rollback", false, "undo the interrupted move recorded in the journal")
+	progress := flags.Bool("progress", false, "print the progress of the move to stderr")
+	force := forceOption(flags, "move the partition even if it's in use")
+	flags.Parse(args)
+	switch {
+	case *resume && *rollback:
+		log.Fatalln("Only one of --resume and --rollback can be given")
+	case (*resume || *rollback) && *journal == "":
+		log.Fatalln("--resume and --rollback require --journal")
+	case !*resume && !*rollback && !positional:
+		log.Fatalln("Missing partition index and LBA")
+	}
+	if *resume || *rollback {
+		j, err := gpt.ReadJournal(*journal)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		index = j.SrcIndex
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if index >= 0 && index < len(table.Entries) {
+		checkModify(disk, dev, table, *force, false, index)
+	}
+	if *progress {
+		table.Progress = printProgress(os.Stderr)
+	}
+	switch {
+	case *resume:
+		err = table.ResumeMove(dev, *journal)
+	case *rollback:
+		err = table.RollbackMove(dev, *journal)
+	default:
+		err = table.MovePartition(dev, index, lba, *journal)
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("Partition %d is at LBA %d-%d\n", index, table.Entries[index].StartingLBA, table.Entries[index].EndingLBA)
+}
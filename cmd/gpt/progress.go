@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/driusan/gpt"
+)
+
+// Returns a gpt.ProgressFunc which prints a single updating line of progress
+// to w (usually os.Stderr.)
+func printProgress(w io.Writer) gpt.ProgressFunc {
+	return func(p gpt.Progress) {
+		eta := ""
+		if d := p.ETA(); d > 0 {
+			eta = fmt.Sprintf(", %v remaining", d.Round(time.Second))
+		}
+		fmt.Fprintf(w, "\r\033[K%s: %5.1f%% (%s of %s%s)", p.Phase, 100*p.Fraction(), ieeeSize(p.Done), ieeeSize(p.Total), eta)
+		if p.Done >= p.Total {
+			fmt.Fprintln(w)
+		}
+	}
+}
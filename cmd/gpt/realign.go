@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Moves the misaligned partitions on disk to aligned LBAs, with the options
+// in args.
+func realign(disk string, args []string) {
+	flags := flag.NewFlagSet("realign", flag.ExitOnError)
+	align := flags.Uint64("align", 0, "the alignment in logical blocks (1MiB, or 4KiB for Apple disks, by default)")
+	dryRun := flags.Bool("dry-run", false, "only print the partitions which would be moved")
+	journal := flags.String("journal", "", "record the progress of each move in this file")
+	progress := flags.Bool("progress", false, "print the progress of each move to stderr")
+	force := forceOption(flags, "move partitions even if they're in use")
+	flags.Parse(args)
+
+	mode := os.O_RDWR
+	if *dryRun {
+		mode = os.O_RDONLY
+	}
+	f, dev := openDisk(disk, mode)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if !*dryRun {
+		checkModify(disk, dev, table, *force, false)
+	}
+	if *progress {
+		table.Progress = printProgress(os.Stderr)
+	}
+	results, err := table.RealignPartitions(dev, gpt.AlignPolicy{Align: *align, DryRun: *dryRun, Journal: *journal})
+	failed := false
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("Partition %d (LBA %d) can not be aligned: %v\n", r.Index, r.From, r.Err)
+			failed = true
+		case *dryRun:
+			fmt.Printf("Partition %d would be moved from LBA %d to LBA %d\n", r.Index, r.From, r.To)
+		default:
+			fmt.Printf("Moved partition %d from LBA %d to LBA %d\n", r.Index, r.From, r.To)
+		}
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if !*dryRun {
+		if err := f.Sync(); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+	if len(results) == 0 {
+		fmt.Println("All partitions are aligned.")
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
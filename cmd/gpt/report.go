@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto"
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+// Prints a DFXML report of the table on disk, as described by args.
+func report(disk string, args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	algorithms := flags.String("hash", "", "comma separated hash algorithms to hash each partition with (md5, sha1, sha256 or sha512)")
+	progress := flags.Bool("progress", false, "print the progress of hashing partitions to stderr")
+	flags.Parse(args)
+	var hashes []crypto.Hash
+	if *algorithms != "" {
+		for _, name := range strings.Split(*algorithms, ",") {
+			h, ok := hashAlgorithms[name]
+			if !ok {
+				log.Fatalf("Unknown hash algorithm %q", name)
+			}
+			hashes = append(hashes, h)
+		}
+	}
+
+	f, dev := openDisk(disk, os.O_RDONLY)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if *progress {
+		table.Progress = printProgress(os.Stderr)
+	}
+	if err := table.WriteDFXML(os.Stdout, dev, disk, hashes...); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
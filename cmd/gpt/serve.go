@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+
+	"github.com/driusan/gpt"
+)
+
+// The partition table of a disk, as served by /disks/{dev}/table.
+type tableJSON struct {
+	Path               string          `json:"path"`
+	GUID               gpt.GUID        `json:"guid"`
+	FromBackup         bool            `json:"from_backup,omitempty"`
+	FirstUsableLBA     uint64          `json:"first_usable_lba"`
+	LastUsableLBA      uint64          `json:"last_usable_lba"`
+	BackupLBA          uint64          `json:"backup_lba"`
+	PartitionEntries   uint32          `json:"partition_entries"`
+	PartitionEntrySize uint32          `json:"partition_entry_size"`
+	Partitions         []partitionJSON `json:"partitions"`
+}
+
+// A partition in a tableJSON.
+type partitionJSON struct {
+	Index      int      `json:"index"`
+	GUID       gpt.GUID `json:"guid"`
+	Type       gpt.GUID `json:"type"`
+	TypeName   string   `json:"type_name"`
+	Name       string   `json:"name,omitempty"`
+	StartLBA   uint64   `json:"start_lba"`
+	EndLBA     uint64   `json:"end_lba"`
+	Attributes uint64   `json:"attributes"`
+}
+
+// Serves the partition tables of the disks on the machine over HTTP as JSON,
+// as requested by args. Nothing is ever written to the disks.
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", ":8080", "the address to listen on")
+	maxLevel := flags.String("max-level", "partitions", "the most thorough verification level that clients may request")
+	flags.Parse(args)
+	limit, ok := parseVerifyLevel(*maxLevel)
+	if !ok {
+		log.Fatalf("Invalid level \"%v\"", *maxLevel)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /disks", func(w http.ResponseWriter, r *http.Request) {
+		inv, err := gpt.TakeInventory(r.Context())
+		if err != nil {
+			serveError(w, http.StatusInternalServerError, err)
+			return
+		}
+		serveJSON(w, inv)
+	})
+	mux.HandleFunc("GET /disks/{dev}/table", func(w http.ResponseWriter, r *http.Request) {
+		d, ok := openServedDisk(w, r)
+		if !ok {
+			return
+		}
+		defer d.Close()
+		serveJSON(w, describeTable(d.File().Name(), d.Table))
+	})
+	mux.HandleFunc("GET /disks/{dev}/verify", func(w http.ResponseWriter, r *http.Request) {
+		level := gpt.VerifyPartitions
+		if s := r.URL.Query().Get("level"); s != "" {
+			var ok bool
+			if level, ok = parseVerifyLevel(s); !ok || level > limit {
+				serveError(w, http.StatusBadRequest, errors.New("Invalid or disallowed level \""+s+"\""))
+				return
+			}
+		}
+		d, ok := openServedDisk(w, r)
+		if !ok {
+			return
+		}
+		defer d.Close()
+		names := r.URL.Query().Has("names")
+		serveJSON(w, gpt.Verify(d.File(), gpt.VerifyOptions{Level: level, Names: names}))
+	})
+
+	log.Printf("Serving partition tables on %v", *listen)
+	log.Fatalln(http.ListenAndServe(*listen, mux))
+}
+
+// Opens the disk named by the request's {dev}, which must be one of the disks
+// in the machine's inventory (ie. "sda" for /dev/sda), writing an error
+// response if it can't be opened.
+func openServedDisk(w http.ResponseWriter, r *http.Request) (*gpt.Disk, bool) {
+	inv, err := gpt.TakeInventory(r.Context())
+	if err != nil {
+		serveError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	path := filepath.Join("/dev", r.PathValue("dev"))
+	if !slices.ContainsFunc(inv.Disks, func(d gpt.InventoryDisk) bool { return d.Path == path }) {
+		serveError(w, http.StatusNotFound, errors.New("No disk with a GPT named \""+r.PathValue("dev")+"\""))
+		return nil, false
+	}
+	d, err := gpt.Open(path)
+	if err != nil {
+		serveError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return d, true
+}
+
+// Returns the JSON representation of the table t, read from path.
+func describeTable(path string, t *gpt.Table) tableJSON {
+	tj := tableJSON{
+		Path:               path,
+		GUID:               t.Primary.Disk,
+		FromBackup:         t.FromBackup,
+		FirstUsableLBA:     t.Primary.FirstUseableLBA,
+		LastUsableLBA:      t.Primary.LastUseableLBA,
+		BackupLBA:          t.Primary.AltLBA,
+		PartitionEntries:   t.Primary.MaxNumberPartitionEntries,
+		PartitionEntrySize: t.Primary.SizeOfPartitionEntry,
+		Partitions:         []partitionJSON{},
+	}
+	for i, e := range t.Used() {
+		tj.Partitions = append(tj.Partitions, partitionJSON{
+			Index:      i,
+			GUID:       e.UniqueParitition,
+			Type:       e.PartitionType,
+			TypeName:   e.PartitionType.HumanString(),
+			Name:       e.GetName(),
+			StartLBA:   e.StartingLBA,
+			EndLBA:     e.EndingLBA,
+			Attributes: uint64(e.Attributes),
+		})
+	}
+	return tj
+}
+
+// Writes v as the JSON response.
+func serveJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// Writes err as a JSON error response with the HTTP status code status, and
+// the error's gpt.ErrorCode.
+func serveError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string        `json:"error"`
+		Code  gpt.ErrorCode `json:"code"`
+	}{err.Error(), gpt.CodeOf(err)})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/driusan/gpt"
+)
+
+// Shows the table on disk, in the format requested by args.
+func show(disk string, args []string) {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	format := flags.String("format", "gpt", "output format (gpt, gdisk or header)")
+	tmpl := flags.String("template", "", "print each partition with a text/template")
+	flags.Parse(args)
+
+	var t *template.Template
+	if *tmpl != "" {
+		var err error
+		if t, err = template.New("show").Parse(*tmpl); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+
+	f, dev := openDisk(disk, os.O_RDONLY)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	switch {
+	case t != nil:
+		showTemplate(t, table)
+	case *format == "gpt":
+		showDefault(table)
+	case *format == "header":
+		fmt.Println("Primary header:")
+		table.Primary.DebugDump(os.Stdout)
+		fmt.Println("\nBackup header:")
+		table.Backup.DebugDump(os.Stdout)
+	case *format == "gdisk":
+		size, err := dev.Seek(0, io.SeekEnd)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		showGdisk(disk, uint64(size)/table.BlockSize(), table)
+	default:
+		log.Fatalf("Unknown format %q", *format)
+	}
+}
+
+// The data passed to a --template for each partition.
+type templateEntry struct {
+	gpt.GPTPartitionEntry
+
+	// The index of the partition in the table.
+	Index int
+
+	// The partition name and the human readable name of its type.
+	Name, TypeName string
+
+	// The names of the attributes which are set.
+	AttributeNames []string
+
+	// The table that the partition is in.
+	Table *gpt.Table
+}
+
+// Prints each partition in the table with the template t, adding a newline
+// if t doesn't print one.
+func showTemplate(t *template.Template, table *gpt.Table) {
+	var buf bytes.Buffer
+	for i, p := range table.Entries {
+		if p.PartitionType.IsZero() {
+			continue
+		}
+		buf.Reset()
+		err := t.Execute(&buf, templateEntry{
+			GPTPartitionEntry: p,
+			Index:             i,
+			Name:              p.GetName(),
+			TypeName:          p.PartitionType.HumanString(),
+			AttributeNames:    p.Attributes.Names(p.PartitionType),
+			Table:             table,
+		})
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		os.Stdout.Write(buf.Bytes())
+	}
+}
+
+// Prints the table in this tool's native format.
+func showDefault(table *gpt.Table) {
+	fmt.Printf("%11s %11s %5s %s\n", "Start", "Size", "Index", "Contents")
+	for i, p := range table.Entries {
+		if p.PartitionType.IsZero() {
+			continue
+		}
+		contents := p.PartitionType.HumanString()
+		if name := p.GetName(); name != "" {
+			contents += fmt.Sprintf(" (Part name: %s)", name)
+		}
+		if attrs := p.Attributes.Names(p.PartitionType); len(attrs) > 0 {
+			contents += fmt.Sprintf(" [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Printf("%11d %11d %5d %s\n", p.StartingLBA, p.Size(), i, contents)
+	}
+}
+
+// Prints the table in the same format as "sgdisk -p" or "gdisk -l", for a disk
+// which is diskSize blocks long.
+func showGdisk(disk string, diskSize uint64, table *gpt.Table) {
+	h := table.Primary
+	bs := table.BlockSize()
+	fmt.Printf("Disk %s: %d sectors, %s\n", disk, diskSize, ieeeSize(diskSize*bs))
+	fmt.Printf("Sector size (logical): %d bytes\n", bs)
+	fmt.Printf("Disk identifier (GUID): %s\n", h.Disk)
+	fmt.Printf("Partition table holds up to %d entries\n", h.MaxNumberPartitionEntries)
+	arrayBlocks := gpt.BytesToLBAs(uint64(h.MaxNumberPartitionEntries)*uint64(h.SizeOfPartitionEntry), bs)
+	fmt.Printf("Main partition table begins at sector %d and ends at sector %d\n", h.PartitionEntryLBA, h.PartitionEntryLBA+arrayBlocks-1)
+	fmt.Printf("First usable sector is %d, last usable sector is %d\n", h.FirstUseableLBA, h.LastUseableLBA)
+
+	// gdisk reports the largest power of two up to 1MiB that all
+	// partitions start on, and the sum of all unallocated blocks in the
+	// usable area.
+	align := (1 << 20) / bs
+	used := uint64(0)
+	for _, p := range table.Entries {
+		if p.PartitionType.IsZero() {
+			continue
+		}
+		for align > 1 && p.StartingLBA%align != 0 {
+			align /= 2
+		}
+		used += p.LBACount()
+	}
+	// Overlapping partitions, or partitions outside of the usable area,
+	// can use more blocks than there are.
+	free := uint64(0)
+	if usable := gpt.RangeLength(h.FirstUseableLBA, h.LastUseableLBA); usable > used {
+		free = usable - used
+	}
+	fmt.Printf("Partitions will be aligned on %d-sector boundaries\n", align)
+	fmt.Printf("Total free space is %d sectors (%s)\n", free, ieeeSize(free*bs))
+
+	fmt.Printf("\nNumber  Start (sector)    End (sector)  Size       Code  Name\n")
+	for i, p := range table.Entries {
+		if p.PartitionType.IsZero() {
+			continue
+		}
+		size := ieeeSize(p.SizeBytes(bs))
+		name := p.GetName()
+		if utf8.RuneCountInString(name) > 22 {
+			name = string([]rune(name)[:22])
+		}
+		fmt.Printf("%4d  %14d  %14d   %-10s  %04X  %s\n", i+1, p.StartingLBA, p.EndingLBA, size, p.PartitionType.GdiskCode(), name)
+	}
+}
+
+// Formats a size in bytes the same way as gdisk, using the largest binary
+// unit that keeps the value at or below 1024.
+func ieeeSize(bytes uint64) string {
+	// gdisk does this calculation with single precision floats, so do the
+	// same to get the same rounding.
+	size := float32(bytes)
+	prefixes := " KMGTPEZ"
+	i := 0
+	for size > 1024 && i < len(prefixes)-1 {
+		i++
+		size /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f bytes", size)
+	}
+	return fmt.Sprintf("%.1f %ciB", size, prefixes[i])
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Erases the contents of the partition given by args.
+func shred(disk string, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing partition index")
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("Invalid partition index %q", args[0])
+	}
+	flags := flag.NewFlagSet("shred", flag.ExitOnError)
+	passes := flags.Int("passes", 1, "the number of passes of random data to overwrite the partition with")
+	discard := flags.Bool("discard", false, "discard the partition's blocks instead of overwriting them")
+	crypto := flags.Bool("crypto", false, "destroy the partition's LUKS header instead of overwriting it")
+	progress := flags.Bool("progress", false, "print the progress of erasing the partition to stderr")
+	force := forceOption(flags, "erase the partition even if it's in use or contains a filesystem")
+	flags.Parse(args[1:])
+	if *discard && *crypto {
+		log.Fatalln("Only one of --discard and --crypto can be given")
+	}
+
+	f, dev := openDisk(disk, os.O_RDWR)
+	defer f.Close()
+
+	table, err := readTable(dev)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if index >= 0 && index < len(table.Entries) {
+		checkModify(disk, dev, table, *force, true, index)
+	}
+	if *progress {
+		table.Progress = printProgress(os.Stderr)
+	}
+	switch {
+	case *discard:
+		err = table.DiscardPartition(dev, index)
+	case *crypto:
+		err = table.DestroyLUKSHeader(dev, index)
+	default:
+		err = table.ShredPartition(dev, index, *passes)
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+}
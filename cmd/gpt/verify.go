@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// The names of the -level options, in the order of gpt.VerifyLevel.
+var verifyLevels = []string{"header", "checksums", "backup", "partitions", "contents", "deep"}
+
+// Returns the VerifyLevel named s, which is one of verifyLevels.
+func parseVerifyLevel(s string) (gpt.VerifyLevel, bool) {
+	for i, l := range verifyLevels {
+		if l == s {
+			return gpt.VerifyLevel(i), true
+		}
+	}
+	return 0, false
+}
+
+// Verifies the table on disk, with the checks requested by args.
+func verify(disk string, args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	names := flags.Bool("names", false, "also check for partition names that may cause problems")
+	fixGUIDs := flags.Bool("fix-guids", false, "replace zero and duplicate GUIDs with new random GUIDs")
+	repair := flags.Bool("repair", false, "apply the suggested repairs for checksum errors")
+	progress := flags.Bool("progress", false, "print the progress of reading partitions to stderr")
+	strict := flags.Bool("strict", false, "treat warnings as errors")
+	level := flags.String("level", "partitions", "how thoroughly to check the disk")
+	deep := flags.Bool("deep", false, "read every block of every partition (the same as --level deep)")
+	freeSpace := flags.Bool("free-space", false, "with --deep, also read the free space")
+	roundTrip := flags.Bool("round-trip", false, "also check that writing the table back unmodified would not change any sectors")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+	if *deep {
+		*level = "deep"
+	}
+
+	opts := gpt.VerifyOptions{Names: *names, FreeSpace: *freeSpace}
+	if *progress {
+		opts.Progress = printProgress(os.Stderr)
+	}
+	var ok bool
+	if opts.Level, ok = parseVerifyLevel(*level); !ok {
+		log.Fatalf("Invalid level \"%v\"", *level)
+	}
+
+	mode := os.O_RDONLY
+	if *fixGUIDs || *repair {
+		mode = os.O_RDWR
+	}
+	f, dev := openDisk(disk, mode)
+	defer f.Close()
+
+	if *fixGUIDs {
+		table, err := readTable(dev)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		n, err := table.RegenerateGUIDs()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if n > 0 {
+			if err := table.Write(dev); err != nil {
+				log.Fatalln(err.Error())
+			}
+			if err := f.Sync(); err != nil {
+				log.Fatalln(err.Error())
+			}
+			fmt.Printf("Regenerated %d GUIDs.\n", n)
+		}
+	}
+
+	report := gpt.Verify(dev, opts)
+	if repairs := report.Repairs(); *repair && len(repairs) > 0 {
+		if err := gpt.ApplyRepairs(dev, repairs); err != nil {
+			log.Fatalln(err.Error())
+		}
+		if err := f.Sync(); err != nil {
+			log.Fatalln(err.Error())
+		}
+		fmt.Printf("Applied repairs: %v\n", repairs)
+		report = gpt.Verify(dev, opts)
+	}
+	if *roundTrip && report.Err() == nil {
+		if err := gpt.RoundTripCheck(dev); err != nil {
+			report.Findings = append(report.Findings, gpt.Finding{Severity: gpt.SeverityError, Err: err, Index: -1})
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalln(err.Error())
+		}
+	} else {
+		fmt.Print(report)
+	}
+	if report.Err() != nil || (*strict && len(report.Findings) > 0) {
+		os.Exit(1)
+	}
+	if *asJSON {
+		return
+	}
+	fmt.Printf("GPT appears to be valid.\n")
+	if table, err := readTable(dev); err == nil {
+		for _, hint := range table.DPSHints() {
+			fmt.Printf("Hint: %s\n", hint)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/driusan/gpt"
+)
+
+// Prints a line whenever the table on disk changes.
+func watch(disk string, args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	once := flags.Bool("once", false, "exit after the first change")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	events, err := gpt.Watch(ctx, disk)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	for ev := range events {
+		now := time.Now().Format(time.RFC3339)
+		if ev.Err != nil {
+			fmt.Printf("%s error %v\n", now, ev.Err)
+		} else {
+			fmt.Printf("%s changed disk %s, %d partitions\n", now, ev.Table.Primary.Disk, countPartitions(ev.Table))
+		}
+		if *once {
+			if ev.Err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+}
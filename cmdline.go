@@ -0,0 +1,15 @@
+package gpt
+
+// Returns the kernel command line fragment which mounts the partition at
+// index of the table as the root filesystem (ie. "root=PARTUUID=..."), for
+// use by bootloader configuration generators. The PARTUUID is lower case, as
+// it is reported by blkid.
+//
+// An empty string is returned if index does not refer to a partition which
+// is in use.
+func (t *Table) RootCmdline(index int) string {
+	if index < 0 || index >= len(t.Entries) || t.Entries[index].PartitionType.IsZero() {
+		return ""
+	}
+	return "root=" + partUUID(t.Entries[index])
+}
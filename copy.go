@@ -0,0 +1,94 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+)
+
+// CopyOptions control how CopyPartitionWithOptions copies a partition.
+type CopyOptions struct {
+	// If set, called with the progress of the copy.
+	Progress ProgressFunc
+
+	// If set, all-zero chunks of the source and holes in a source file
+	// aren't written to the destination. Instead, holes are punched in a
+	// destination file, or zeros are written to any part of it which isn't
+	// already a hole. This makes copying a mostly empty partition much
+	// faster, and keeps image files sparse.
+	Sparse bool
+
+	// If non-zero, the maximum bytes per second to copy.
+	RateLimit uint64
+
+	// If set, the progress of the copy is recorded in a journal file at
+	// this path. If the journal already exists, it must be for the same
+	// copy, which is resumed from where the journal says it was
+	// interrupted. The journal is removed when the copy is complete.
+	Journal string
+}
+
+// Copies the contents of the partition at srcIdx of the GPT on srcDev to the
+// partition at dstIdx of the GPT on dstDev, which must be at least as large.
+// If the source partition is smaller, the rest of the destination partition
+// is left untouched. dstDev is synced before returning if it has a Sync
+// method (ie. it's an *os.File.)
+func CopyPartition(srcDev io.ReadSeeker, srcIdx int, dstDev io.ReadWriteSeeker, dstIdx int) error {
+	return CopyPartitionWithOptions(srcDev, srcIdx, dstDev, dstIdx, CopyOptions{})
+}
+
+// Copies a partition like CopyPartition, with the given options.
+func CopyPartitionWithOptions(srcDev io.ReadSeeker, srcIdx int, dstDev io.ReadWriteSeeker, dstIdx int, opts CopyOptions) error {
+	srcTable, err := ReadTable(srcDev)
+	if err != nil {
+		return wrapf(CodeIO, err, "Could not read source GPT: %w", err)
+	}
+	dstTable, err := ReadTable(dstDev)
+	if err != nil {
+		return wrapf(CodeIO, err, "Could not read destination GPT: %w", err)
+	}
+	src, err := srcTable.PartitionView(srcDev, srcIdx)
+	if err != nil {
+		return wrapf(CodeInvalidArgument, err, "Source: %w", err)
+	}
+	dst, err := dstTable.PartitionView(dstDev, dstIdx)
+	if err != nil {
+		return wrapf(CodeInvalidArgument, err, "Destination: %w", err)
+	}
+	if src.size > dst.size {
+		return errorf(CodeNoFreeSpace, "Source partition %d (%d bytes) does not fit in destination partition %d (%d bytes)", srcIdx, src.size, dstIdx, dst.size)
+	}
+
+	var j *Journal
+	if opts.Journal != "" {
+		se, de := srcTable.Entries[srcIdx], dstTable.Entries[dstIdx]
+		j, err = resumeJournal(opts.Journal, &Journal{
+			Operation: JournalCopy,
+			SrcIndex:  srcIdx,
+			DstIndex:  dstIdx,
+			SrcStart:  se.StartingLBA,
+			SrcEnd:    se.EndingLBA,
+			DstStart:  de.StartingLBA,
+			DstEnd:    de.EndingLBA,
+		})
+		if err != nil {
+			return err
+		}
+		defer j.close()
+	}
+
+	p := newProgress(opts.Progress, uint64(src.size))
+	defer p.finish()
+	p.phase(fmt.Sprintf("copying partition %d to partition %d", srcIdx, dstIdx))
+	c := &rangeCopy{srcDev, src, dstDev, dst, opts.Sparse, p, newRateLimiter(opts.RateLimit), j, false}
+	var from int64
+	if j != nil {
+		from = int64(j.Done)
+	}
+	if err := c.run(from); err != nil {
+		return wrapf(CodeIO, err, "Could not copy partition %d: %w", srcIdx, err)
+	}
+	if j != nil {
+		return j.remove()
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A range of logical blocks, and what they're used for.
+type blockRange struct {
+	first, last uint64
+	desc        string
+}
+
+// Reads every block of every partition in use, and of the free space if
+// opts.FreeSpace is set, returning an error for each run of blocks which
+// can't be read.
+func (t *Table) verifyDeep(hd io.ReadSeeker, opts VerifyOptions) []error {
+	var ranges []blockRange
+	for i, e := range t.Entries {
+		if !e.PartitionType.IsZero() && e.StartingLBA <= e.EndingLBA {
+			ranges = append(ranges, blockRange{e.StartingLBA, e.EndingLBA, fmt.Sprintf("partition %d", i)})
+		}
+	}
+	if opts.FreeSpace {
+		ranges = append(ranges, t.freeRanges(ranges)...)
+	}
+	bs := t.BlockSize()
+	var total uint64
+	for _, r := range ranges {
+		total += RangeLength(r.first, r.last) * bs
+	}
+
+	var errs []error
+	p := newProgress(opts.Progress, total)
+	defer p.finish()
+	buf := make([]byte, copyBufferSize)
+	for _, r := range ranges {
+		p.phase("reading " + r.desc)
+		readBlocks(hd, r.first, r.last, bs, buf, p, func(first, last uint64, err error) {
+			if first == last {
+				errs = append(errs, errorf(CodeUnreadableBlock, "Could not read LBA %d of %s: %w", first, r.desc, err))
+			} else {
+				errs = append(errs, errorf(CodeUnreadableBlock, "Could not read LBA %d-%d of %s: %w", first, last, r.desc, err))
+			}
+		})
+	}
+	return errs
+}
+
+// Returns the ranges of the usable area which aren't in used.
+func (t *Table) freeRanges(used []blockRange) []blockRange {
+	sorted := append([]blockRange(nil), used...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].first < sorted[j].first })
+	var free []blockRange
+	next := t.Primary.FirstUseableLBA
+	for _, r := range append(sorted, blockRange{first: t.Primary.LastUseableLBA + 1}) {
+		if r.first > next {
+			free = append(free, blockRange{next, r.first - 1, "free space"})
+		}
+		if r.last+1 > next {
+			next = r.last + 1
+		}
+	}
+	return free
+}
+
+// Reads blocks first to last of hd, which has logical blocks of bs bytes,
+// with buf, calling bad for each run of consecutive blocks which can't be
+// read. Blocks are read a buffer at a time, and one at a time only if the
+// buffer can't be read.
+func readBlocks(hd io.ReadSeeker, first, last, bs uint64, buf []byte, p *progress, bad func(first, last uint64, err error)) {
+	var runFirst, runLast uint64
+	var runErr error
+	flush := func() {
+		if runErr != nil {
+			bad(runFirst, runLast, runErr)
+			runErr = nil
+		}
+	}
+	for lba := first; lba <= last; {
+		n := min(uint64(len(buf))/bs, last-lba+1)
+		if readBlocksAt(hd, lba, bs, buf[:n*bs]) == nil {
+			flush()
+			p.add(n * bs)
+			lba += n
+			continue
+		}
+		for end := lba + n; lba < end; lba++ {
+			if err := readBlocksAt(hd, lba, bs, buf[:bs]); err != nil {
+				if runErr == nil {
+					runFirst, runErr = lba, err
+				}
+				runLast = lba
+			} else {
+				flush()
+			}
+			p.add(bs)
+		}
+	}
+	flush()
+}
+
+// Reads len(buf) bytes from hd starting at the logical block lba, for logical
+// blocks of bs bytes.
+func readBlocksAt(hd io.ReadSeeker, lba, bs uint64, buf []byte) error {
+	if _, err := hd.Seek(int64(lba*bs), io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(hd, buf)
+	return err
+}
@@ -0,0 +1,19 @@
+package gpt
+
+import (
+	"io"
+)
+
+// A BlockDevice is a disk, or the virtual disk inside a disk image, that a
+// partition table can be read from and written to. *os.File is a
+// BlockDevice, as are the images provided by the qcow2 and vmdk packages.
+type BlockDevice interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.WriterAt
+
+	// Flushes any writes to stable storage.
+	Sync() error
+
+	Close() error
+}
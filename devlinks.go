@@ -0,0 +1,75 @@
+package gpt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// The directories in which udev creates symlinks for GPT partitions on Linux.
+const (
+	ByPartUUIDDir  = "/dev/disk/by-partuuid"
+	ByPartLabelDir = "/dev/disk/by-partlabel"
+)
+
+// A DevLink is a symlink which udev creates for a partition.
+type DevLink struct {
+	// The path of the symlink.
+	Path string
+
+	// The device node that the symlink resolves to, or an empty string if
+	// the symlink does not exist.
+	Target string
+}
+
+// Returns the paths of the symlinks which udev creates for the partition on
+// Linux: one in ByPartUUIDDir, and one in ByPartLabelDir if the partition has
+// a name.
+func (e GPTPartitionEntry) DevLinks() []string {
+	links := []string{
+		filepath.Join(ByPartUUIDDir, strings.ToLower(e.UniqueParitition.String())),
+	}
+	if name := e.GetName(); name != "" {
+		links = append(links, filepath.Join(ByPartLabelDir, udevEncode(name)))
+	}
+	return links
+}
+
+// Resolves the symlinks returned by DevLinks on the running system. Links
+// which don't exist are returned with an empty Target.
+func (e GPTPartitionEntry) ResolveDevLinks() ([]DevLink, error) {
+	var links []DevLink
+	for _, path := range e.DevLinks() {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			target = ""
+		}
+		links = append(links, DevLink{path, target})
+	}
+	return links, nil
+}
+
+// Encodes s the same way udev (via libblkid) does for symlink names.
+// Characters other than ASCII alphanumerics, "#+-.:=@_" and valid multibyte
+// UTF-8 sequences are replaced by a \xNN escape.
+func udevEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case size > 1:
+			b.WriteString(s[i : i+size])
+		case r < utf8.RuneSelf && (r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || strings.ContainsRune("#+-.:=@_", r)):
+			b.WriteByte(s[i])
+		default:
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package gpt
+
+import (
+	"crypto"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The namespaces of DFXML, and of the elements which record GPT specific
+// details that DFXML has no elements for.
+const (
+	dfxmlNamespace = "http://www.forensicswiki.org/wiki/Category:Digital_Forensics_XML"
+	dfxmlDC        = "http://purl.org/dc/elements/1.1/"
+	dfxmlGPT       = "https://github.com/driusan/gpt"
+)
+
+type dfxmlDocument struct {
+	XMLName         xml.Name             `xml:"dfxml"`
+	Namespace       string               `xml:"xmlns,attr"`
+	DC              string               `xml:"xmlns:dc,attr"`
+	GPT             string               `xml:"xmlns:gpt,attr"`
+	Version         string               `xml:"version,attr"`
+	Type            string               `xml:"metadata>dc:type"`
+	Program         string               `xml:"creator>program"`
+	Source          dfxmlSource          `xml:"source"`
+	PartitionSystem dfxmlPartitionSystem `xml:"partitionsystem"`
+}
+
+type dfxmlSource struct {
+	Filename   string `xml:"image_filename,omitempty"`
+	SectorSize uint64 `xml:"sectorsize"`
+	Size       uint64 `xml:"image_size"`
+}
+
+type dfxmlPartitionSystem struct {
+	Offset     uint64           `xml:"offset,attr"`
+	Type       string           `xml:"pstype_str"`
+	GUID       GUID             `xml:"guid"`
+	Primary    dfxmlHeader      `xml:"gpt:primary_header"`
+	Backup     dfxmlHeader      `xml:"gpt:backup_header"`
+	Partitions []dfxmlPartition `xml:"partition"`
+}
+
+type dfxmlHeader struct {
+	Offset          uint64 `xml:"offset,attr"`
+	Revision        string `xml:"gpt:revision"`
+	HeaderSize      uint32 `xml:"gpt:header_size"`
+	HeaderCRC32     string `xml:"gpt:header_crc32"`
+	MyLBA           uint64 `xml:"gpt:my_lba"`
+	AltLBA          uint64 `xml:"gpt:alternate_lba"`
+	FirstUsableLBA  uint64 `xml:"gpt:first_usable_lba"`
+	LastUsableLBA   uint64 `xml:"gpt:last_usable_lba"`
+	EntryLBA        uint64 `xml:"gpt:partition_entry_lba"`
+	Entries         uint32 `xml:"gpt:number_of_partition_entries"`
+	EntrySize       uint32 `xml:"gpt:size_of_partition_entry"`
+	EntryArrayCRC32 string `xml:"gpt:partition_entry_array_crc32"`
+}
+
+type dfxmlPartition struct {
+	Index      int         `xml:"partition_index"`
+	Type       GUID        `xml:"ptype_str"`
+	TypeName   string      `xml:"gpt:type_name"`
+	Label      string      `xml:"partition_label,omitempty"`
+	GUID       GUID        `xml:"guid"`
+	Attributes string      `xml:"gpt:attributes"`
+	FirstLBA   uint64      `xml:"gpt:first_lba"`
+	LastLBA    uint64      `xml:"gpt:last_lba"`
+	ByteRun    dfxmlRun    `xml:"byte_runs>byte_run"`
+	Hashes     []dfxmlHash `xml:"hashdigest"`
+}
+
+type dfxmlRun struct {
+	Offset uint64 `xml:"img_offset,attr"`
+	Length uint64 `xml:"len,attr"`
+}
+
+type dfxmlHash struct {
+	Type   string `xml:"type,attr"`
+	Digest string `xml:",chardata"`
+}
+
+// Returns the header h, of a disk with logical blocks of bs bytes, in DFXML.
+func newDFXMLHeader(h GPTHeader, bs uint64) dfxmlHeader {
+	return dfxmlHeader{
+		Offset:          h.MyLBA * bs,
+		Revision:        fmt.Sprintf("%#08x", h.Revision),
+		HeaderSize:      h.HeaderSize,
+		HeaderCRC32:     fmt.Sprintf("%#08x", h.HeaderCRC32),
+		MyLBA:           h.MyLBA,
+		AltLBA:          h.AltLBA,
+		FirstUsableLBA:  h.FirstUseableLBA,
+		LastUsableLBA:   h.LastUseableLBA,
+		EntryLBA:        h.PartitionEntryLBA,
+		Entries:         h.MaxNumberPartitionEntries,
+		EntrySize:       h.SizeOfPartitionEntry,
+		EntryArrayCRC32: fmt.Sprintf("%#08x", h.PartitionEntryArrayCRC32),
+	}
+}
+
+// Writes a Digital Forensics XML (DFXML) report of the table, which was read
+// from dev, to w. The report records the size of dev, both headers and every
+// used partition entry, with the byte offset and length of each partition
+// in the image. source is recorded as the image's file name, if it isn't
+// empty.
+//
+// If any hashes are given, the contents of each partition are hashed with
+// them, which reads every partition. The hash functions must be linked into
+// the binary, as for HashPartition.
+func (t *Table) WriteDFXML(w io.Writer, dev io.ReadSeeker, source string, hashes ...crypto.Hash) error {
+	size, err := deviceSize(dev)
+	if err != nil {
+		return err
+	}
+	doc := dfxmlDocument{
+		Namespace: dfxmlNamespace,
+		DC:        dfxmlDC,
+		GPT:       dfxmlGPT,
+		Version:   "1.2.0",
+		Type:      "Disk Image",
+		Program:   "gpt",
+		Source: dfxmlSource{
+			Filename:   source,
+			SectorSize: t.BlockSize(),
+			Size:       size,
+		},
+		PartitionSystem: dfxmlPartitionSystem{
+			Type:    "gpt",
+			GUID:    t.Primary.Disk,
+			Primary: newDFXMLHeader(t.Primary, t.BlockSize()),
+			Backup:  newDFXMLHeader(t.Backup, t.BlockSize()),
+		},
+	}
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		p := dfxmlPartition{
+			Index:      i,
+			Type:       e.PartitionType,
+			TypeName:   e.PartitionType.HumanString(),
+			Label:      e.GetName(),
+			GUID:       e.UniqueParitition,
+			Attributes: fmt.Sprintf("%#016x", uint64(e.Attributes)),
+			FirstLBA:   e.StartingLBA,
+			LastLBA:    e.EndingLBA,
+			ByteRun:    dfxmlRun{e.StartOffsetBytes(t.BlockSize()), e.SizeBytes(t.BlockSize())},
+		}
+		if len(hashes) > 0 {
+			sums, err := t.hashPartition(dev, i, hashes)
+			if err != nil {
+				return err
+			}
+			for j, h := range hashes {
+				name := strings.ToLower(strings.ReplaceAll(h.String(), "-", ""))
+				p.Hashes = append(p.Hashes, dfxmlHash{name, hex.EncodeToString(sums[j])})
+			}
+		}
+		doc.PartitionSystem.Partitions = append(doc.PartitionSystem.Partitions, p)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
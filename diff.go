@@ -0,0 +1,167 @@
+package gpt
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// A Difference is a single difference between two partition tables, as
+// returned by DiffTables.
+type Difference struct {
+	// The index of the partition entry which differs, or -1 for a
+	// difference between the headers.
+	Index int
+
+	// The header field or partition field (ie. "name") which differs. If
+	// empty, a partition was added (Old is empty) or removed (New is
+	// empty), and Old or New describes the partition.
+	Field string
+
+	// The old and new values.
+	Old, New string
+}
+
+// Returns a line describing the difference, starting with "+" for an added
+// partition, "-" for a removed partition, or "~" for a change.
+func (d Difference) String() string {
+	switch {
+	case d.Index < 0:
+		return fmt.Sprintf("~ header %s: %s -> %s", d.Field, d.Old, d.New)
+	case d.Field != "":
+		return fmt.Sprintf("~ partition %d %s: %s -> %s", d.Index, d.Field, d.Old, d.New)
+	case d.Old == "":
+		return fmt.Sprintf("+ partition %d: %s", d.Index, d.New)
+	default:
+		return fmt.Sprintf("- partition %d: %s", d.Index, d.Old)
+	}
+}
+
+// Returns the differences between the tables a and b. The headers are
+// compared by the fields which describe the table (the checksums and the
+// fields which are derived from the primary header are not compared), and
+// the partitions are compared by their index, so a partition which moved to
+// another entry is reported as removed and added.
+func DiffTables(a, b *Table) []Difference {
+	var diffs []Difference
+	field := func(name string, old, new any) {
+		o, n := fmt.Sprint(old), fmt.Sprint(new)
+		if o != n {
+			diffs = append(diffs, Difference{-1, name, o, n})
+		}
+	}
+
+	ha, hb := a.Primary, b.Primary
+	field("disk GUID", ha.Disk, hb.Disk)
+	field("revision", fmt.Sprintf("%#08x", ha.Revision), fmt.Sprintf("%#08x", hb.Revision))
+	field("backup LBA", ha.AltLBA, hb.AltLBA)
+	field("first usable LBA", ha.FirstUseableLBA, hb.FirstUseableLBA)
+	field("last usable LBA", ha.LastUseableLBA, hb.LastUseableLBA)
+	field("partition entry LBA", ha.PartitionEntryLBA, hb.PartitionEntryLBA)
+	field("partition entries", ha.MaxNumberPartitionEntries, hb.MaxNumberPartitionEntries)
+	field("partition entry size", ha.SizeOfPartitionEntry, hb.SizeOfPartitionEntry)
+
+	for i := 0; i < max(len(a.Entries), len(b.Entries)); i++ {
+		var ea, eb GPTPartitionEntry
+		if i < len(a.Entries) {
+			ea = a.Entries[i]
+		}
+		if i < len(b.Entries) {
+			eb = b.Entries[i]
+		}
+		switch {
+		case ea.PartitionType.IsZero() && eb.PartitionType.IsZero():
+		case ea.PartitionType.IsZero():
+			diffs = append(diffs, Difference{Index: i, New: describeEntry(eb)})
+		case eb.PartitionType.IsZero():
+			diffs = append(diffs, Difference{Index: i, Old: describeEntry(ea)})
+		default:
+			diffs = append(diffs, diffEntries(i, ea, eb)...)
+		}
+	}
+	return diffs
+}
+
+// Returns true if t and other are identical: both headers, including their
+// checksums, and every partition entry are the same, as are the bytes which
+// are written after the entry arrays. Writing either table would write the
+// same blocks. What the tables were read from, and settings such as their
+// Logger, aren't compared.
+func (t *Table) Equal(other *Table) bool {
+	return t.BlockSize() == other.BlockSize() &&
+		t.Primary == other.Primary && t.Backup == other.Backup &&
+		slices.Equal(t.Entries, other.Entries) &&
+		sameTail(t.primaryTail, other.primaryTail) && sameTail(t.backupTail, other.backupTail)
+}
+
+// Returns true if the bytes after the end of an entry array, a and b, would
+// be written the same. A table which wasn't read from a disk has no tail, and
+// zeros are written instead.
+func sameTail(a, b []byte) bool {
+	switch {
+	case a == nil:
+		return isZero(b)
+	case b == nil:
+		return isZero(a)
+	}
+	return bytes.Equal(a, b)
+}
+
+// Returns true if t and other describe the same layout: the same usable area,
+// and partitions in use at the same locations with the same types and
+// attributes. The disk GUID, the partitions' unique GUIDs and names, which
+// entries the partitions are in, and the size and location of the entry
+// arrays aren't compared.
+func (t *Table) EquivalentLayout(other *Table) bool {
+	return t.Primary.FirstUseableLBA == other.Primary.FirstUseableLBA &&
+		t.Primary.LastUseableLBA == other.Primary.LastUseableLBA &&
+		slices.Equal(t.layout(), other.layout())
+}
+
+// Returns the partitions in use, without their unique GUIDs and names, in
+// order of their location.
+func (t *Table) layout() []GPTPartitionEntry {
+	var l []GPTPartitionEntry
+	for _, e := range t.Used() {
+		e.UniqueParitition, e.PartitionName = ZeroGUID, [36]uint16{}
+		l = append(l, e)
+	}
+	slices.SortFunc(l, func(a, b GPTPartitionEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.StartingLBA, b.StartingLBA),
+			cmp.Compare(a.EndingLBA, b.EndingLBA),
+			a.PartitionType.Compare(b.PartitionType),
+			cmp.Compare(a.Attributes, b.Attributes),
+		)
+	})
+	return l
+}
+
+// Returns the differences between the fields of the partition entries a and
+// b, which are both at index.
+func diffEntries(index int, a, b GPTPartitionEntry) []Difference {
+	var diffs []Difference
+	field := func(name string, old, new any) {
+		o, n := fmt.Sprint(old), fmt.Sprint(new)
+		if o != n {
+			diffs = append(diffs, Difference{index, name, o, n})
+		}
+	}
+	field("type", a.PartitionType.HumanString(), b.PartitionType.HumanString())
+	field("GUID", a.UniqueParitition, b.UniqueParitition)
+	field("start LBA", a.StartingLBA, b.StartingLBA)
+	field("end LBA", a.EndingLBA, b.EndingLBA)
+	field("attributes", fmt.Sprintf("%#016x", uint64(a.Attributes)), fmt.Sprintf("%#016x", uint64(b.Attributes)))
+	field("name", fmt.Sprintf("%q", a.GetName()), fmt.Sprintf("%q", b.GetName()))
+	return diffs
+}
+
+// Returns a one line description of the partition e.
+func describeEntry(e GPTPartitionEntry) string {
+	s := fmt.Sprintf("%s at LBA %d-%d", e.PartitionType.HumanString(), e.StartingLBA, e.EndingLBA)
+	if name := e.GetName(); name != "" {
+		s += fmt.Sprintf(" (Part name: %s)", name)
+	}
+	return s
+}
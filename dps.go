@@ -0,0 +1,244 @@
+package gpt
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Partition types defined by the Discoverable Partitions Specification which
+// are not architecture specific. systemd-gpt-auto-generator mounts partitions
+// of these types automatically.
+var (
+	DPSExtendedBoot = registerType("BC13C2FF-59E6-4262-A352-B275FD6F7172", "Linux extended boot", 0xEA00, OSLinux, CategoryBoot)
+	DPSHome         = registerType("933AC7E1-2EB4-4F13-B844-0E14E2AEF915", "Linux /home", 0x8302, OSLinux, CategoryData)
+	DPSSrv          = registerType("3B8F8425-20E0-4F3B-907F-1A25A76F98E8", "Linux /srv", 0x8306, OSLinux, CategoryData)
+	DPSVar          = registerType("4D21B016-B534-45C2-A9FB-5C16E091FD2D", "Linux /var", 0x8310, OSLinux, CategoryData)
+	DPSTmp          = registerType("7EC6F557-3BC5-4ACA-B293-16EF5DF639D1", "Linux /var/tmp", 0x8311, OSLinux, CategoryData)
+	DPSUserHome     = registerType("773F91EF-66D4-49B5-BD83-D683BF40AD16", "Linux user's home", 0x8312, OSLinux, CategoryData)
+
+	// Swap is identified by the standard Linux swap type.
+	DPSSwap = LinuxSwap
+)
+
+// Attribute bits defined by the Discoverable Partitions Specification.
+const (
+	// The partition is mounted read only.
+	DPSReadOnly = GPTPartitionAttribute(1 << 60)
+
+	// The filesystem should be grown to the size of the partition
+	// when it's first mounted.
+	DPSGrowFS = GPTPartitionAttribute(1 << 59)
+
+	// The partition is not mounted automatically.
+	DPSNoAuto = GPTPartitionAttribute(1 << 63)
+)
+
+// The architecture specific partition types defined by the Discoverable
+// Partitions Specification.
+type dpsArch struct {
+	root, usr GUID
+}
+
+// The architecture specific types, keyed by the architecture identifiers
+// used by systemd.
+var dpsArches = map[string]dpsArch{
+	"alpha":       dpsArchTypes("alpha", "6523F8AE-3EB1-4E2A-A05A-18B695AE656F", "E18CF08C-33EC-4C0D-8246-C6C6FB3DA024", 0, 0),
+	"arc":         dpsArchTypes("arc", "D27F46ED-2919-4CB8-BD25-9531F3C16534", "7978A683-6316-4922-BBEE-38BFF5A2FECC", 0, 0),
+	"arm":         dpsArchTypes("arm", "69DAD710-2CE4-4E3C-B16C-21A1D49ABED3", "7D0359A3-02B3-4F0A-865C-654403E70625", 0x8307, 0),
+	"arm64":       dpsArchTypes("arm64", "B921B045-1DF0-41C3-AF44-4C6F280D3FAE", "B0E01050-EE5F-4390-949A-9101B17104E9", 0x8305, 0),
+	"ia64":        dpsArchTypes("ia64", "993D8D3D-F80E-4225-855A-9DAF8ED7EA97", "4301D2A6-4E3B-4B2A-BB94-9E0B2C4225EA", 0x830A, 0),
+	"loongarch64": dpsArchTypes("loongarch64", "77055800-792C-4F94-B39A-98C91B762BB6", "E611C702-575C-4CBE-9A46-434FA0BF7E3F", 0, 0),
+	"mips-le":     dpsArchTypes("mips-le", "37C58C8A-D913-4156-A25F-48B1B64E07F0", "0F4868E9-9952-4706-979F-3ED3A473E947", 0, 0),
+	"mips64-le":   dpsArchTypes("mips64-le", "700BDA43-7A34-4507-B179-EEB93D7A7CA3", "C97C1F32-BA06-40B4-9F22-236061B08AA8", 0, 0),
+	"parisc":      dpsArchTypes("parisc", "1AACDB3B-5444-4138-BD9E-E5C2239B2346", "DC4A4480-6917-4262-A4EC-DB9384949F25", 0, 0),
+	"ppc":         dpsArchTypes("ppc", "1DE3F1EF-FA98-47B5-8DCD-4A860A654D78", "7D14FEC5-CC71-415D-9D6C-06BF0B3C3EAF", 0, 0),
+	"ppc64":       dpsArchTypes("ppc64", "912ADE1D-A839-4913-8964-A10EEE08FBD2", "2C9739E2-F068-46B3-9FD0-01C5A9AFBCCA", 0, 0),
+	"ppc64-le":    dpsArchTypes("ppc64-le", "C31C45E6-3F39-412E-80FB-4809C4980599", "15BB03AF-77E7-4D4A-B12B-C0D084F7491C", 0, 0),
+	"riscv32":     dpsArchTypes("riscv32", "60D5A7FE-8E7D-435C-B714-3DD8162144E1", "B933FB22-5C3F-4F91-AF90-E2BB0FA50702", 0, 0),
+	"riscv64":     dpsArchTypes("riscv64", "72EC70A6-CF74-40E6-BD49-4BDA08E8F224", "BEAEC34B-8442-439B-A40B-984381ED097D", 0, 0),
+	"s390":        dpsArchTypes("s390", "08A7ACEA-624C-4A20-91E8-6E0FA67D23F9", "CD0F869B-D0FB-4CA0-B141-9EA87CC78D66", 0, 0),
+	"s390x":       dpsArchTypes("s390x", "5EEAD9A9-FE09-4A1E-A1D7-520D00531306", "8A4F5770-50AA-4ED3-874A-99B710DB6FEA", 0, 0),
+	"tilegx":      dpsArchTypes("tilegx", "C50CDD70-3862-4CC3-90E1-809A8C93EE2C", "55497029-C7C1-44CC-AA39-815ED1558630", 0, 0),
+	"x86":         dpsArchTypes("x86", "44479540-F297-41B2-9AF7-D131D5F0458A", "75250D76-8CC6-458E-BD66-BD47CC81A812", 0x8303, 0),
+	"x86-64":      dpsArchTypes("x86-64", "4F68BCE3-E8CD-4DB1-96E7-FBCAF984B709", "8484680C-9521-48C6-9C11-B0720656F69E", 0x8304, 0),
+}
+
+// The dm-verity hash partition types for each architecture's root and /usr
+// partitions, keyed by the architecture identifiers used by systemd.
+var dpsVerityArches = map[string]dpsArch{
+	"alpha":       dpsVerityTypes("alpha", "FC56D9E9-E6E5-4C06-BE32-E74407CE09A5", "8CCE0D25-C0D0-4A44-BD87-46331BF1DF67"),
+	"arc":         dpsVerityTypes("arc", "24B2D975-0F97-4521-AFA1-CD531E421B8D", "FCA0598C-D880-4591-8C16-4EDA05C7347C"),
+	"arm":         dpsVerityTypes("arm", "7386CDF2-203C-47A9-A498-F2ECCE45A2D6", "C215D751-7BCD-4649-BE90-6627490A4C05"),
+	"arm64":       dpsVerityTypes("arm64", "DF3300CE-D69F-4C92-978C-9BFB0F38D820", "6E11A4E7-FBCA-4DED-B9E9-E1A512BB664E"),
+	"ia64":        dpsVerityTypes("ia64", "86ED10D5-B607-45BB-8957-D350F23D0571", "6A491E03-3BE7-4545-8E38-83320E0EA880"),
+	"loongarch64": dpsVerityTypes("loongarch64", "F3393B22-E9AF-4613-A948-9D3BFBD0C535", "F46B2C26-59AE-48F0-9106-C50ED47F673D"),
+	"mips-le":     dpsVerityTypes("mips-le", "D7D150D2-2A04-4A33-8F12-16651205FF7B", "46B98D8D-B55C-4E8F-AAB3-37FCA7F80752"),
+	"mips64-le":   dpsVerityTypes("mips64-le", "16B417F8-3E06-4F57-8DD2-9B5232F41AA6", "3C3D61FE-B5F3-414D-BB71-8739A694A4EF"),
+	"parisc":      dpsVerityTypes("parisc", "D212A430-FBC5-49F9-A983-A7FEEF2B8D0E", "5843D618-EC37-48D7-9F12-CEA8E08768B2"),
+	"ppc":         dpsVerityTypes("ppc", "98CFE649-1588-46DC-B2F0-ADD147424925", "DF765D00-270E-49E5-BC75-F47BB2118B09"),
+	"ppc64":       dpsVerityTypes("ppc64", "9225A9A3-3C19-4D89-B4F6-EEFF88F17631", "BDB528A5-A259-475F-A87D-DA53FA736A07"),
+	"ppc64-le":    dpsVerityTypes("ppc64-le", "906BD944-4589-4AAE-A4E4-DD983917446A", "EE2B9983-21E8-4153-86D9-B6901A54D1CE"),
+	"riscv32":     dpsVerityTypes("riscv32", "AE0253BE-1167-4007-AC68-43926C14C5DE", "CB1EE4E3-8CD0-4136-A0A4-AA61A32E8730"),
+	"riscv64":     dpsVerityTypes("riscv64", "B6ED5582-440B-4209-B8DA-5FF7C419EA3D", "8F1056BE-9B05-47C4-81D6-BE53128E5B54"),
+	"s390":        dpsVerityTypes("s390", "7AC63B47-B25C-463B-8DF8-B4A94E6C90E1", "B663C618-E7BC-4D6D-90AA-11B756BB1797"),
+	"s390x":       dpsVerityTypes("s390x", "B325BFBE-C7BE-4AB8-8357-139E652D2F6B", "31741CC4-1A2A-4111-A581-E00B447D2D06"),
+	"tilegx":      dpsVerityTypes("tilegx", "966061EC-28E4-4B2E-B4A5-1F0A825A1D84", "2FB4BF56-07FA-42DA-8132-6B139F2015F0"),
+	"x86":         dpsVerityTypes("x86", "D13C5D3B-B5D1-422A-B29F-9454FDC89D76", "8F461B0D-14EE-4E81-9AA9-049B6FB97ABD"),
+	"x86-64":      dpsVerityTypes("x86-64", "2C7357ED-EBD2-46D9-AEC1-23D437EC2BF5", "77FF5F63-E7B6-4633-ACF4-1565B864C0E6"),
+}
+
+// Registers the root and /usr dm-verity partition types for an architecture.
+func dpsVerityTypes(arch, root, usr string) dpsArch {
+	return dpsArch{
+		root: registerType(root, fmt.Sprintf("Linux root verity (%s)", arch), 0, OSLinux, CategoryVerity),
+		usr:  registerType(usr, fmt.Sprintf("Linux /usr verity (%s)", arch), 0, OSLinux, CategoryVerity),
+	}
+}
+
+// Maps the GOARCH names used by Go to the architecture identifiers used by
+// systemd, where they're different.
+var goarchToDPS = map[string]string{
+	"386":      "x86",
+	"amd64":    "x86-64",
+	"mipsle":   "mips-le",
+	"mips64le": "mips64-le",
+	"ppc64le":  "ppc64-le",
+}
+
+// Registers the root and /usr partition types for an architecture.
+func dpsArchTypes(arch, root, usr string, rootGdisk, usrGdisk uint16) dpsArch {
+	return dpsArch{
+		root: registerType(root, fmt.Sprintf("Linux root (%s)", arch), rootGdisk, OSLinux, CategorySystem),
+		usr:  registerType(usr, fmt.Sprintf("Linux /usr (%s)", arch), usrGdisk, OSLinux, CategorySystem),
+	}
+}
+
+// Returns the architecture identifier used by systemd for arch, which may
+// be either a systemd identifier or a GOARCH value.
+func dpsArchName(arch string) string {
+	if name, ok := goarchToDPS[arch]; ok {
+		return name
+	}
+	return arch
+}
+
+// Returns the Discoverable Partitions Specification root partition type for
+// arch, which may be either a systemd architecture identifier (ie. "x86-64")
+// or a GOARCH value (ie. "amd64").
+func DPSRootForArch(arch string) (GUID, bool) {
+	a, ok := dpsArches[dpsArchName(arch)]
+	return a.root, ok
+}
+
+// Returns the Discoverable Partitions Specification /usr partition type for
+// arch, which may be either a systemd architecture identifier (ie. "x86-64")
+// or a GOARCH value (ie. "amd64").
+func DPSUsrForArch(arch string) (GUID, bool) {
+	a, ok := dpsArches[dpsArchName(arch)]
+	return a.usr, ok
+}
+
+// Returns the dm-verity partition type for the root partition of arch,
+// which may be either a systemd architecture identifier or a GOARCH value.
+func DPSRootVerityForArch(arch string) (GUID, bool) {
+	a, ok := dpsVerityArches[dpsArchName(arch)]
+	return a.root, ok
+}
+
+// Returns the dm-verity partition type for the /usr partition of arch,
+// which may be either a systemd architecture identifier or a GOARCH value.
+func DPSUsrVerityForArch(arch string) (GUID, bool) {
+	a, ok := dpsVerityArches[dpsArchName(arch)]
+	return a.usr, ok
+}
+
+// Returns the Discoverable Partitions Specification root partition type for
+// the architecture that this program is running on.
+func DPSRoot() (GUID, bool) {
+	return DPSRootForArch(runtime.GOARCH)
+}
+
+// Returns the mount point that systemd-gpt-auto-generator uses for
+// partitions of type t, and the architecture the partition is for if it's
+// architecture specific.
+func dpsMountPoint(t GUID) (mnt, arch string) {
+	switch t {
+	case EFISystemPartition:
+		return "/efi", ""
+	case DPSExtendedBoot:
+		return "/boot", ""
+	case DPSHome:
+		return "/home", ""
+	case DPSSrv:
+		return "/srv", ""
+	case DPSVar:
+		return "/var", ""
+	case DPSTmp:
+		return "/var/tmp", ""
+	case DPSSwap:
+		return "swap", ""
+	}
+	for name, a := range dpsArches {
+		switch t {
+		case a.root:
+			return "/", name
+		case a.usr:
+			return "/usr", name
+		}
+	}
+	return "", ""
+}
+
+// Returns hints describing how systemd-gpt-auto-generator will treat the
+// partitions in the table under the Discoverable Partitions Specification.
+// No hints are returned unless at least one partition has a type specific to
+// the specification (the EFI System Partition and swap types are used by
+// many layouts which weren't designed for it.)
+//
+// The hints are informational, and do not indicate that the table is invalid.
+func (t *Table) DPSHints() []string {
+	var hints []string
+	matches := false
+	mounted := make(map[string]int)
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		mnt, arch := dpsMountPoint(e.PartitionType)
+		if mnt == "" {
+			continue
+		}
+		if e.Attributes&DPSNoAuto != 0 {
+			hints = append(hints, fmt.Sprintf("Partition %d (%s) has the no-auto attribute and will not be mounted automatically", i, mnt))
+			continue
+		}
+		if mnt != "/efi" && mnt != "swap" {
+			matches = true
+		}
+		key := mnt + " " + arch
+		if j, ok := mounted[key]; ok {
+			hints = append(hints, fmt.Sprintf("Partition %d (%s) will be ignored, partition %d is used instead", i, mnt, j))
+			continue
+		}
+		mounted[key] = i
+
+		hint := fmt.Sprintf("Partition %d will be mounted at %s", i, mnt)
+		if mnt == "swap" {
+			hint = fmt.Sprintf("Partition %d will be used as swap", i)
+		}
+		if arch != "" {
+			hint += fmt.Sprintf(" on %s systems", arch)
+		}
+		if e.Attributes&DPSReadOnly != 0 {
+			hint += " (read only)"
+		}
+		if e.Attributes&DPSGrowFS != 0 {
+			hint += " (filesystem will be grown)"
+		}
+		hints = append(hints, hint)
+	}
+	if !matches {
+		return nil
+	}
+	return hints
+}
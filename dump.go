@@ -0,0 +1,55 @@
+package gpt
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Returns every field of the header, one per line, with numbers in both
+// decimal and hex and GUIDs decoded, similar to the header details shown by
+// gdisk's expert mode.
+func (g GPTHeader) String() string {
+	var b strings.Builder
+	field := func(name, format string, args ...any) {
+		fmt.Fprintf(&b, "%-28s "+format+"\n", append([]any{name + ":"}, args...)...)
+	}
+	crc := "invalid header size"
+	if validHeaderSize(g, LogicalBlockSize) {
+		crc = "OK"
+		if want := g.computeCRC(); want != g.HeaderCRC32 {
+			crc = fmt.Sprintf("should be %#08x", want)
+		}
+	}
+	field("Signature", "%q (%#016x)", g.Signature[:], binary.LittleEndian.Uint64(g.Signature[:]))
+	field("Revision", "%#08x (%d.%d)", g.Revision, g.Revision>>16, g.Revision&0xffff)
+	field("Header size", "%d (%#x)", g.HeaderSize, g.HeaderSize)
+	field("Header CRC32", "%#08x (%s)", g.HeaderCRC32, crc)
+	field("Reserved", "%d (%#x)", g.Reserved, g.Reserved)
+	field("Current LBA", "%d (%#x)", g.MyLBA, g.MyLBA)
+	field("Backup LBA", "%d (%#x)", g.AltLBA, g.AltLBA)
+	field("First usable LBA", "%d (%#x)", g.FirstUseableLBA, g.FirstUseableLBA)
+	field("Last usable LBA", "%d (%#x)", g.LastUseableLBA, g.LastUseableLBA)
+	field("Disk GUID", "%s", g.Disk)
+	field("Partition entry LBA", "%d (%#x)", g.PartitionEntryLBA, g.PartitionEntryLBA)
+	field("Number of partition entries", "%d (%#x)", g.MaxNumberPartitionEntries, g.MaxNumberPartitionEntries)
+	field("Size of partition entry", "%d (%#x)", g.SizeOfPartitionEntry, g.SizeOfPartitionEntry)
+	field("Partition entry array CRC32", "%#08x", g.PartitionEntryArrayCRC32)
+	return b.String()
+}
+
+// Writes the fields of the header to w as String does, followed by a hex dump
+// of the header as it's encoded on disk, up to its HeaderSize.
+func (g GPTHeader) DebugDump(w io.Writer) error {
+	if _, err := io.WriteString(w, g.String()); err != nil {
+		return err
+	}
+	raw := g.encode()
+	if validHeaderSize(g, LogicalBlockSize) {
+		raw = raw[:g.HeaderSize]
+	}
+	_, err := io.WriteString(w, hex.Dump(raw))
+	return err
+}
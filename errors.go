@@ -0,0 +1,196 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An ErrorCode identifies the kind of an error returned by this package, or
+// of a verification Finding, independently of its message. Codes are stable
+// between versions, so programs can map them to their own taxonomy (ie. of
+// alerts) rather than parsing the messages, which may change.
+//
+// Every code has a sentinel error, such as ErrOverlap for CodeOverlap, which
+// errors.Is matches against any error with that code. Errors which don't
+// come from this package, such as I/O errors, have the code CodeUnknown.
+type ErrorCode string
+
+const (
+	CodeUnknown ErrorCode = "unknown"
+
+	// Errors from operations which modify the table.
+	CodeReadOnly        ErrorCode = "read-only"
+	CodeNoChange        ErrorCode = "no-change"
+	CodeDestructive     ErrorCode = "destructive"
+	CodeBusy            ErrorCode = "busy"
+	CodeSignature       ErrorCode = "signature"
+	CodeNotInUse        ErrorCode = "not-in-use"
+	CodeNoFreeSpace     ErrorCode = "no-free-space"
+	CodeNoFreeEntries   ErrorCode = "no-free-entries"
+	CodeDeviceTooSmall  ErrorCode = "device-too-small"
+	CodeInvalidArgument ErrorCode = "invalid-argument"
+	CodeRoundTrip       ErrorCode = "round-trip"
+	CodeJournal         ErrorCode = "journal"
+
+	// Problems with the headers and entry arrays.
+	CodeInvalidHeader   ErrorCode = "invalid-header"
+	CodeHeaderRevision  ErrorCode = "header-revision"
+	CodeHeaderCRC       ErrorCode = "header-crc"
+	CodeArrayCRC        ErrorCode = "array-crc"
+	CodeEntryArraySize  ErrorCode = "entry-array-size"
+	CodeBackupOnly      ErrorCode = "backup-only"
+	CodeBackupMismatch  ErrorCode = "backup-mismatch"
+	CodeBackupLocation  ErrorCode = "backup-location"
+	CodePlacement       ErrorCode = "placement"
+	CodeBlockSize       ErrorCode = "block-size"
+	CodeMBR             ErrorCode = "mbr"
+	CodeUnreadableBlock ErrorCode = "unreadable-block"
+
+	// Problems with the partitions.
+	CodeUnknownType   ErrorCode = "unknown-type"
+	CodeMisaligned    ErrorCode = "misaligned"
+	CodeInvalidRange  ErrorCode = "invalid-range"
+	CodeOutsideUsable ErrorCode = "outside-usable-area"
+	CodeOverlap       ErrorCode = "overlap"
+	CodeGUID          ErrorCode = "guid"
+	CodeName          ErrorCode = "name"
+
+	// Errors from devices and the operating system.
+	CodeIO          ErrorCode = "io"
+	CodeUnsupported ErrorCode = "unsupported"
+	CodeNotFound    ErrorCode = "not-found"
+)
+
+// The sentinel errors for each ErrorCode.
+var (
+	// ErrReadOnly is returned by methods which would modify a table that
+	// was read from a disk opened with Open.
+	ErrReadOnly = sentinel(CodeReadOnly, "Partition table is read only")
+
+	// ErrNoChange is returned by operations which would not modify the
+	// table.
+	ErrNoChange = sentinel(CodeNoChange, "No change required")
+
+	// ErrDestructive is returned by ApplyLayout when converging the table
+	// to the layout would delete or shrink a partition, and pruning wasn't
+	// allowed.
+	ErrDestructive = sentinel(CodeDestructive, "Layout requires deleting or shrinking partitions")
+
+	ErrBusy            = sentinel(CodeBusy, "Partition is in use")
+	ErrSignature       = sentinel(CodeSignature, "Partition contains a filesystem")
+	ErrNotInUse        = sentinel(CodeNotInUse, "Partition is not in use")
+	ErrNoFreeSpace     = sentinel(CodeNoFreeSpace, "No free space")
+	ErrNoFreeEntries   = sentinel(CodeNoFreeEntries, "No unused partition entries")
+	ErrDeviceTooSmall  = sentinel(CodeDeviceTooSmall, "Device is too small")
+	ErrInvalidArgument = sentinel(CodeInvalidArgument, "Invalid argument")
+	ErrRoundTrip       = sentinel(CodeRoundTrip, "Writing the table back would change it")
+	ErrJournal         = sentinel(CodeJournal, "Invalid journal")
+
+	ErrInvalidHeader   = sentinel(CodeInvalidHeader, "Invalid GPT header")
+	ErrHeaderRevision  = sentinel(CodeHeaderRevision, "Non-standard GPT header revision")
+	ErrHeaderCRC       = sentinel(CodeHeaderCRC, "Incorrect GPT header CRC")
+	ErrArrayCRC        = sentinel(CodeArrayCRC, "Incorrect partition entry array CRC")
+	ErrEntryArraySize  = sentinel(CodeEntryArraySize, "Invalid partition entry array size")
+	ErrBackupOnly      = sentinel(CodeBackupOnly, "Table can only be read from the backup header")
+	ErrBackupMismatch  = sentinel(CodeBackupMismatch, "Backup header does not match primary")
+	ErrBackupLocation  = sentinel(CodeBackupLocation, "Backup header is in the wrong place")
+	ErrPlacement       = sentinel(CodePlacement, "Partition entry array is in the wrong place")
+	ErrBlockSize       = sentinel(CodeBlockSize, "Unsupported logical block size")
+	ErrMBR             = sentinel(CodeMBR, "Problem with the MBR")
+	ErrUnreadableBlock = sentinel(CodeUnreadableBlock, "Block can not be read")
+
+	ErrUnknownType   = sentinel(CodeUnknownType, "Unknown partition type")
+	ErrMisaligned    = sentinel(CodeMisaligned, "Partition is misaligned")
+	ErrInvalidRange  = sentinel(CodeInvalidRange, "Partition ends before it starts")
+	ErrOutsideUsable = sentinel(CodeOutsideUsable, "Partition is outside of the usable area")
+	ErrOverlap       = sentinel(CodeOverlap, "Partitions overlap")
+	ErrGUID          = sentinel(CodeGUID, "Invalid GUID")
+	ErrName          = sentinel(CodeName, "Problematic partition name")
+
+	// ErrIO is returned when a device, image or file can't be read or
+	// written. The error that caused it is wrapped.
+	ErrIO          = sentinel(CodeIO, "Input/output error")
+	ErrUnsupported = sentinel(CodeUnsupported, "Not supported")
+	ErrNotFound    = sentinel(CodeNotFound, "Device not found")
+)
+
+// An error with an ErrorCode.
+type codedError struct {
+	code ErrorCode
+	err  error
+
+	// The index of the partition entry that the error is about, or -1.
+	index int
+}
+
+// Returns a sentinel error with code and the message msg.
+func sentinel(code ErrorCode, msg string) error {
+	return &codedError{code, errors.New(msg), -1}
+}
+
+// Returns an error with code, formatted like fmt.Errorf, so it may wrap
+// another error with %w.
+func errorf(code ErrorCode, format string, args ...any) error {
+	return &codedError{code, fmt.Errorf(format, args...), -1}
+}
+
+// Returns an error formatted like errorf which adds context to err (which
+// the format should wrap with %w), keeping the code and partition entry of
+// err if it has them. Otherwise the error has code.
+func wrapf(code ErrorCode, err error, format string, args ...any) error {
+	if c := CodeOf(err); c != CodeUnknown {
+		code = c
+	}
+	return &codedError{code, fmt.Errorf(format, args...), entryOf(err)}
+}
+
+// Returns an error with code about the partition entry at index, formatted
+// like errorf.
+func entryErrorf(index int, code ErrorCode, format string, args ...any) error {
+	return &codedError{code, fmt.Errorf(format, args...), index}
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Returns the error's code.
+func (e *codedError) Code() ErrorCode {
+	return e.code
+}
+
+// Returns the error wrapped by the message, if any.
+func (e *codedError) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
+// Returns true if target is an error with the same code, so that errors.Is
+// matches the code's sentinel error.
+func (e *codedError) Is(target error) bool {
+	t, ok := target.(*codedError)
+	return ok && t.code == e.code
+}
+
+func (e *codedError) entry() int {
+	return e.index
+}
+
+// Returns the index of the partition entry that err is about, or -1 if it
+// isn't about a single entry.
+func entryOf(err error) int {
+	var e interface{ entry() int }
+	if errors.As(err, &e) {
+		return e.entry()
+	}
+	return -1
+}
+
+// Returns the code of err, or of the first error that it wraps which has one,
+// or CodeUnknown if none do.
+func CodeOf(err error) ErrorCode {
+	var c interface{ Code() ErrorCode }
+	if errors.As(err, &c) {
+		return c.Code()
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,32 @@
+package gpt
+
+// The minimum recommended size of an EFI System Partition, in bytes. Smaller
+// partitions can't hold a FAT32 filesystem on all disks, and some firmware
+// refuses to boot from them.
+const ESPMinSize uint64 = 100 << 20
+
+// The alignment, in bytes, of the start and size of an EFI System Partition.
+// Aligning to 1MiB keeps the FAT clusters aligned with the physical sectors
+// of any disk.
+const ESPAlignment uint64 = 1 << 20
+
+// Adds an EFI System Partition of at least size bytes to the table. The size
+// is rounded up to a multiple of ESPAlignment, and the partition is marked as
+// required by the platform.
+//
+// Returns the index of the new entry in t.Entries.
+func (t *Table) AddESP(size uint64) (int, error) {
+	if err := t.checkWritable(); err != nil {
+		return -1, err
+	}
+	if size < ESPMinSize {
+		return -1, errorf(CodeInvalidArgument, "EFI System Partition must be at least %d bytes.", ESPMinSize)
+	}
+	size = alignUp(size, ESPAlignment)
+	i, err := t.AddPartition(EFISystemPartition, size, ESPAlignment/t.BlockSize())
+	if err != nil {
+		return -1, err
+	}
+	t.Entries[i].Attributes |= GPTPartitionSystem
+	return i, nil
+}
@@ -0,0 +1,167 @@
+package gpt
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"sort"
+	"unicode/utf16"
+)
+
+// An ESPInfo describes an EFI System Partition found by FindESPs.
+type ESPInfo struct {
+	// The whole disk device that the partition is on.
+	Disk string
+
+	// The index of the partition in the disk's table.
+	Index int
+
+	// The partition entry.
+	Entry GPTPartitionEntry
+
+	// True if the partition contains a FAT filesystem. An ESP without a
+	// FAT filesystem can't be used by the firmware.
+	FAT bool
+
+	// The position in the firmware's BootOrder of the first boot entry
+	// which loads from this partition, or -1 if no boot entry does.
+	BootOrder int
+
+	// The description of that boot entry (ie. "Linux Boot Manager".)
+	BootEntry string
+}
+
+// A firmware boot entry which loads from a GPT partition.
+type efiBootEntry struct {
+	partition   GUID
+	description string
+}
+
+// Scans all disks on the machine for EFI System Partitions.
+//
+// The partitions are returned sorted by the firmware boot order, with
+// partitions that are not referenced by any boot entry following in order of
+// disk and partition index. Disks which can't be read, or which don't have a
+// GPT, are skipped.
+func FindESPs(ctx context.Context) ([]ESPInfo, error) {
+	disks, err := listDisks()
+	if err != nil {
+		return nil, err
+	}
+	// The boot order is only used for ranking, so machines without EFI
+	// variables just have unranked ESPs.
+	bootOrder, _ := efiBootOrder()
+
+	var esps []ESPInfo
+	for _, disk := range disks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := findDiskESPs(disk, bootOrder)
+		if err != nil {
+			continue
+		}
+		esps = append(esps, found...)
+	}
+	sort.SliceStable(esps, func(i, j int) bool {
+		a, b := esps[i], esps[j]
+		if a.BootOrder != b.BootOrder {
+			if a.BootOrder < 0 || b.BootOrder < 0 {
+				return b.BootOrder < 0
+			}
+			return a.BootOrder < b.BootOrder
+		}
+		if a.Disk != b.Disk {
+			return a.Disk < b.Disk
+		}
+		return a.Index < b.Index
+	})
+	return esps, nil
+}
+
+// Finds the ESPs on a single disk. bootOrder is the list of firmware boot
+// entries, in boot order.
+func findDiskESPs(disk string, bootOrder []efiBootEntry) ([]ESPInfo, error) {
+	f, err := os.Open(disk)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := ReadTable(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var esps []ESPInfo
+	for i, e := range t.Entries {
+		if e.PartitionType != EFISystemPartition {
+			continue
+		}
+		fstype, err := t.ProbeFilesystem(f, i)
+		if err != nil {
+			return nil, err
+		}
+		info := ESPInfo{Disk: disk, Index: i, Entry: e, FAT: fstype == "vfat", BootOrder: -1}
+		for pos, b := range bootOrder {
+			if b.partition == e.UniqueParitition {
+				info.BootOrder = pos
+				info.BootEntry = b.description
+				break
+			}
+		}
+		esps = append(esps, info)
+	}
+	return esps, nil
+}
+
+// Returns the partition GUID from the first hard drive media device path in
+// an EFI_LOAD_OPTION (the contents of a Boot#### EFI variable), if any.
+func loadOptionPartition(opt []byte) (GUID, bool) {
+	// UINT32 Attributes, UINT16 FilePathListLength, followed by a null
+	// terminated UTF-16 description.
+	if len(opt) < 6 {
+		return ZeroGUID, false
+	}
+	pathLen := int(binary.LittleEndian.Uint16(opt[4:6]))
+	i := 6
+	for ; i+1 < len(opt); i += 2 {
+		if opt[i] == 0 && opt[i+1] == 0 {
+			i += 2
+			break
+		}
+	}
+	if i+pathLen > len(opt) {
+		return ZeroGUID, false
+	}
+	path := opt[i : i+pathLen]
+
+	// Walk the device path nodes, looking for a hard drive media device
+	// path (type 4, subtype 1) with a GUID signature.
+	for len(path) >= 4 {
+		typ, subtype := path[0], path[1]
+		n := int(binary.LittleEndian.Uint16(path[2:4]))
+		if typ == 0x7F || n < 4 || n > len(path) {
+			break
+		}
+		if typ == 4 && subtype == 1 && n >= 42 && path[41] == 2 {
+			var sig [16]byte
+			copy(sig[:], path[24:40])
+			return GUIDFromEFIBytes(sig), true
+		}
+		path = path[n:]
+	}
+	return ZeroGUID, false
+}
+
+// Returns the description of an EFI_LOAD_OPTION.
+func loadOptionDescription(opt []byte) string {
+	var desc []uint16
+	for i := 6; i+1 < len(opt); i += 2 {
+		c := binary.LittleEndian.Uint16(opt[i:])
+		if c == 0 {
+			break
+		}
+		desc = append(desc, c)
+	}
+	return string(utf16.Decode(desc))
+}
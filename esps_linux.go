@@ -0,0 +1,70 @@
+package gpt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The directory that Linux exposes EFI variables in, and the vendor GUID of
+// the global variables which define the boot entries.
+const (
+	efivarsDir    = "/sys/firmware/efi/efivars"
+	efiGlobalGUID = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+)
+
+// Returns the device nodes of all whole disks on the machine, sorted by name.
+func listDisks() ([]string, error) {
+	entries, err := os.ReadDir("/sys/block")
+	if err != nil {
+		return nil, err
+	}
+	var disks []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		if size, err := readSysUint(filepath.Join("/sys/block", name, "size")); err != nil || size == 0 {
+			continue
+		}
+		disks = append(disks, filepath.Join("/dev", name))
+	}
+	sort.Strings(disks)
+	return disks, nil
+}
+
+// Reads an EFI variable, discarding the attributes.
+func readEFIVar(name string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(efivarsDir, name+"-"+efiGlobalGUID))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, errorf(CodeIO, "EFI variable %v too short", name)
+	}
+	return b[4:], nil
+}
+
+// Returns the firmware boot entries which load from a GPT partition, in the
+// order given by the BootOrder EFI variable.
+func efiBootOrder() ([]efiBootEntry, error) {
+	order, err := readEFIVar("BootOrder")
+	if err != nil {
+		return nil, err
+	}
+	var entries []efiBootEntry
+	for i := 0; i+1 < len(order); i += 2 {
+		opt, err := readEFIVar(fmt.Sprintf("Boot%04X", binary.LittleEndian.Uint16(order[i:])))
+		if err != nil {
+			continue
+		}
+		if g, ok := loadOptionPartition(opt); ok {
+			entries = append(entries, efiBootEntry{g, loadOptionDescription(opt)})
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package gpt
+
+// BUG(driusan): FindESPs and TakeInventory are only implemented on Linux.
+func listDisks() ([]string, error) {
+	return nil, errorf(CodeUnsupported, "Listing disks is not supported on this operating system")
+}
+
+func efiBootOrder() ([]efiBootEntry, error) {
+	return nil, errorf(CodeUnsupported, "EFI variables are not supported on this operating system")
+}
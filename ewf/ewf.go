@@ -0,0 +1,353 @@
+// Package ewf provides read only access to the disk inside an Expert Witness
+// Format (EWF, or EnCase) forensic image, so that the GPT of an imaged disk
+// can be inspected without converting the image.
+//
+// An Image implements gpt.BlockDevice, so it can be passed to any function in
+// package gpt which expects a block device. Writes return gpt.ErrReadOnly.
+//
+// BUG(driusan): Only EWF version 1 (.E01) images are supported, not EWF2
+// (.Ex01) images, and segment files are only found up to .E99.
+package ewf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/driusan/gpt"
+)
+
+// The signature at the start of every EWF segment file.
+const Signature = "EVF\x09\x0d\x0a\xff\x00"
+
+// The header at the start of a segment file.
+type fileHeader struct {
+	Signature   [8]byte
+	FieldsStart uint8
+	Segment     uint16
+	FieldsEnd   uint16
+}
+
+// The descriptor at the start of every section of a segment file.
+type sectionDescriptor struct {
+	Type     [16]byte
+	Next     uint64
+	Size     uint64
+	_        [40]byte
+	Checksum uint32
+}
+
+// The start of the volume (or disk) section, which describes the media.
+type volume struct {
+	MediaType       uint8
+	_               [3]byte
+	Chunks          uint32
+	SectorsPerChunk uint32
+	BytesPerSector  uint32
+	Sectors         uint64
+}
+
+// The header of a table section, which is followed by the table entries.
+type tableHeader struct {
+	Entries    uint32
+	_          [4]byte
+	BaseOffset uint64
+	_          [4]byte
+	Checksum   uint32
+}
+
+// The location of a chunk of the media in the segment files.
+type chunk struct {
+	segment    int
+	offset     int64
+	size       int64
+	compressed bool
+}
+
+// An Image is an open EWF image, which may be split across several segment
+// files.
+type Image struct {
+	segments  []*os.File
+	chunks    []chunk
+	chunkSize int64
+	size      int64
+
+	// The contents of the most recently read chunk, and its index.
+	cached      []byte
+	cachedChunk int
+
+	pos int64
+}
+
+var _ gpt.BlockDevice = (*Image)(nil)
+
+// The EWF format, for registering with gpt.RegisterImageFormat.
+var Format = gpt.ImageFormat{
+	Name: "ewf",
+	Match: func(r io.ReaderAt, size int64) bool {
+		magic := make([]byte, len(Signature))
+		_, err := r.ReadAt(magic, 0)
+		return err == nil && string(magic) == Signature
+	},
+	Open: func(path string, rw bool) (gpt.BlockDevice, error) {
+		if rw {
+			return nil, gpt.ErrReadOnly
+		}
+		return Open(path)
+	},
+}
+
+// Opens the EWF image whose first segment file is path (normally a .E01
+// file). The other segment files must be in the same directory, with the
+// same name and the extensions .E02, .E03 and so on.
+func Open(path string) (*Image, error) {
+	img := &Image{cachedChunk: -1}
+	for seg := 1; ; seg++ {
+		name, err := segmentName(path, seg)
+		if err != nil {
+			img.Close()
+			return nil, err
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			img.Close()
+			return nil, err
+		}
+		img.segments = append(img.segments, f)
+		done, err := img.readSegment(f, seg)
+		if err != nil {
+			img.Close()
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		if done {
+			break
+		}
+	}
+	if img.chunkSize == 0 {
+		img.Close()
+		return nil, fmt.Errorf("%v: no volume section", path)
+	}
+	if want := (img.size + img.chunkSize - 1) / img.chunkSize; int64(len(img.chunks)) < want {
+		img.Close()
+		return nil, fmt.Errorf("%v: image has %d chunks, expected %d", path, len(img.chunks), want)
+	}
+	return img, nil
+}
+
+// Returns the name of segment seg of the image whose first segment is path.
+func segmentName(path string, seg int) (string, error) {
+	if seg == 1 {
+		return path, nil
+	}
+	if seg > 99 || len(path) < 4 || !strings.EqualFold(path[len(path)-4:], ".e01") {
+		return "", fmt.Errorf("Can not find segment %d of %v", seg, path)
+	}
+	return fmt.Sprintf("%s%02d", path[:len(path)-2], seg), nil
+}
+
+// Reads the sections of the segment file f, which should be segment number
+// seg. Returns true if it's the last segment.
+func (img *Image) readSegment(f *os.File, seg int) (bool, error) {
+	var fh fileHeader
+	if err := binary.Read(io.NewSectionReader(f, 0, 13), binary.LittleEndian, &fh); err != nil {
+		return false, err
+	}
+	if string(fh.Signature[:]) != Signature {
+		return false, fmt.Errorf("not an EWF segment file")
+	}
+	if int(fh.Segment) != seg {
+		return false, fmt.Errorf("segment number %d, expected %d", fh.Segment, seg)
+	}
+
+	// The end of the most recent sectors section, which is where the last
+	// chunk of the table which follows it ends.
+	var sectorsEnd int64
+	for off := int64(13); ; {
+		raw := make([]byte, 76)
+		if _, err := f.ReadAt(raw, off); err != nil {
+			return false, fmt.Errorf("could not read section at offset %d: %v", off, err)
+		}
+		var d sectionDescriptor
+		binary.Read(bytes.NewReader(raw), binary.LittleEndian, &d)
+		if adler32.Checksum(raw[:72]) != d.Checksum {
+			return false, fmt.Errorf("invalid checksum for section at offset %d", off)
+		}
+		data := io.NewSectionReader(f, off+76, max(int64(d.Size)-76, 0))
+
+		switch typ := string(bytes.TrimRight(d.Type[:], "\x00")); typ {
+		case "volume", "disk":
+			if img.chunkSize != 0 {
+				break
+			}
+			var v volume
+			if err := binary.Read(data, binary.LittleEndian, &v); err != nil {
+				return false, fmt.Errorf("could not read volume section: %v", err)
+			}
+			if v.SectorsPerChunk == 0 || v.BytesPerSector == 0 {
+				return false, fmt.Errorf("invalid volume section")
+			}
+			img.chunkSize = int64(v.SectorsPerChunk) * int64(v.BytesPerSector)
+			img.size = int64(v.Sectors) * int64(v.BytesPerSector)
+		case "sectors":
+			sectorsEnd = off + int64(d.Size)
+		case "table":
+			if err := img.readTable(data, seg-1, sectorsEnd); err != nil {
+				return false, err
+			}
+		case "next":
+			return false, nil
+		case "done":
+			return true, nil
+		}
+		if d.Next <= uint64(off) {
+			return false, fmt.Errorf("section at offset %d has no next section", off)
+		}
+		off = int64(d.Next)
+	}
+}
+
+// Reads the table section data, which is in segment, adding its entries to
+// the image's chunks. The last chunk in the table ends at sectorsEnd.
+func (img *Image) readTable(data *io.SectionReader, segment int, sectorsEnd int64) error {
+	var h tableHeader
+	if err := binary.Read(data, binary.LittleEndian, &h); err != nil {
+		return fmt.Errorf("could not read table section: %v", err)
+	}
+	entries := make([]uint32, h.Entries)
+	if err := binary.Read(data, binary.LittleEndian, entries); err != nil {
+		return fmt.Errorf("could not read table section: %v", err)
+	}
+	for i, e := range entries {
+		c := chunk{
+			segment:    segment,
+			offset:     int64(h.BaseOffset) + int64(e&0x7FFFFFFF),
+			compressed: e&0x80000000 != 0,
+		}
+		end := sectorsEnd
+		if i+1 < len(entries) {
+			end = int64(h.BaseOffset) + int64(entries[i+1]&0x7FFFFFFF)
+		}
+		if end <= c.offset {
+			return fmt.Errorf("invalid table entry %d", i)
+		}
+		c.size = end - c.offset
+		img.chunks = append(img.chunks, c)
+	}
+	return nil
+}
+
+// Closes the segment files.
+func (img *Image) Close() error {
+	var err error
+	for _, f := range img.segments {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Returns the size of the imaged media in bytes.
+func (img *Image) Size() int64 {
+	return img.size
+}
+
+// Returns the contents of chunk i, decompressing it if necessary.
+func (img *Image) readChunk(i int) ([]byte, error) {
+	if i == img.cachedChunk {
+		return img.cached, nil
+	}
+	c := img.chunks[i]
+	want := min(img.chunkSize, img.size-int64(i)*img.chunkSize)
+	r := io.NewSectionReader(img.segments[c.segment], c.offset, c.size)
+	buf := make([]byte, want)
+	if c.compressed {
+		z, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decompress chunk %d: %v", i, err)
+		}
+		if _, err := io.ReadFull(z, buf); err != nil {
+			return nil, fmt.Errorf("Could not decompress chunk %d: %v", i, err)
+		}
+	} else {
+		// Uncompressed chunks are followed by their Adler-32
+		// checksum.
+		var sum uint32
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("Could not read chunk %d: %v", i, err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &sum); err == nil && sum != adler32.Checksum(buf) {
+			return nil, fmt.Errorf("Invalid checksum for chunk %d", i)
+		}
+	}
+	img.cached, img.cachedChunk = buf, i
+	return buf, nil
+}
+
+// Reads len(p) bytes of the imaged media starting at off.
+func (img *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= img.size {
+			return n, io.EOF
+		}
+		data, err := img.readChunk(int(pos / img.chunkSize))
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos%img.chunkSize:])
+	}
+	return n, nil
+}
+
+// Returns gpt.ErrReadOnly, since EWF images can't be modified.
+func (img *Image) WriteAt(p []byte, off int64) (int, error) {
+	return 0, gpt.ErrReadOnly
+}
+
+// Reads from the current position in the imaged media.
+func (img *Image) Read(p []byte) (int, error) {
+	n, err := img.ReadAt(p, img.pos)
+	img.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Returns gpt.ErrReadOnly, since EWF images can't be modified.
+func (img *Image) Write(p []byte) (int, error) {
+	return 0, gpt.ErrReadOnly
+}
+
+// Sets the position in the imaged media for the next Read.
+func (img *Image) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += img.pos
+	case io.SeekEnd:
+		offset += img.Size()
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("Seek to negative offset %d", offset)
+	}
+	img.pos = offset
+	return offset, nil
+}
+
+// Does nothing, since EWF images can't be modified.
+func (img *Image) Sync() error {
+	return nil
+}
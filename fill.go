@@ -0,0 +1,64 @@
+package gpt
+
+// Adds a partition of type typ which fills the largest free region of the
+// usable area, starting at a multiple of align logical blocks. If all is set,
+// a partition is added to every free region instead, in order of their
+// location. Regions which are too small to hold an aligned partition are
+// ignored. The new partitions are given random unique GUIDs.
+//
+// Returns the indexes of the new entries in t.Entries.
+func (t *Table) FillFree(typ GUID, align uint64, all bool) ([]int, error) {
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+	if typ.IsZero() {
+		return nil, errorf(CodeUnknownType, "Can not add a partition with the unused partition type.")
+	}
+	if align == 0 {
+		align = 1
+	}
+	var regions []blockRange
+	for r := range t.Free() {
+		if start := alignUp(r.FirstLBA, align); start <= r.LastLBA {
+			regions = append(regions, blockRange{first: start, last: r.LastLBA})
+		}
+	}
+	if len(regions) == 0 {
+		return nil, errorf(CodeNoFreeSpace, "No free space for a partition.")
+	}
+	if !all {
+		largest := regions[0]
+		for _, r := range regions[1:] {
+			if r.last-r.first > largest.last-largest.first {
+				largest = r
+			}
+		}
+		regions = []blockRange{largest}
+	}
+
+	var added []int
+	for _, r := range regions {
+		idx := -1
+		for i, e := range t.Entries {
+			if e.PartitionType.IsZero() {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return added, errorf(CodeNoFreeEntries, "No unused partition entries.")
+		}
+		guid, err := NewGUID()
+		if err != nil {
+			return added, err
+		}
+		t.Entries[idx] = GPTPartitionEntry{
+			PartitionType:    typ,
+			UniqueParitition: guid,
+			StartingLBA:      r.first,
+			EndingLBA:        r.last,
+		}
+		added = append(added, idx)
+	}
+	return added, nil
+}
@@ -0,0 +1,105 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The fields of a GPTPartitionEntry, without its Format method, for the verbs
+// which Format leaves to the fmt package.
+type entryFields GPTPartitionEntry
+
+// Formats the entry for the fmt package. %v and %s print a short summary of
+// the partition's type, location and name, such as
+// `Linux filesystem LBA 2048-4095 "root"`, or "Unused" for an unused entry.
+// %+v prints every field, with the attributes named. %x and %X print the
+// entry's 128 byte on disk format in hex. Other verbs, including %#v, format
+// the entry's fields as they would a struct.
+func (e GPTPartitionEntry) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		fmt.Fprintf(f, "{Type: %v, GUID: %v, LBA: %d-%d, Attributes: %+v, Name: %q}",
+			e.PartitionType.HumanString(), e.UniqueParitition, e.StartingLBA, e.EndingLBA, entryAttributes(e), e.GetName())
+	case verb == 'v' && f.Flag('#'):
+		s := fmt.Sprintf("%#v", entryFields(e))
+		fmt.Fprint(f, strings.Replace(s, "gpt.entryFields", "gpt.GPTPartitionEntry", 1))
+	case verb == 'v' || verb == 's':
+		if e.PartitionType.IsZero() {
+			fmt.Fprint(f, "Unused")
+			return
+		}
+		fmt.Fprintf(f, "%v LBA %d-%d", e.PartitionType.HumanString(), e.StartingLBA, e.EndingLBA)
+		if name := e.GetName(); name != "" {
+			fmt.Fprintf(f, " %q", name)
+		}
+	case verb == 'x' || verb == 'X':
+		b, _ := e.MarshalBinary()
+		fmt.Fprintf(f, fmt.FormatString(f, verb), b)
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), entryFields(e))
+	}
+}
+
+// The attributes of an entry, which are named for the entry's type when
+// formatted with %+v.
+type typedAttributes struct {
+	a   GPTPartitionAttribute
+	typ GUID
+}
+
+// Returns the attributes of e, for formatting with its type.
+func entryAttributes(e GPTPartitionEntry) typedAttributes {
+	return typedAttributes{e.Attributes, e.PartitionType}
+}
+
+func (t typedAttributes) Format(f fmt.State, verb rune) {
+	t.a.format(f, verb, t.typ)
+}
+
+// Formats the attributes for the fmt package. %v and %s print the names of
+// the attributes which are set, separated by commas, followed by any other
+// bits which are set in hex, or "none" if no bits are set. %+v prints all of
+// the bits in hex, followed by the names in parentheses. Other verbs, such as
+// %x and %d, format the attributes as a uint64.
+//
+// Only the attributes defined for all partitions are named, since the GUID
+// specific attributes depend on the partition's type. Format the entry with
+// %+v to name them too.
+func (a GPTPartitionAttribute) Format(f fmt.State, verb rune) {
+	a.format(f, verb, ZeroGUID)
+}
+
+// Formats the attributes of a partition of type typ.
+func (a GPTPartitionAttribute) format(f fmt.State, verb rune, typ GUID) {
+	if verb != 'v' && verb != 's' {
+		fmt.Fprintf(f, fmt.FormatString(f, verb), uint64(a))
+		return
+	}
+	names := a.Names(typ)
+	if verb == 'v' && f.Flag('+') {
+		fmt.Fprintf(f, "%#016x", uint64(a))
+		if len(names) > 0 {
+			fmt.Fprintf(f, " (%s)", strings.Join(names, ", "))
+		}
+		return
+	}
+	if rest := a &^ a.namedBits(typ); rest != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint64(rest)))
+	}
+	if len(names) == 0 {
+		fmt.Fprint(f, "none")
+		return
+	}
+	fmt.Fprint(f, strings.Join(names, ","))
+}
+
+// Returns the bits of a which Names names for a partition of type typ.
+func (a GPTPartitionAttribute) namedBits(typ GUID) GPTPartitionAttribute {
+	var named GPTPartitionAttribute
+	for bit := GPTPartitionAttribute(1); bit != 0; bit <<= 1 {
+		if a&bit != 0 && len(bit.Names(typ)) > 0 {
+			named |= bit
+		}
+	}
+	return named
+}
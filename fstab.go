@@ -0,0 +1,102 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An FstabEntry is a single line of an /etc/fstab file.
+type FstabEntry struct {
+	// The index of the partition in the table that this entry mounts.
+	Index int
+
+	// The device to mount, as a PARTUUID= identifier.
+	Spec string
+
+	// The mount point, or "none" for swap.
+	File string
+
+	// The filesystem type.
+	Type string
+
+	// Comma separated mount options.
+	Options string
+
+	// The dump frequency and fsck pass number.
+	Freq, PassNo int
+}
+
+// Returns the entry formatted as a line of /etc/fstab, without a trailing
+// newline.
+func (e FstabEntry) String() string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%d %d", e.Spec, e.File, e.Type, e.Options, e.Freq, e.PassNo)
+}
+
+// Returns the PARTUUID= identifier for a partition, as used by fstab and the
+// kernel command line.
+func partUUID(e GPTPartitionEntry) string {
+	return "PARTUUID=" + strings.ToLower(e.UniqueParitition.String())
+}
+
+// Returns suggested /etc/fstab entries for each partition in the table which
+// contains a filesystem that can be mounted (or used as swap). The
+// filesystem type of each partition is detected with ProbeFilesystem.
+//
+// Partitions with a type from the Discoverable Partitions Specification are
+// mounted at the location defined by the specification, other partitions are
+// mounted under /mnt using their partition name or index.
+func (t *Table) Fstab(hd io.ReadSeeker) ([]FstabEntry, error) {
+	var entries []FstabEntry
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		fstype, err := t.ProbeFilesystem(hd, i)
+		if err != nil {
+			return nil, err
+		}
+		if fstype == "" || fstype == "crypto_LUKS" {
+			continue
+		}
+
+		fe := FstabEntry{
+			Index:   i,
+			Spec:    partUUID(e),
+			Type:    fstype,
+			Options: "defaults",
+			PassNo:  2,
+		}
+		mnt, _ := dpsMountPoint(e.PartitionType)
+		switch {
+		case fstype == "swap":
+			fe.File, fe.Options, fe.PassNo = "none", "sw", 0
+		case mnt != "" && mnt != "swap":
+			fe.File = mnt
+		case e.GetName() != "":
+			fe.File = "/mnt/" + strings.Map(fstabNameChar, e.GetName())
+		default:
+			fe.File = fmt.Sprintf("/mnt/part%d", i)
+		}
+		switch {
+		case fe.File == "/":
+			fe.PassNo = 1
+		case e.PartitionType == EFISystemPartition:
+			fe.Options = "umask=0077"
+		}
+		if mnt != "" && e.Attributes&DPSReadOnly != 0 && fstype != "swap" {
+			fe.Options += ",ro"
+		}
+		entries = append(entries, fe)
+	}
+	return entries, nil
+}
+
+// Maps characters of a partition name which can't be used in an fstab mount
+// point to underscores.
+func fstabNameChar(r rune) rune {
+	if r <= ' ' || r == '/' || r == '\\' || r == 0x7F {
+		return '_'
+	}
+	return r
+}
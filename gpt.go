@@ -6,22 +6,20 @@
 package gpt
 
 import (
-	"bytes"
 	"encoding/binary"
-	"fmt"
+	"hash/crc32"
 	"io"
+	"slices"
 	"unicode/utf16"
 )
 
-// Size of a block on the hard drive.
-//
-// TODO: This should be a variable and handle different sizes, but then the
-// padding in GPT can't be built into the struct definition, and the
-// LogicalBlock would need to be a slice instead of an array.
-// BUG(driusan): Only 512 byte logical block sizes are currently supported.
+// The most common size of a block on the hard drive, and the size that a
+// GPTHeader is encoded in. Disks with 4096 byte logical blocks (4Kn disks) are
+// also supported, see Table.BlockSize. Their headers are followed by zeros up
+// to the end of the block.
 const LogicalBlockSize uint64 = 512
 
-// A single block on the hard drive.
+// A single 512 byte block on the hard drive, such as the MBR in LBA 0.
 type LogicalBlock [LogicalBlockSize]byte
 
 // GPTHeader represents a GPT header. There should be two copies of this:
@@ -82,30 +80,31 @@ type GPTHeader struct {
 	Padding [LogicalBlockSize - 92]byte
 }
 
-// Verifies that the GPT header loaded from disk is valid.
-//
-// BUG(driusan): HeaderCRC32 not verified.
-// BUG(driusan): PartitionEntryArrayCRC32 not verified
-// BUG(driusan): Secondary header is not verified
+// Verifies that the fields of the GPT header loaded from disk are valid. The
+// checksums and secondary header are not verified, use the package level
+// Verify function to check them.
 func (g GPTHeader) Verify() error {
 	if string(g.Signature[:]) != "EFI PART" {
-		return fmt.Errorf("Invalid GPT Header \"%v\"", string(g.Signature[:]))
+		return errorf(CodeInvalidHeader, "Invalid GPT Header \"%v\"", string(g.Signature[:]))
 	}
 	if g.Reserved != 0 {
-		return fmt.Errorf("Invalid GPT Header. Reserved area not zero.")
+		return errorf(CodeInvalidHeader, "Invalid GPT Header. Reserved area not zero.")
 	}
 	for _, b := range g.Padding {
 		if b != 0 {
-			return fmt.Errorf("Invalid GPT Header. Header not zero padded.")
+			return errorf(CodeInvalidHeader, "Invalid GPT Header. Header not zero padded.")
 		}
 	}
 
-	if g.MyLBA != 1 || g.PartitionEntryLBA != 2 {
-		return fmt.Errorf("TODO: Handle GPT Header or GPT Partition in non-standard location")
+	// The partition entry array doesn't have to start at LBA 2, some
+	// tools leave a gap before it. VerifyPlacement checks that it doesn't
+	// overlap anything.
+	if g.MyLBA != 1 {
+		return errorf(CodeInvalidHeader, "Invalid GPT Header. Primary header claims to be at LBA %d.", g.MyLBA)
+	}
+	if g.PartitionEntryLBA < 2 {
+		return errorf(CodeInvalidHeader, "Invalid GPT Header. Partition entry array at LBA %d overlaps the header.", g.PartitionEntryLBA)
 	}
-
-	// TODO: Check HeaderCRC32
-	// TODO: Check PartitionEntryCRC32
 	return g.verifyAlt()
 }
 
@@ -117,79 +116,213 @@ func (g GPTHeader) verifyAlt() error {
 	return nil
 }
 
+// Verifies that the partition entry array described by the header can be
+// read from a device of size bytes with logical blocks of bs bytes. Its
+// entries must be a power of two of at least 128 bytes which fit in a block,
+// and it must be within the device, so that a corrupt header can't make it
+// read (or allocate room for) more than the device holds.
+func (g GPTHeader) verifyEntryArray(bs, size uint64) error {
+	es := uint64(g.SizeOfPartitionEntry)
+	if es < 128 || es&(es-1) != 0 || es > bs {
+		return errorf(CodeInvalidHeader, "Invalid GPT Header. Invalid partition entry size %d.", g.SizeOfPartitionEntry)
+	}
+	if end := size / bs; g.PartitionEntryLBA > end || g.entryArrayBlocks(bs) > end-g.PartitionEntryLBA {
+		return errorf(CodeInvalidHeader, "Invalid GPT Header. Partition entry array of %d entries at LBA %d is past the end of the device (%d blocks).", g.MaxNumberPartitionEntries, g.PartitionEntryLBA, end)
+	}
+	return nil
+}
+
+// Encodes the header in its on disk format, which is one LogicalBlock.
+func (g GPTHeader) encode() []byte {
+	b := make([]byte, LogicalBlockSize)
+	copy(b[0:8], g.Signature[:])
+	binary.LittleEndian.PutUint32(b[8:12], g.Revision)
+	binary.LittleEndian.PutUint32(b[12:16], g.HeaderSize)
+	binary.LittleEndian.PutUint32(b[16:20], g.HeaderCRC32)
+	binary.LittleEndian.PutUint32(b[20:24], g.Reserved)
+	binary.LittleEndian.PutUint64(b[24:32], g.MyLBA)
+	binary.LittleEndian.PutUint64(b[32:40], g.AltLBA)
+	binary.LittleEndian.PutUint64(b[40:48], g.FirstUseableLBA)
+	binary.LittleEndian.PutUint64(b[48:56], g.LastUseableLBA)
+	disk := g.Disk.EFIBytes()
+	copy(b[56:72], disk[:])
+	binary.LittleEndian.PutUint64(b[72:80], g.PartitionEntryLBA)
+	binary.LittleEndian.PutUint32(b[80:84], g.MaxNumberPartitionEntries)
+	binary.LittleEndian.PutUint32(b[84:88], g.SizeOfPartitionEntry)
+	binary.LittleEndian.PutUint32(b[88:92], g.PartitionEntryArrayCRC32)
+	copy(b[92:], g.Padding[:])
+	return b
+}
+
+// Encodes the header as a logical block of bs bytes, which is followed by
+// zeros after the first LogicalBlockSize bytes.
+func (g GPTHeader) encodeBlock(bs uint64) []byte {
+	b := make([]byte, bs)
+	copy(b, g.encode())
+	return b
+}
+
+// Decodes a header from its on disk format in b, which must be at least 92
+// bytes. The padding is taken from the rest of b, up to a LogicalBlock.
+func decodeHeader(b []byte) GPTHeader {
+	g := GPTHeader{
+		Revision:                  binary.LittleEndian.Uint32(b[8:12]),
+		HeaderSize:                binary.LittleEndian.Uint32(b[12:16]),
+		HeaderCRC32:               binary.LittleEndian.Uint32(b[16:20]),
+		Reserved:                  binary.LittleEndian.Uint32(b[20:24]),
+		MyLBA:                     binary.LittleEndian.Uint64(b[24:32]),
+		AltLBA:                    binary.LittleEndian.Uint64(b[32:40]),
+		FirstUseableLBA:           binary.LittleEndian.Uint64(b[40:48]),
+		LastUseableLBA:            binary.LittleEndian.Uint64(b[48:56]),
+		Disk:                      GUIDFromEFIBytes([16]byte(b[56:72])),
+		PartitionEntryLBA:         binary.LittleEndian.Uint64(b[72:80]),
+		MaxNumberPartitionEntries: binary.LittleEndian.Uint32(b[80:84]),
+		SizeOfPartitionEntry:      binary.LittleEndian.Uint32(b[84:88]),
+		PartitionEntryArrayCRC32:  binary.LittleEndian.Uint32(b[88:92]),
+	}
+	copy(g.Signature[:], b[0:8])
+	copy(g.Padding[:], b[92:])
+	return g
+}
+
+// Encodes the header in its on disk format, which is one logical block long.
+// It implements encoding.BinaryMarshaler, and never fails.
+func (g GPTHeader) MarshalBinary() ([]byte, error) {
+	return g.encode(), nil
+}
+
+// Decodes the header from its on disk format, as returned by MarshalBinary.
+// data must hold at least the 92 bytes of the header's fields, and the rest
+// of it (up to a logical block) is the padding. The header isn't verified.
+func (g *GPTHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < 92 {
+		return errorf(CodeInvalidHeader, "GPT header is %d bytes, must be at least 92", len(data))
+	}
+	*g = decodeHeader(data)
+	return nil
+}
+
+// Computes the CRC32 of the header, as it should be stored in HeaderCRC32.
+// A HeaderSize past the first LogicalBlockSize bytes (for a disk with larger
+// logical blocks) covers the zeros which follow the header in its block.
+func (g GPTHeader) computeCRC() uint32 {
+	g.HeaderCRC32 = 0
+	return crc32.ChecksumIEEE(g.encodeBlock(max(uint64(g.HeaderSize), LogicalBlockSize))[:g.HeaderSize])
+}
+
+// Returns the size in bytes of the usable area of the disk described by this
+// header, from the start of FirstUseableLBA to the end of LastUseableLBA, for
+// a disk with logical blocks of sectorSize bytes.
+func (g GPTHeader) UsableBytes(sectorSize uint64) uint64 {
+	if g.LastUseableLBA < g.FirstUseableLBA {
+		return 0
+	}
+	return (g.LastUseableLBA - g.FirstUseableLBA + 1) * sectorSize
+}
+
+// Returns the size in bytes of the partition entry array described by this
+// header.
+func (g GPTHeader) entryArraySize() uint64 {
+	return uint64(g.MaxNumberPartitionEntries) * uint64(g.SizeOfPartitionEntry)
+}
+
+// Returns the number of logical blocks of bs bytes occupied by the partition
+// entry array.
+func (g GPTHeader) entryArrayBlocks(bs uint64) uint64 {
+	return BytesToLBAs(g.entryArraySize(), bs)
+}
+
 // Reads the GPT Partitions from the location pointed to from the GPT header
 // hd should be a io.ReadSeeker (usually an os.File) pointing to the block
 // device for the drive being read.
 func (g GPTHeader) GetPartitions(hd io.ReadSeeker) ([]GPTPartitionEntry, error) {
-	newOffset, err := hd.Seek(int64(LogicalBlockSize*(g.PartitionEntryLBA)), 0)
+	return g.ReadPartitions(hd, nil, nil)
+}
+
+// Reads the GPT Partitions the same as GetPartitions, but appends them to dst
+// and uses buf (which should be at least LogicalBlockSize bytes) as scratch
+// space for reading blocks. If dst has room for the entries and buf is large
+// enough, no memory is allocated, so a caller scanning many disks can reuse
+// both between calls. Either may be nil.
+func (g GPTHeader) ReadPartitions(hd io.ReadSeeker, buf []byte, dst []GPTPartitionEntry) ([]GPTPartitionEntry, error) {
+	return g.readPartitions(hd, LogicalBlockSize, buf, dst)
+}
+
+// Reads the GPT Partitions like ReadPartitions, from a disk with logical
+// blocks of bs bytes.
+func (g GPTHeader) readPartitions(hd io.ReadSeeker, bs uint64, buf []byte, dst []GPTPartitionEntry) ([]GPTPartitionEntry, error) {
+	size, err := deviceSize(hd)
+	if err != nil {
+		return dst, err
+	}
+	if err := g.verifyEntryArray(bs, size); err != nil {
+		return dst, err
+	}
+	newOffset, err := hd.Seek(int64(bs*g.PartitionEntryLBA), 0)
 	if err != nil {
-		return nil, err
+		return dst, err
+	}
+	if uint64(newOffset) != bs*g.PartitionEntryLBA {
+		return dst, errorf(CodeInvalidHeader, "Could not find PartitionEntry table.")
 	}
-	if uint64(newOffset) != LogicalBlockSize*g.PartitionEntryLBA {
-		return nil, fmt.Errorf("Could not find PartitionEntry table.")
+	if uint64(len(buf)) < bs {
+		buf = make([]byte, bs)
 	}
-	partitions := make([]GPTPartitionEntry, 0, g.MaxNumberPartitionEntries)
+	block := buf[:bs]
+	dst = slices.Grow(dst, int(g.MaxNumberPartitionEntries))
 
 	// We must load 1 logical block at a time, otherwise bad things happen
 	// on some OSes
 	partitionsLeft := g.MaxNumberPartitionEntries
-	partitionsPerBlock := uint32(LogicalBlockSize / uint64(g.SizeOfPartitionEntry))
-	if uint64(LogicalBlockSize)%uint64(g.SizeOfPartitionEntry) != 0 {
-		return nil, fmt.Errorf("Partitions must fit entirely in a single block.")
-	}
 	for partitionsLeft > 0 {
-		// Read one logical block to ensure that we don't get an I/O
-		// error
-		var hdBlock LogicalBlock
-		_, err := io.ReadFull(hd, hdBlock[:])
-		if err != nil {
-			return nil, err
+		if _, err := io.ReadFull(hd, block); err != nil {
+			return dst, err
 		}
-
-		// then convert what we read into a reader, and read the
-		// the partition entry from that, since the size of an entry
-		// isn't the size of a block.
-		partReader := bytes.NewReader(hdBlock[:])
-
-		for i := uint32(0); i < partitionsPerBlock; i++ {
-			p := GPTPartitionEntry{}
-			err = binary.Read(partReader, binary.LittleEndian, &p)
-			//err = binary.Read(partReader, binary.BigEndian, &p)
-			if err != nil {
-				return nil, err
-			}
-
-			// read the appropriate amount of padding to get to the next
-			// entry and verify that it's all zeros
-			if paddingSize := g.SizeOfPartitionEntry - 128; paddingSize > 0 {
-				padding := make([]byte, paddingSize)
-
-				// Make the padding non-zero, so that when we read it
-				// we can be sure that it was read properly and not
-				// just initialized to zero by Go.
-				for i := uint32(0); i < paddingSize; i++ {
-					padding[i] = 0xFF
-				}
-
-				n, err := io.ReadFull(partReader, padding)
-				if err != nil {
-					return nil, err
-				}
-				if uint32(n) != paddingSize {
-					return nil, fmt.Errorf("Could not read appropriate number of zeros to pad partition entry")
-				}
-				for i := uint32(0); i < paddingSize; i++ {
-					if padding[i] != 0 {
-						return nil, fmt.Errorf("Invalid partition entry padding")
-					}
+		for off := uint32(0); off < uint32(bs) && partitionsLeft > 0; off += g.SizeOfPartitionEntry {
+			entry := block[off : off+g.SizeOfPartitionEntry]
+
+			// The padding to get to the next entry must be all
+			// zeros.
+			for _, c := range entry[128:] {
+				if c != 0 {
+					return dst, errorf(CodeInvalidHeader, "Invalid partition entry padding")
 				}
 			}
-
-			partitions = append(partitions, p)
+			dst = append(dst, decodeEntry(entry))
 			partitionsLeft--
 		}
 	}
-	return partitions, nil
+	return dst, nil
+}
+
+// Encodes a partition entry in its on disk format into the first 128 bytes of
+// b.
+func encodeEntry(b []byte, e GPTPartitionEntry) {
+	typ, unique := e.PartitionType.EFIBytes(), e.UniqueParitition.EFIBytes()
+	copy(b[0:16], typ[:])
+	copy(b[16:32], unique[:])
+	binary.LittleEndian.PutUint64(b[32:40], e.StartingLBA)
+	binary.LittleEndian.PutUint64(b[40:48], e.EndingLBA)
+	binary.LittleEndian.PutUint64(b[48:56], uint64(e.Attributes))
+	for i, c := range e.PartitionName {
+		binary.LittleEndian.PutUint16(b[56+2*i:], c)
+	}
+}
+
+// Decodes a partition entry from the first 128 bytes of b, without the
+// reflection (and allocations) of encoding/binary.
+func decodeEntry(b []byte) GPTPartitionEntry {
+	e := GPTPartitionEntry{
+		PartitionType:    GUIDFromEFIBytes([16]byte(b[0:16])),
+		UniqueParitition: GUIDFromEFIBytes([16]byte(b[16:32])),
+		StartingLBA:      binary.LittleEndian.Uint64(b[32:40]),
+		EndingLBA:        binary.LittleEndian.Uint64(b[40:48]),
+		Attributes:       GPTPartitionAttribute(binary.LittleEndian.Uint64(b[48:56])),
+	}
+	for i := range e.PartitionName {
+		e.PartitionName[i] = binary.LittleEndian.Uint16(b[56+2*i:])
+	}
+	return e
 }
 
 type GPTPartitionAttribute uint64
@@ -199,11 +332,51 @@ type GPTPartitionAttribute uint64
 // Bits 48-63 are reserved for GUID specific use and must be preserved by tools
 // which modify the GPT header
 const (
-	GPTPartitionSystem = GPTPartitionAttribute(iota)
+	GPTPartitionSystem = GPTPartitionAttribute(1 << iota)
 	GPTPartitionNoBlockIOProtocol
 	GPTPartitionLegacyBIOSBootable
 )
 
+// Sets or clears a single attribute bit.
+func (a *GPTPartitionAttribute) setBit(bit GPTPartitionAttribute, set bool) {
+	if set {
+		*a |= bit
+	} else {
+		*a &^= bit
+	}
+}
+
+// Returns the names of the attributes that are set on a partition of type
+// typ, including the GUID specific attributes of the types whose attributes
+// are known (ie. "hidden" for a Microsoft basic data partition.)
+func (a GPTPartitionAttribute) Names(typ GUID) []string {
+	var names []string
+	if a&GPTPartitionSystem != 0 {
+		names = append(names, "required")
+	}
+	if a&GPTPartitionNoBlockIOProtocol != 0 {
+		names = append(names, "no-block-io")
+	}
+	if a&GPTPartitionLegacyBIOSBootable != 0 {
+		names = append(names, "legacy-bios-bootable")
+	}
+	if typ == MicrosoftBasicData {
+		if a.MicrosoftReadOnly() {
+			names = append(names, "read-only")
+		}
+		if a.MicrosoftShadowCopy() {
+			names = append(names, "shadow-copy")
+		}
+		if a.MicrosoftHidden() {
+			names = append(names, "hidden")
+		}
+		if a.MicrosoftNoDriveLetter() {
+			names = append(names, "no-drive-letter")
+		}
+	}
+	return names
+}
+
 // Represents a single GPT partition.
 // When reading a GPT partition from the disk, it's followed by
 // len(sizeOfPartitionEntry)-128 zeros, which can't be encoded in this struct
@@ -227,21 +400,85 @@ type GPTPartitionEntry struct {
 	PartitionName [36]uint16
 }
 
+// Encodes the entry in its 128 byte on disk format, without the zero padding
+// of entries larger than 128 bytes. It implements encoding.BinaryMarshaler,
+// and never fails.
+func (e GPTPartitionEntry) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 128)
+	encodeEntry(b, e)
+	return b, nil
+}
+
+// Decodes the entry from its on disk format, as returned by MarshalBinary.
+// Any bytes in data after the first 128 are ignored.
+func (e *GPTPartitionEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < 128 {
+		return errorf(CodeInvalidHeader, "Partition entry is %d bytes, must be at least 128", len(data))
+	}
+	*e = decodeEntry(data)
+	return nil
+}
+
 // Returns the size of a GPT partition in number of logical blocks
 func (e GPTPartitionEntry) Size() uint64 {
-	return e.EndingLBA - e.StartingLBA
+	return e.LBACount()
+}
+
+// Returns the number of logical blocks in the partition. The ending LBA is
+// inclusive, so this is one more than the difference between the ending and
+// starting LBAs. A partition which ends before it starts has no blocks.
+func (e GPTPartitionEntry) LBACount() uint64 {
+	return RangeLength(e.StartingLBA, e.EndingLBA)
+}
+
+// Returns the offset in bytes of the last byte of the partition from the start
+// of the disk, for a disk with logical blocks of sectorSize bytes.
+func (e GPTPartitionEntry) LastByte(sectorSize uint64) uint64 {
+	return (e.EndingLBA+1)*sectorSize - 1
+}
+
+// Returns the size of the partition in bytes, for a disk with logical blocks
+// of sectorSize bytes. The ending LBA is inclusive, so a partition from LBA 34
+// to 34 is one block long.
+func (e GPTPartitionEntry) SizeBytes(sectorSize uint64) uint64 {
+	return e.LBACount() * sectorSize
+}
+
+// Returns the offset in bytes of the start of the partition from the start of
+// the disk, for a disk with logical blocks of sectorSize bytes.
+func (e GPTPartitionEntry) StartOffsetBytes(sectorSize uint64) uint64 {
+	return e.StartingLBA * sectorSize
 }
 
 // Returns the name of the GPT partition.
+//
+// The name is terminated by the first null character (if any.) Characters
+// outside of the Basic Multilingual Plane are decoded from their UTF-16
+// surrogate pairs, and unpaired surrogates are replaced with U+FFFD rather
+// than being passed through as invalid UTF-8.
 func (e GPTPartitionEntry) GetName() string {
-	for i := 0; i < len(e.PartitionName); i++ {
-		c := e.PartitionName[i]
+	name := e.PartitionName[:]
+	for i, c := range name {
 		if c == 0 {
-			if i == 0 {
-				return ""
-			}
-			return string(utf16.Decode(e.PartitionName[:i]))
+			name = name[:i]
+			break
 		}
 	}
-	return string(utf16.Decode(e.PartitionName[:]))
+	return string(utf16.Decode(name))
+}
+
+// Sets the name of the GPT partition.
+//
+// Invalid UTF-8 sequences in name are replaced with U+FFFD, and characters
+// outside of the Basic Multilingual Plane are encoded as surrogate pairs. It is
+// an error for the encoded name to be longer than the 36 UTF-16 code units
+// that the partition entry can hold.
+func (e *GPTPartitionEntry) SetName(name string) error {
+	encoded := utf16.Encode([]rune(name))
+	if len(encoded) > len(e.PartitionName) {
+		return errorf(CodeName, "Partition name \"%v\" too long (%d UTF-16 code units, max %d)", name, len(encoded), len(e.PartitionName))
+	}
+	e.PartitionName = [36]uint16{}
+	copy(e.PartitionName[:], encoded)
+	return nil
 }
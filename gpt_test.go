@@ -0,0 +1,68 @@
+package gpt_test
+
+import (
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+)
+
+// Returns an image with a full partition entry array, and its primary header.
+func benchmarkImage(tb testing.TB) (*gpttest.Image, gpt.GPTHeader) {
+	d := gpttest.Disk{}
+	for i := uint64(0); i < gpttest.DefaultEntries; i++ {
+		d.Partitions = append(d.Partitions, gpttest.Partition{Type: gpt.LinuxFilesystem, Start: 2048 + i*8, End: 2048 + i*8 + 7})
+	}
+	img, err := d.Image()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return img, table.Primary
+}
+
+func TestReadPartitionsReusesBuffers(t *testing.T) {
+	img, h := benchmarkImage(t)
+	buf := make([]byte, gpt.LogicalBlockSize)
+	dst := make([]gpt.GPTPartitionEntry, 0, h.MaxNumberPartitionEntries)
+	allocs := testing.AllocsPerRun(10, func() {
+		var err error
+		if dst, err = h.ReadPartitions(img, buf, dst[:0]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ReadPartitions with buffers made %v allocations", allocs)
+	}
+	if len(dst) != int(h.MaxNumberPartitionEntries) || dst[127].StartingLBA != 2048+127*8 {
+		t.Errorf("read %d entries, last starts at %d", len(dst), dst[len(dst)-1].StartingLBA)
+	}
+}
+
+func BenchmarkGetPartitions(b *testing.B) {
+	img, h := benchmarkImage(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := h.GetPartitions(img); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadPartitions(b *testing.B) {
+	img, h := benchmarkImage(b)
+	buf := make([]byte, gpt.LogicalBlockSize)
+	dst := make([]gpt.GPTPartitionEntry, 0, h.MaxNumberPartitionEntries)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if dst, err = h.ReadPartitions(img, buf, dst[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
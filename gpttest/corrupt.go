@@ -0,0 +1,97 @@
+package gpttest
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand"
+)
+
+// Which copy of the GPT a corruption applies to.
+type Copy int
+
+const (
+	Primary Copy = iota
+	Backup
+)
+
+// Returns the LBAs of the header and entry array of copy c of the GPT on d.
+func (d Disk) header(c Copy) (lba, array uint64) {
+	h := d.PrimaryHeader()
+	if c == Backup {
+		h = d.BackupHeader()
+	}
+	return h.MyLBA, h.PartitionEntryLBA
+}
+
+// Returns a Corruption which inverts the stored header CRC of copy c, so
+// that the header's contents are intact but its CRC is wrong.
+func FlipHeaderCRC(c Copy) Corruption {
+	return func(img []byte, d Disk) []byte {
+		lba, _ := d.header(c)
+		crc := img[lba*d.SectorSize+16:]
+		binary.LittleEndian.PutUint32(crc, ^binary.LittleEndian.Uint32(crc))
+		return img
+	}
+}
+
+// Returns a Corruption which inverts the partition entry array CRC stored in
+// the header of copy c. The header CRC is recomputed, so that only the array
+// CRC is wrong.
+func FlipArrayCRC(c Copy) Corruption {
+	return func(img []byte, d Disk) []byte {
+		lba, _ := d.header(c)
+		h := img[lba*d.SectorSize:]
+		binary.LittleEndian.PutUint32(h[88:], ^binary.LittleEndian.Uint32(h[88:]))
+		fixHeaderCRC(h)
+		return img
+	}
+}
+
+// Returns a Corruption which overwrites the header of copy c with zeros.
+func ZeroHeader(c Copy) Corruption {
+	return func(img []byte, d Disk) []byte {
+		lba, _ := d.header(c)
+		clear(img[lba*d.SectorSize : (lba+1)*d.SectorSize])
+		return img
+	}
+}
+
+// Returns a Corruption which overwrites the partition entry array of copy c
+// with pseudo-random bytes generated from seed, without updating the CRCs.
+func ScrambleEntries(c Copy, seed int64) Corruption {
+	return func(img []byte, d Disk) []byte {
+		_, array := d.header(c)
+		size := uint64(d.Entries) * uint64(d.EntrySize)
+		start := array * d.SectorSize
+		rand.New(rand.NewSource(seed)).Read(img[start : start+size])
+		return img
+	}
+}
+
+// Returns a Corruption which removes the last sectors of the image, as if
+// it was truncated. Truncating 1 sector removes the backup header, and
+// truncating 1 + d.ArraySectors() removes the backup entry array too.
+func Truncate(sectors uint64) Corruption {
+	return func(img []byte, d Disk) []byte {
+		n := min(sectors*d.SectorSize, uint64(len(img)))
+		return img[:uint64(len(img))-n]
+	}
+}
+
+// Returns a Corruption which removes the backup header and entry array from
+// the end of the image.
+func TruncateBackup() Corruption {
+	return func(img []byte, d Disk) []byte {
+		return Truncate(1+d.ArraySectors())(img, d)
+	}
+}
+
+// Recomputes the CRC of the encoded header at the start of h.
+func fixHeaderCRC(h []byte) {
+	size := binary.LittleEndian.Uint32(h[12:])
+	if size < 92 || int(size) > len(h) {
+		return
+	}
+	binary.LittleEndian.PutUint32(h[16:], 0)
+	binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h[:size]))
+}
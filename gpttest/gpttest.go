@@ -0,0 +1,295 @@
+// Package gpttest builds synthetic GPT disk images, both valid and
+// deliberately broken, so that programs using the gpt package can be tested
+// without real disks.
+//
+// A Disk describes the image to build, and is built in memory with Image or
+// in a temporary file with TempFile:
+//
+//	d := gpttest.Disk{
+//		Partitions: []gpttest.Partition{
+//			{Type: gpt.EFISystemPartition, Start: 2048, End: 206847},
+//		},
+//	}
+//	img, err := d.Image()
+//	table, err := gpt.ReadTable(img)
+package gpttest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// Defaults used for the zero values of the fields of a Disk.
+const (
+	DefaultSectorSize = 512
+	DefaultSectors    = 204800
+	DefaultEntries    = 128
+	DefaultEntrySize  = 128
+)
+
+// A Partition is a partition entry of a Disk.
+type Partition struct {
+	Type gpt.GUID
+
+	// The partition's unique GUID. A random GUID is generated if it's
+	// the zero GUID.
+	GUID gpt.GUID
+
+	// The first and last sector of the partition, inclusive.
+	Start, End uint64
+
+	Name       string
+	Attributes gpt.GPTPartitionAttribute
+
+	// The index of the entry in the partition entry array. Partitions
+	// with an Index of 0 are placed in the first unused entry after the
+	// previous partition.
+	Index int
+}
+
+// A Corruption modifies a built image, returning the modified image. The disk
+// that the image was built from (with defaults filled in) is passed so that
+// the corruption can locate the structures it modifies.
+type Corruption func(img []byte, d Disk) []byte
+
+// A Disk describes a synthetic disk image containing a GPT. The zero value
+// is a valid, empty, 100MiB disk.
+type Disk struct {
+	// The size of a sector in bytes. Defaults to DefaultSectorSize.
+	SectorSize uint64
+
+	// The size of the disk in sectors. Defaults to DefaultSectors.
+	Sectors uint64
+
+	// The number and size of partition entries. Default to
+	// DefaultEntries and DefaultEntrySize.
+	Entries   uint32
+	EntrySize uint32
+
+	// The disk GUID. A random GUID is generated if it's the zero GUID.
+	GUID gpt.GUID
+
+	// If set, the protective MBR in sector 0 is not written.
+	NoProtectiveMBR bool
+
+	Partitions []Partition
+
+	// Corruptions to apply, in order, after building a valid image.
+	Corruptions []Corruption
+}
+
+// Returns d with defaults filled in for zero values, and a random disk GUID
+// if it has none.
+func (d Disk) withDefaults() (Disk, error) {
+	d = d.withSizes()
+	if d.SectorSize < 512 || d.SectorSize&(d.SectorSize-1) != 0 {
+		return d, fmt.Errorf("Invalid sector size %d", d.SectorSize)
+	}
+	if d.EntrySize < 128 || d.EntrySize&(d.EntrySize-1) != 0 {
+		return d, fmt.Errorf("Invalid entry size %d", d.EntrySize)
+	}
+	if d.Sectors < 3+2*d.ArraySectors() {
+		return d, fmt.Errorf("Disk of %d sectors is too small for the GPT", d.Sectors)
+	}
+	if d.GUID.IsZero() {
+		g, err := gpt.NewGUID()
+		if err != nil {
+			return d, err
+		}
+		d.GUID = g
+	}
+	return d, nil
+}
+
+// Returns d with the default sizes filled in for zero values.
+func (d Disk) withSizes() Disk {
+	if d.SectorSize == 0 {
+		d.SectorSize = DefaultSectorSize
+	}
+	if d.Sectors == 0 {
+		d.Sectors = DefaultSectors
+	}
+	if d.Entries == 0 {
+		d.Entries = DefaultEntries
+	}
+	if d.EntrySize == 0 {
+		d.EntrySize = DefaultEntrySize
+	}
+	return d
+}
+
+// Returns the number of sectors used by each copy of the partition entry
+// array.
+func (d Disk) ArraySectors() uint64 {
+	d = d.withSizes()
+	size := uint64(d.Entries) * uint64(d.EntrySize)
+	return (size + d.SectorSize - 1) / d.SectorSize
+}
+
+// Returns the primary header of the disk, as it's written by Bytes. The CRCs
+// are not set.
+func (d Disk) PrimaryHeader() gpt.GPTHeader {
+	d = d.withSizes()
+	h := gpt.GPTHeader{
+		Revision:                  0x00010000,
+		HeaderSize:                92,
+		MyLBA:                     1,
+		AltLBA:                    d.Sectors - 1,
+		FirstUseableLBA:           2 + d.ArraySectors(),
+		LastUseableLBA:            d.Sectors - 2 - d.ArraySectors(),
+		Disk:                      d.GUID,
+		PartitionEntryLBA:         2,
+		MaxNumberPartitionEntries: d.Entries,
+		SizeOfPartitionEntry:      d.EntrySize,
+	}
+	copy(h.Signature[:], "EFI PART")
+	return h
+}
+
+// Returns the backup header of the disk, as it's written by Bytes. The CRCs
+// are not set.
+func (d Disk) BackupHeader() gpt.GPTHeader {
+	d = d.withSizes()
+	h := d.PrimaryHeader()
+	h.MyLBA, h.AltLBA = h.AltLBA, h.MyLBA
+	h.PartitionEntryLBA = d.Sectors - 1 - d.ArraySectors()
+	return h
+}
+
+// Builds the image described by d, and applies its corruptions.
+func (d Disk) Bytes() ([]byte, error) {
+	d, err := d.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	img := make([]byte, d.Sectors*d.SectorSize)
+	if !d.NoProtectiveMBR {
+		writeProtectiveMBR(img, d.Sectors)
+	}
+
+	array, err := d.entryArray()
+	if err != nil {
+		return nil, err
+	}
+	arrayCRC := crc32.ChecksumIEEE(array)
+	for _, h := range []gpt.GPTHeader{d.PrimaryHeader(), d.BackupHeader()} {
+		h.PartitionEntryArrayCRC32 = arrayCRC
+		copy(img[h.PartitionEntryLBA*d.SectorSize:], array)
+		copy(img[h.MyLBA*d.SectorSize:], EncodeHeader(h))
+	}
+
+	for _, c := range d.Corruptions {
+		img = c(img, d)
+	}
+	return img, nil
+}
+
+// Encodes the partition entry array.
+func (d Disk) entryArray() ([]byte, error) {
+	array := make([]byte, uint64(d.Entries)*uint64(d.EntrySize))
+	used := make([]bool, d.Entries)
+	next := 0
+	for _, p := range d.Partitions {
+		idx := p.Index
+		if idx == 0 {
+			for idx = next; idx < len(used) && used[idx]; idx++ {
+			}
+		}
+		if idx < 0 || idx >= len(used) {
+			return nil, fmt.Errorf("No partition entry for partition %q", p.Name)
+		}
+		used[idx] = true
+		next = idx + 1
+
+		e := gpt.GPTPartitionEntry{
+			PartitionType:    p.Type,
+			UniqueParitition: p.GUID,
+			StartingLBA:      p.Start,
+			EndingLBA:        p.End,
+			Attributes:       p.Attributes,
+		}
+		if e.UniqueParitition.IsZero() {
+			g, err := gpt.NewGUID()
+			if err != nil {
+				return nil, err
+			}
+			e.UniqueParitition = g
+		}
+		if err := e.SetName(p.Name); err != nil {
+			return nil, err
+		}
+		b, _ := e.MarshalBinary()
+		copy(array[uint64(idx)*uint64(d.EntrySize):], b)
+	}
+	return array, nil
+}
+
+// Encodes h, computing its header CRC. The result is HeaderSize bytes long,
+// since the rest of the sector is zero padding.
+func EncodeHeader(h gpt.GPTHeader) []byte {
+	h.HeaderCRC32 = 0
+	b, _ := h.MarshalBinary()
+	b = b[:h.HeaderSize]
+	binary.LittleEndian.PutUint32(b[16:], crc32.ChecksumIEEE(b))
+	return b
+}
+
+// Writes a protective MBR, with a single partition of type 0xEE covering the
+// disk, to the first sector of img.
+func writeProtectiveMBR(img []byte, sectors uint64) {
+	size := min(sectors-1, 0xFFFFFFFF)
+	entry := img[446:462]
+	entry[1], entry[2], entry[3] = 0x00, 0x02, 0x00 // CHS of LBA 1
+	entry[4] = 0xEE
+	entry[5], entry[6], entry[7] = 0xFF, 0xFF, 0xFF
+	binary.LittleEndian.PutUint32(entry[8:], 1)
+	binary.LittleEndian.PutUint32(entry[12:], uint32(size))
+	img[510], img[511] = 0x55, 0xAA
+}
+
+// Builds the image described by d in memory.
+func (d Disk) Image() (*Image, error) {
+	b, err := d.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(b), nil
+}
+
+// Builds the image described by d in a new temporary file in dir (or the
+// default temporary directory if dir is empty.) The file is positioned at
+// its start, and should be removed by the caller when it's no longer needed.
+func (d Disk) TempFile(dir string) (*os.File, error) {
+	b, err := d.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, "gpttest-*.img")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// Returns a Corruption which overwrites the image with data, starting at
+// sector lba.
+func OverwriteSector(lba uint64, data []byte) Corruption {
+	return func(img []byte, d Disk) []byte {
+		copy(img[lba*d.SectorSize:], data)
+		return img
+	}
+}
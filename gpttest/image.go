@@ -0,0 +1,91 @@
+package gpttest
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/driusan/gpt"
+)
+
+// An Image is an in memory disk image. It implements gpt.BlockDevice, so it
+// can be read and written like a disk. Writes can not extend the image.
+type Image struct {
+	b   []byte
+	pos int64
+}
+
+var _ gpt.BlockDevice = (*Image)(nil)
+
+// Returns an Image containing b. The image uses b directly, so writes to the
+// image modify b.
+func NewImage(b []byte) *Image {
+	return &Image{b: b}
+}
+
+// Returns the contents of the image.
+func (img *Image) Bytes() []byte {
+	return img.b
+}
+
+func (img *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative offset")
+	}
+	if off >= int64(len(img.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, img.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (img *Image) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(img.b)) {
+		return 0, fmt.Errorf("Write outside of the image")
+	}
+	return copy(img.b[off:], p), nil
+}
+
+func (img *Image) Read(p []byte) (int, error) {
+	n, err := img.ReadAt(p, img.pos)
+	img.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (img *Image) Write(p []byte) (int, error) {
+	n, err := img.WriteAt(p, img.pos)
+	img.pos += int64(n)
+	return n, err
+}
+
+func (img *Image) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += img.pos
+	case io.SeekEnd:
+		offset += int64(len(img.b))
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("Seek to negative offset %d", offset)
+	}
+	img.pos = offset
+	return offset, nil
+}
+
+// Does nothing, since the image is in memory.
+func (img *Image) Sync() error {
+	return nil
+}
+
+// Does nothing, since the image is in memory.
+func (img *Image) Close() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package gpt
+
+import (
+	"io"
+)
+
+// Moves the backup header and partition entry array to the end of dev, and
+// extends the partition which ends last on the disk to fill the usable space
+// (like growpart from cloud-utils.) This is typically done on the first boot
+// of a cloud image, after the image was written to a larger disk.
+//
+// The updated table is written to dev. ErrNoChange is returned (and nothing is
+// written) if the last partition already fills the disk.
+func (t *Table) GrowLastPartition(dev io.ReadWriteSeeker) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	size, err := deviceSize(dev)
+	if err != nil {
+		return err
+	}
+	lastLBA := size/t.BlockSize() - 1
+	if lastLBA < t.Primary.AltLBA {
+		return errorf(CodeDeviceTooSmall, "Device is smaller than the partition table (%d blocks, backup header at %d)", lastLBA+1, t.Primary.AltLBA)
+	}
+
+	last := -1
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		if last < 0 || e.EndingLBA > t.Entries[last].EndingLBA {
+			last = i
+		}
+	}
+	if last < 0 {
+		return errorf(CodeNotInUse, "No partitions to grow")
+	}
+
+	lastUseable := lastLBA - t.Primary.entryArrayBlocks(t.BlockSize()) - 1
+	if lastLBA == t.Primary.AltLBA && t.Entries[last].EndingLBA == lastUseable {
+		return ErrNoChange
+	}
+	t.Primary.AltLBA = lastLBA
+	t.Primary.LastUseableLBA = lastUseable
+	t.Entries[last].EndingLBA = lastUseable
+	return t.Write(dev)
+}
+
+// Returns the size of dev in bytes.
+func deviceSize(dev io.Seeker) (uint64, error) {
+	size, err := dev.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(size), nil
+}
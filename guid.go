@@ -1,10 +1,21 @@
 package gpt
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 )
 
 // Represents a RFC 4122 GUID.
+//
+// On disk, EFI stores the first three fields of a GUID in little endian byte
+// order and the remaining 8 bytes as is, while the RFC 4122 string and byte
+// representations are entirely big endian. The fields of this struct hold
+// the numeric values, so that encoding/binary with binary.LittleEndian produces
+// the EFI layout. Use EFIBytes and Bytes to get the raw bytes in either order.
 type GUID struct {
 	TimeLow             uint32
 	TimeMid             uint16
@@ -17,32 +28,197 @@ type GUID struct {
 // The ZeroGUID is a nil GUID that can be used for comparison
 var ZeroGUID GUID = GUID{0, 0, 0, 0, 0, [6]byte{0, 0, 0, 0, 0}}
 
-// Converts a partition type GUID to a human readable string.
-// BUG(driusan): Converting PartitionTypeGUID to a human readable string
-// only supports partition types which are used on my computer, because
-// I don't have time to transcribe every one on wikipedia.
+// Returns true if g and other are the same GUID.
+func (g GUID) Equal(other GUID) bool {
+	return g == other
+}
+
+// Returns true if g is the nil GUID. An unused partition entry has a zero
+// partition type.
+func (g GUID) IsZero() bool {
+	return g == ZeroGUID
+}
+
+// Compares two GUIDs in the order of their string representation. The result
+// is 0 if g == other, -1 if g < other, and +1 if g > other.
+func (g GUID) Compare(other GUID) int {
+	switch {
+	case g.TimeLow != other.TimeLow:
+		return compare(g.TimeLow < other.TimeLow)
+	case g.TimeMid != other.TimeMid:
+		return compare(g.TimeMid < other.TimeMid)
+	case g.TimeHighAndVersion != other.TimeHighAndVersion:
+		return compare(g.TimeHighAndVersion < other.TimeHighAndVersion)
+	case g.ClockSeqAndReserved != other.ClockSeqAndReserved:
+		return compare(g.ClockSeqAndReserved < other.ClockSeqAndReserved)
+	case g.ClockSeqLow != other.ClockSeqLow:
+		return compare(g.ClockSeqLow < other.ClockSeqLow)
+	}
+	return bytes.Compare(g.Node[:], other.Node[:])
+}
+
+// Converts the result of a less than comparison between two unequal values
+// to the result of a Compare.
+func compare(less bool) int {
+	if less {
+		return -1
+	}
+	return 1
+}
+
+// Converts a partition type GUID to a human readable string. Unknown types
+// are returned as the GUID string. Types which aren't known to this package
+// can be given a name with RegisterType.
 func (g GUID) HumanString() string {
-	switch guid := g.String(); guid {
-	case "00000000-0000-0000-0000-0000000000000":
+	if g.IsZero() {
 		return "Unused"
-	case "C12A7328-F81F-11D2-BA4B-00A0C93EC93B":
-		return "EFI System Partition"
-	case "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F":
-		return "Linux Swap"
-	case "9D94CE7C-1CA5-11DC-8817-01301BB8A9F5":
-		return "DragonFly UFS1"
-	case "C91818F9-8025-47AF-89D2-F030D7000C2C":
-		return "Plan 9"
-	case "824CC7A0-36A8-11E3-890A-952519AD3F61":
-		return "OpenBSD"
-	case "0FC63DAF-8483-4772-8E79-3D69D8477DE4":
-		return "Linux"
-	default:
-		return guid
 	}
+	if t, ok := partitionTypes[g]; ok {
+		return t.Name
+	}
+	return g.String()
 }
 
-// Converts a GUID to a standard string representation
+// Converts a GUID to the standard RFC 4122 string representation, as printed
+// by tools such as blkid and sgdisk.
 func (g GUID) String() string {
-	return fmt.Sprintf("%0.8X-%0.4X-%0.4X-%0.2X%0.2X-%0.16X", g.TimeLow, g.TimeMid, g.TimeHighAndVersion, g.ClockSeqAndReserved, g.ClockSeqLow, g.Node)
+	b := g.Bytes()
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Parses a GUID in the standard RFC 4122 string representation, such as
+// "C12A7328-F81F-11D2-BA4B-00A0C93EC93B". The hex digits may be in either
+// case, and the GUID may be surrounded by braces (as in the Windows registry)
+// or have no dashes (ie. "C12A7328F81F11D2BA4B00A0C93EC93B".)
+func ParseGUID(s string) (GUID, error) {
+	digits := s
+	if len(digits) >= 2 && digits[0] == '{' && digits[len(digits)-1] == '}' {
+		digits = digits[1 : len(digits)-1]
+	}
+	switch {
+	case len(digits) == 32:
+	case len(digits) == 36 && digits[8] == '-' && digits[13] == '-' && digits[18] == '-' && digits[23] == '-':
+		digits = digits[0:8] + digits[9:13] + digits[14:18] + digits[19:23] + digits[24:]
+	default:
+		return ZeroGUID, errorf(CodeGUID, "Invalid GUID \"%v\"", s)
+	}
+	var b [16]byte
+	if _, err := hex.Decode(b[:], []byte(digits)); err != nil {
+		return ZeroGUID, errorf(CodeGUID, "Invalid GUID \"%v\"", s)
+	}
+	return GUIDFromBytes(b), nil
+}
+
+// Encodes the GUID as its RFC 4122 string representation, so that it's a
+// string in JSON.
+func (g GUID) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+// Decodes a GUID in any of the forms accepted by ParseGUID.
+func (g *GUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseGUID(string(text))
+	if err != nil {
+		return err
+	}
+	*g = parsed
+	return nil
+}
+
+// Returns the GUID in RFC 4122 (big endian) byte order.
+func (g GUID) Bytes() [16]byte {
+	var b [16]byte
+	binary.BigEndian.PutUint32(b[0:4], g.TimeLow)
+	binary.BigEndian.PutUint16(b[4:6], g.TimeMid)
+	binary.BigEndian.PutUint16(b[6:8], g.TimeHighAndVersion)
+	b[8] = g.ClockSeqAndReserved
+	b[9] = g.ClockSeqLow
+	copy(b[10:], g.Node[:])
+	return b
+}
+
+// Returns the GUID in the mixed endian byte order that EFI stores on disk.
+func (g GUID) EFIBytes() [16]byte {
+	var b [16]byte
+	binary.LittleEndian.PutUint32(b[0:4], g.TimeLow)
+	binary.LittleEndian.PutUint16(b[4:6], g.TimeMid)
+	binary.LittleEndian.PutUint16(b[6:8], g.TimeHighAndVersion)
+	b[8] = g.ClockSeqAndReserved
+	b[9] = g.ClockSeqLow
+	copy(b[10:], g.Node[:])
+	return b
+}
+
+// Converts 16 bytes in RFC 4122 (big endian) byte order to a GUID.
+func GUIDFromBytes(b [16]byte) GUID {
+	g := GUID{
+		TimeLow:             binary.BigEndian.Uint32(b[0:4]),
+		TimeMid:             binary.BigEndian.Uint16(b[4:6]),
+		TimeHighAndVersion:  binary.BigEndian.Uint16(b[6:8]),
+		ClockSeqAndReserved: b[8],
+		ClockSeqLow:         b[9],
+	}
+	copy(g.Node[:], b[10:])
+	return g
+}
+
+// Converts 16 bytes in the mixed endian byte order that EFI stores on disk
+// to a GUID.
+func GUIDFromEFIBytes(b [16]byte) GUID {
+	g := GUID{
+		TimeLow:             binary.LittleEndian.Uint32(b[0:4]),
+		TimeMid:             binary.LittleEndian.Uint16(b[4:6]),
+		TimeHighAndVersion:  binary.LittleEndian.Uint16(b[6:8]),
+		ClockSeqAndReserved: b[8],
+		ClockSeqLow:         b[9],
+	}
+	copy(g.Node[:], b[10:])
+	return g
+}
+
+// Generates a new random (version 4) GUID.
+func NewGUID() (GUID, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ZeroGUID, err
+	}
+	// Set the version to 4 and the variant to RFC 4122.
+	b[6] = b[6]&0x0F | 0x40
+	b[8] = b[8]&0x3F | 0x80
+	return GUIDFromBytes(b), nil
+}
+
+// The name space GUIDs defined by RFC 4122, for use with NewV5GUID.
+var (
+	NamespaceDNS  = GUID{0x6ba7b810, 0x9dad, 0x11d1, 0x80, 0xb4, [6]byte{0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}}
+	NamespaceURL  = GUID{0x6ba7b811, 0x9dad, 0x11d1, 0x80, 0xb4, [6]byte{0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}}
+	NamespaceOID  = GUID{0x6ba7b812, 0x9dad, 0x11d1, 0x80, 0xb4, [6]byte{0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}}
+	NamespaceX500 = GUID{0x6ba7b814, 0x9dad, 0x11d1, 0x80, 0xb4, [6]byte{0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}}
+)
+
+// Generates a name based (version 5) GUID from the SHA-1 hash of namespace
+// and name, as described by RFC 4122. The same namespace and name always
+// produce the same GUID, so images built with GUIDs derived from (ie.) their
+// partition labels are identical every time they're built. namespace may be
+// one of the RFC 4122 name spaces, or any GUID chosen for the application.
+func NewV5GUID(namespace GUID, name string) GUID {
+	h := sha1.New()
+	ns := namespace.Bytes()
+	h.Write(ns[:])
+	h.Write([]byte(name))
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	// Set the version to 5 and the variant to RFC 4122.
+	b[6] = b[6]&0x0F | 0x50
+	b[8] = b[8]&0x3F | 0x80
+	return GUIDFromBytes(b)
+}
+
+// Returns the two byte hex code that gdisk uses as a short hand for this
+// partition type, or 0xFFFF if the type has no known code.
+func (g GUID) GdiskCode() uint16 {
+	if t, ok := partitionTypes[g]; ok && t.GdiskCode != 0 {
+		return t.GdiskCode
+	}
+	return 0xFFFF
 }
@@ -0,0 +1,113 @@
+package gpt_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/driusan/gpt"
+)
+
+func TestGUIDByteOrder(t *testing.T) {
+	tests := []struct {
+		s        string
+		guid     gpt.GUID
+		bytes    [16]byte
+		efiBytes [16]byte
+	}{
+		{
+			// The EFI System Partition type, as it's stored on disk by
+			// every EFI firmware.
+			s:        "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+			guid:     gpt.EFISystemPartition,
+			bytes:    [16]byte{0xC1, 0x2A, 0x73, 0x28, 0xF8, 0x1F, 0x11, 0xD2, 0xBA, 0x4B, 0x00, 0xA0, 0xC9, 0x3E, 0xC9, 0x3B},
+			efiBytes: [16]byte{0x28, 0x73, 0x2A, 0xC1, 0x1F, 0xF8, 0xD2, 0x11, 0xBA, 0x4B, 0x00, 0xA0, 0xC9, 0x3E, 0xC9, 0x3B},
+		},
+		{
+			s:        "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+			guid:     gpt.LinuxFilesystem,
+			bytes:    [16]byte{0x0F, 0xC6, 0x3D, 0xAF, 0x84, 0x83, 0x47, 0x72, 0x8E, 0x79, 0x3D, 0x69, 0xD8, 0x47, 0x7D, 0xE4},
+			efiBytes: [16]byte{0xAF, 0x3D, 0xC6, 0x0F, 0x83, 0x84, 0x72, 0x47, 0x8E, 0x79, 0x3D, 0x69, 0xD8, 0x47, 0x7D, 0xE4},
+		},
+		{
+			s:        "00000000-0000-0000-0000-000000000000",
+			guid:     gpt.ZeroGUID,
+			bytes:    [16]byte{},
+			efiBytes: [16]byte{},
+		},
+		{
+			s:        "01234567-89AB-CDEF-0123-456789ABCDEF",
+			guid:     gpt.GUID{0x01234567, 0x89AB, 0xCDEF, 0x01, 0x23, [6]byte{0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF}},
+			bytes:    [16]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF, 0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF},
+			efiBytes: [16]byte{0x67, 0x45, 0x23, 0x01, 0xAB, 0x89, 0xEF, 0xCD, 0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF},
+		},
+	}
+	for _, test := range tests {
+		g, err := gpt.ParseGUID(test.s)
+		if err != nil {
+			t.Errorf("ParseGUID(%q): %v", test.s, err)
+			continue
+		}
+		if g != test.guid {
+			t.Errorf("ParseGUID(%q) = %#v, want %#v", test.s, g, test.guid)
+		}
+		if s := g.String(); s != test.s {
+			t.Errorf("%q.String() = %q", test.s, s)
+		}
+		if b := g.Bytes(); b != test.bytes {
+			t.Errorf("%q.Bytes() = % X, want % X", test.s, b, test.bytes)
+		}
+		if b := g.EFIBytes(); b != test.efiBytes {
+			t.Errorf("%q.EFIBytes() = % X, want % X", test.s, b, test.efiBytes)
+		}
+		if g := gpt.GUIDFromBytes(test.bytes); g != test.guid {
+			t.Errorf("GUIDFromBytes(% X) = %v, want %v", test.bytes, g, test.s)
+		}
+		if g := gpt.GUIDFromEFIBytes(test.efiBytes); g != test.guid {
+			t.Errorf("GUIDFromEFIBytes(% X) = %v, want %v", test.efiBytes, g, test.s)
+		}
+	}
+}
+
+func TestParseGUIDForms(t *testing.T) {
+	tests := []struct {
+		s  string
+		ok bool
+	}{
+		{"C12A7328-F81F-11D2-BA4B-00A0C93EC93B", true},
+		{"c12a7328-f81f-11d2-ba4b-00a0c93ec93b", true},
+		{"{C12A7328-F81F-11D2-BA4B-00A0C93EC93B}", true},
+		{"C12A7328F81F11D2BA4B00A0C93EC93B", true},
+		{"{C12A7328F81F11D2BA4B00A0C93EC93B}", true},
+		{"", false},
+		{"C12A7328-F81F-11D2-BA4B-00A0C93EC93", false},
+		{"C12A7328-F81F-11D2-BA4B-00A0C93EC93B0", false},
+		{"C12A7328F-81F-11D2-BA4B-00A0C93EC93B", false},
+		{"C12A7328-F81F-11D2-BA4B-00A0C93EC93G", false},
+		{"{C12A7328-F81F-11D2-BA4B-00A0C93EC93B", false},
+	}
+	for _, test := range tests {
+		g, err := gpt.ParseGUID(test.s)
+		switch {
+		case test.ok && err != nil:
+			t.Errorf("ParseGUID(%q): %v", test.s, err)
+		case test.ok && g != gpt.EFISystemPartition:
+			t.Errorf("ParseGUID(%q) = %v", test.s, g)
+		case !test.ok && gpt.CodeOf(err) != gpt.CodeGUID:
+			t.Errorf("ParseGUID(%q) = %v, %v, want a %v error", test.s, g, err, gpt.CodeGUID)
+		}
+	}
+}
+
+func TestGUIDJSON(t *testing.T) {
+	b, err := json.Marshal(gpt.EFISystemPartition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"C12A7328-F81F-11D2-BA4B-00A0C93EC93B"` {
+		t.Errorf("Marshal = %s", b)
+	}
+	var g gpt.GUID
+	if err := json.Unmarshal(b, &g); err != nil || g != gpt.EFISystemPartition {
+		t.Errorf("Unmarshal(%s) = %v, %v", b, g, err)
+	}
+}
@@ -0,0 +1,106 @@
+package gpt
+
+import (
+	"fmt"
+)
+
+// A GUIDError describes a problem with the unique GUID of a partition or the
+// disk GUID, such as a GUID shared with another partition, which will cause
+// tools that identify partitions by PARTUUID to pick the wrong one.
+type GUIDError struct {
+	// The index of the partition entry in the table, or -1 for the disk
+	// GUID.
+	Index int
+
+	// The partition's unique GUID.
+	GUID GUID
+
+	// A description of the problem.
+	Problem string
+}
+
+func (e GUIDError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("Disk GUID %v: %v", e.GUID, e.Problem)
+	}
+	return fmt.Sprintf("Partition %d GUID %v: %v", e.Index, e.GUID, e.Problem)
+}
+
+// Returns CodeGUID.
+func (e GUIDError) Code() ErrorCode {
+	return CodeGUID
+}
+
+func (e GUIDError) entry() int {
+	return e.Index
+}
+
+// Returns true if target is ErrGUID.
+func (e GUIDError) Is(target error) bool {
+	return target == ErrGUID
+}
+
+// Checks the disk GUID and the unique GUIDs of all partitions in use in the
+// table, returning a GUIDError for each GUID which is the zero GUID or is
+// shared with an earlier partition. This usually happens when a disk is
+// cloned without regenerating its GUIDs, or was created by a tool which
+// didn't generate them. RegenerateGUIDs fixes both problems.
+func (t *Table) VerifyGUIDs() []error {
+	var errs []error
+	if t.Primary.Disk.IsZero() {
+		errs = append(errs, GUIDError{-1, t.Primary.Disk, "zero GUID"})
+	}
+	seen := make(map[GUID]int)
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		if e.UniqueParitition.IsZero() {
+			errs = append(errs, GUIDError{i, e.UniqueParitition, "zero GUID"})
+			continue
+		}
+		if j, ok := seen[e.UniqueParitition]; ok {
+			errs = append(errs, GUIDError{i, e.UniqueParitition, fmt.Sprintf("duplicate of partition %d", j)})
+			continue
+		}
+		seen[e.UniqueParitition] = i
+	}
+	return errs
+}
+
+// Replaces a zero disk GUID, and the unique GUIDs of partitions which are
+// zero or duplicates of an earlier partition's GUID, with new random GUIDs.
+// The first partition using a duplicated GUID keeps it.
+//
+// Returns the number of GUIDs which were replaced.
+func (t *Table) RegenerateGUIDs() (int, error) {
+	if err := t.checkWritable(); err != nil {
+		return 0, err
+	}
+	n := 0
+	if t.Primary.Disk.IsZero() {
+		g, err := NewGUID()
+		if err != nil {
+			return n, err
+		}
+		t.Primary.Disk = g
+		n++
+	}
+	seen := make(map[GUID]bool)
+	for i := range t.Entries {
+		e := &t.Entries[i]
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		if e.UniqueParitition.IsZero() || seen[e.UniqueParitition] {
+			g, err := NewGUID()
+			if err != nil {
+				return n, err
+			}
+			e.UniqueParitition = g
+			n++
+		}
+		seen[e.UniqueParitition] = true
+	}
+	return n, nil
+}
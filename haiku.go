@@ -0,0 +1,6 @@
+package gpt
+
+// Haiku partition types.
+var (
+	HaikuBFS = registerType("42465331-3BA3-10F1-802A-4861696B7521", "Haiku BFS", 0xEB00, OSHaiku, CategoryData)
+)
@@ -0,0 +1,71 @@
+package gpt
+
+import (
+	"crypto"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// The size of the buffer used when streaming partition contents, and the
+// size of the chunks partitions are copied in.
+const copyBufferSize = 1 << 20
+
+// Counts the bytes written through it as progress, limiting the rate they're
+// written at.
+type progressWriter struct {
+	w io.Writer
+	p *progress
+	l *rateLimiter
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.add(uint64(n))
+	pw.l.wait(uint64(n))
+	return n, err
+}
+
+// Returns the hash of the contents of the partition at index of the table,
+// which was read from dev. The hash function must be linked into the binary
+// (ie. by importing crypto/sha256.) The table's Progress function, if any, is
+// called as the partition is read, which is done at no more than the table's
+// RateLimit.
+func (t *Table) HashPartition(dev io.ReadSeeker, index int, hash crypto.Hash) ([]byte, error) {
+	sums, err := t.hashPartition(dev, index, []crypto.Hash{hash})
+	if err != nil {
+		return nil, err
+	}
+	return sums[0], nil
+}
+
+// Returns the hashes of the contents of the partition at index like
+// HashPartition, reading the partition once for all of them.
+func (t *Table) hashPartition(dev io.ReadSeeker, index int, hashes []crypto.Hash) ([][]byte, error) {
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		if !h.Available() {
+			return nil, errorf(CodeUnsupported, "Hash function %v is not available", h)
+		}
+		writers[i] = h.New()
+	}
+	view, err := t.PartitionView(dev, index)
+	if err != nil {
+		return nil, err
+	}
+	p := newProgress(t.Progress, uint64(view.size))
+	defer p.finish()
+	p.phase(fmt.Sprintf("hashing partition %d", index))
+	n, err := io.CopyBuffer(progressWriter{io.MultiWriter(writers...), p, newRateLimiter(t.RateLimit)}, view, make([]byte, copyBufferSize))
+	if err != nil {
+		return nil, wrapf(CodeIO, err, "Could not read partition %d: %w", index, err)
+	}
+	if n != view.size {
+		return nil, errorf(CodeIO, "Could not read partition %d: %w", index, io.ErrUnexpectedEOF)
+	}
+	sums := make([][]byte, len(hashes))
+	for i, w := range writers {
+		sums[i] = w.(hash.Hash).Sum(nil)
+	}
+	return sums, nil
+}
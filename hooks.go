@@ -0,0 +1,141 @@
+package gpt
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// A PlannedWrite is a region of the disk which an operation is about to
+// overwrite.
+type PlannedWrite struct {
+	// The first logical block written, in blocks of the plan's BlockSize.
+	LBA uint64
+
+	// The data written, which is a whole number of logical blocks.
+	Data []byte
+
+	// What's being written, such as "primary header".
+	Description string
+}
+
+// A Plan describes the writes that a destructive operation is about to make
+// to a disk.
+type Plan struct {
+	// The operation, such as "write table" or the name of a Repair.
+	Operation string
+
+	// The table being written, or nil if the operation doesn't write a
+	// whole table (such as a repair.)
+	Table *Table
+
+	// The size in bytes of the disk's logical blocks.
+	BlockSize uint64
+
+	// The writes, in the order they will be made.
+	Writes []PlannedWrite
+}
+
+// Hooks are callbacks which are invoked around every operation which writes
+// to a disk, so that applications can implement confirmation prompts, audit
+// logs, or wait for udev to settle after the write.
+type Hooks struct {
+	// Called before the writes are made. If it returns an error, the
+	// operation is cancelled and returns that error.
+	Before func(p *Plan) error
+
+	// Called after the writes were made (or failed), with the error from
+	// the operation.
+	After func(p *Plan, err error)
+}
+
+var hooks struct {
+	sync.Mutex
+	list []*Hooks
+}
+
+// Registers h to be called around every write by this package, in the order
+// the hooks were registered. The returned function unregisters them.
+func RegisterHooks(h Hooks) (unregister func()) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hp := &h
+	hooks.list = append(hooks.list, hp)
+	return func() {
+		hooks.Lock()
+		defer hooks.Unlock()
+		for i, other := range hooks.list {
+			if other == hp {
+				hooks.list = append(hooks.list[:i:i], hooks.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Makes the writes in p to hd, calling the registered hooks before and
+// after, and logging each write to log. hd is flushed to stable storage as
+// mode requires before the After hooks are called, so an operation is only
+// reported as done once it's durable. If hd is a block device, it's locked
+// with LockDevice until the writes are done.
+func execute(hd io.WriteSeeker, log *slog.Logger, p *Plan, mode SyncMode) error {
+	lock, err := lockForWrite(hd)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	hooks.Lock()
+	list := hooks.list
+	hooks.Unlock()
+
+	for _, h := range list {
+		if h.Before == nil {
+			continue
+		}
+		if err := h.Before(p); err != nil {
+			log.Debug("write cancelled by hook", "operation", p.Operation, "err", err)
+			return err
+		}
+	}
+	for _, w := range p.Writes {
+		log.Debug("write",
+			"operation", p.Operation,
+			"what", w.Description,
+			"lba", w.LBA,
+			"blocks", BytesToLBAs(uint64(len(w.Data)), p.BlockSize),
+		)
+		if err = writeBlocks(hd, w.LBA, p.BlockSize, w.Data); err != nil {
+			log.Debug("write failed", "lba", w.LBA, "err", err)
+			break
+		}
+		if mode == SyncEach {
+			if err = flushDevice(hd); err != nil {
+				log.Debug("sync failed", "what", w.Description, "err", err)
+				break
+			}
+		}
+	}
+	if err == nil && mode == SyncAtEnd {
+		if err = flushDevice(hd); err != nil {
+			log.Debug("sync failed", "operation", p.Operation, "err", err)
+		}
+	}
+	for _, h := range list {
+		if h.After != nil {
+			h.After(p, err)
+		}
+	}
+	return err
+}
+
+// Flushes the writes made to dev to stable storage, if it supports it.
+// Files use fdatasync where it's available, since the file's metadata
+// doesn't change when a table is written.
+func flushDevice(dev io.Writer) error {
+	if f, ok := dev.(*os.File); ok {
+		return fdatasync(f)
+	}
+	return syncDevice(dev)
+}
@@ -0,0 +1,154 @@
+package gpt
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// The minimum number of bytes fetched by each request of an HTTPDevice, so
+// that reading a partition table a block at a time doesn't make a request
+// per block.
+const httpReadAhead = 64 << 10
+
+// An HTTPDevice is a read only BlockDevice for a disk image on an HTTP
+// server, which is read with range requests so that only the parts of the
+// image which are needed are downloaded. The server must support range
+// requests.
+//
+// An HTTPDevice is not safe for concurrent use.
+type HTTPDevice struct {
+	url    string
+	client *http.Client
+	size   int64
+	pos    int64
+
+	// The most recently fetched region of the image.
+	cache    []byte
+	cacheOff int64
+}
+
+// Opens the disk image at url. The image's size is taken from the response
+// to a HEAD request, which must advertise support for range requests.
+func OpenHTTP(url string) (*HTTPDevice, error) {
+	d := &HTTPDevice{url: url, client: http.DefaultClient}
+	resp, err := d.client.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorf(CodeIO, "Could not open %v: %v", url, resp.Status)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return nil, errorf(CodeUnsupported, "Server for %v does not support range requests", url)
+	}
+	if resp.ContentLength < 0 {
+		return nil, errorf(CodeUnsupported, "Server for %v did not send the size of the image", url)
+	}
+	d.size = resp.ContentLength
+	return d, nil
+}
+
+// Returns the size of the image in bytes.
+func (d *HTTPDevice) Size() int64 {
+	return d.size
+}
+
+// Reads len(p) bytes from off, fetching them from the server if they aren't
+// in the most recently fetched region.
+func (d *HTTPDevice) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errorf(CodeInvalidArgument, "Invalid offset %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= d.size {
+			return n, io.EOF
+		}
+		if pos < d.cacheOff || pos >= d.cacheOff+int64(len(d.cache)) {
+			if err := d.fetch(pos, max(int64(len(p)-n), httpReadAhead)); err != nil {
+				return n, err
+			}
+		}
+		n += copy(p[n:], d.cache[pos-d.cacheOff:])
+	}
+	return n, nil
+}
+
+// Fetches n bytes starting at off into the cache.
+func (d *HTTPDevice) fetch(off, n int64) error {
+	n = min(n, d.size-off)
+	req, err := http.NewRequest(http.MethodGet, d.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-"+strconv.FormatInt(off+n-1, 10))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return errorf(CodeIO, "Could not read bytes %d-%d of %v: %v", off, off+n-1, d.url, resp.Status)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(resp.Body, buf); err != nil {
+		return wrapf(CodeIO, err, "Could not read bytes %d-%d of %v: %w", off, off+n-1, d.url, err)
+	}
+	d.cache, d.cacheOff = buf, off
+	return nil
+}
+
+// Reads from the current position in the image.
+func (d *HTTPDevice) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	if remaining := d.size - d.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := d.ReadAt(p, d.pos)
+	d.pos += int64(n)
+	return n, err
+}
+
+// Sets the position for the next Read.
+func (d *HTTPDevice) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return d.pos, errorf(CodeInvalidArgument, "Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return d.pos, errorf(CodeInvalidArgument, "Invalid offset %d", offset)
+	}
+	d.pos = offset
+	return offset, nil
+}
+
+// Returns ErrReadOnly, since an HTTPDevice can't be written to.
+func (d *HTTPDevice) Write(p []byte) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// Returns ErrReadOnly, since an HTTPDevice can't be written to.
+func (d *HTTPDevice) WriteAt(p []byte, off int64) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// Does nothing, since an HTTPDevice can't be written to.
+func (d *HTTPDevice) Sync() error {
+	return nil
+}
+
+// Releases the cached region of the image.
+func (d *HTTPDevice) Close() error {
+	d.cache = nil
+	return nil
+}
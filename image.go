@@ -0,0 +1,100 @@
+package gpt
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// An ImageFormat is a disk image container format, such as qcow2, which
+// OpenImage can detect and open. The qcow2, vmdk, vhd and ewf packages each
+// provide one as their Format variable.
+type ImageFormat struct {
+	// The name of the format, such as "qcow2".
+	Name string
+
+	// Returns true if r, which is size bytes long, is an image in this
+	// format. This is normally a check of the format's magic number.
+	Match func(r io.ReaderAt, size int64) bool
+
+	// Opens the image at path, for reading and writing if rw is set.
+	Open func(path string, rw bool) (BlockDevice, error)
+}
+
+var (
+	imageFormatsMu sync.Mutex
+	imageFormats   []ImageFormat
+)
+
+// Registers an image format for OpenImage and OpenImageRW. Formats are tried
+// in the order that they're registered, and a file which isn't in any
+// registered format is opened as a raw image.
+//
+// For example, to open qcow2 and VMDK images as well as raw images:
+//
+//	gpt.RegisterImageFormat(qcow2.Format)
+//	gpt.RegisterImageFormat(vmdk.Format)
+func RegisterImageFormat(f ImageFormat) {
+	imageFormatsMu.Lock()
+	defer imageFormatsMu.Unlock()
+	imageFormats = append(imageFormats, f)
+}
+
+// Returns the name of the registered format of the image at path, or "raw" if
+// it isn't in any registered format.
+func DetectImageFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if format, ok := detectImageFormat(f); ok {
+		return format.Name, nil
+	}
+	return "raw", nil
+}
+
+// Returns the first registered format which matches f.
+func detectImageFormat(f *os.File) (ImageFormat, bool) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ImageFormat{}, false
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ImageFormat{}, false
+	}
+	imageFormatsMu.Lock()
+	formats := imageFormats
+	imageFormatsMu.Unlock()
+	for _, format := range formats {
+		if format.Match(f, size) {
+			return format, true
+		}
+	}
+	return ImageFormat{}, false
+}
+
+// Opens the disk image or block device at path for reading, detecting its
+// format from the registered formats. The returned device is the virtual disk
+// inside the image.
+func OpenImage(path string) (BlockDevice, error) {
+	return openImage(path, os.O_RDONLY)
+}
+
+// Opens the disk image or block device at path for reading and writing, like
+// OpenImage.
+func OpenImageRW(path string) (BlockDevice, error) {
+	return openImage(path, os.O_RDWR)
+}
+
+func openImage(path string, flags int) (BlockDevice, error) {
+	f, err := os.OpenFile(path, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	if format, ok := detectImageFormat(f); ok {
+		f.Close()
+		return format.Open(path, flags == os.O_RDWR)
+	}
+	return f, nil
+}
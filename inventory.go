@@ -0,0 +1,138 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// An Inventory records the partition tables of the disks on a machine. It's
+// encoded as JSON with encoding/json, so that the inventories of many
+// machines can be gathered and compared with FindDuplicates.
+type Inventory struct {
+	// The host name of the machine.
+	Host string `json:"host"`
+
+	Disks []InventoryDisk `json:"disks"`
+}
+
+// An InventoryDisk records the partition table of a single disk.
+type InventoryDisk struct {
+	// The device node of the disk (ie. /dev/sda.)
+	Path string `json:"path"`
+
+	// The disk GUID from the primary header.
+	GUID GUID `json:"guid"`
+
+	// The used partition entries.
+	Partitions []InventoryPartition `json:"partitions"`
+}
+
+// An InventoryPartition records a single partition in an InventoryDisk.
+type InventoryPartition struct {
+	Index int    `json:"index"`
+	GUID  GUID   `json:"guid"`
+	Type  GUID   `json:"type"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Reads the partition tables of all disks on the machine. Disks which can't
+// be read, or which don't have a GPT, are skipped.
+func TakeInventory(ctx context.Context) (*Inventory, error) {
+	disks, err := listDisks()
+	if err != nil {
+		return nil, err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	inv := &Inventory{Host: host}
+	for _, disk := range disks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d, err := inventoryDisk(disk)
+		if err != nil {
+			continue
+		}
+		inv.Disks = append(inv.Disks, d)
+	}
+	return inv, nil
+}
+
+// Reads the partition table of a single disk for an inventory.
+func inventoryDisk(disk string) (InventoryDisk, error) {
+	d := InventoryDisk{Path: disk}
+	f, err := os.Open(disk)
+	if err != nil {
+		return d, err
+	}
+	defer f.Close()
+	t, err := ReadTable(f)
+	if err != nil {
+		return d, err
+	}
+	d.GUID = t.Primary.Disk
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		d.Partitions = append(d.Partitions, InventoryPartition{
+			Index: i,
+			GUID:  e.UniqueParitition,
+			Type:  e.PartitionType,
+			Name:  e.GetName(),
+		})
+	}
+	return d, nil
+}
+
+// A GUIDUse is somewhere that a GUID is used as a disk or partition GUID.
+type GUIDUse struct {
+	Host, Disk string
+
+	// The index of the partition which uses the GUID, or -1 if it's the
+	// disk GUID.
+	Index int
+}
+
+// Returns a description of the use, such as "host:/dev/sda partition 1".
+func (u GUIDUse) String() string {
+	if u.Index < 0 {
+		return fmt.Sprintf("%s:%s disk", u.Host, u.Disk)
+	}
+	return fmt.Sprintf("%s:%s partition %d", u.Host, u.Disk, u.Index)
+}
+
+// A Duplicate is a GUID which is used by more than one disk or partition.
+type Duplicate struct {
+	GUID GUID
+	Uses []GUIDUse
+}
+
+// Returns the GUIDs which are used by more than one disk or partition in the
+// inventories, sorted by GUID. Disk GUIDs and partition GUIDs are compared
+// with each other, since they're all meant to be unique. Zero GUIDs aren't
+// reported, since they're an error on a single disk (which Verify reports)
+// rather than a collision.
+func FindDuplicates(inventories []*Inventory) []Duplicate {
+	uses := make(map[GUID][]GUIDUse)
+	for _, inv := range inventories {
+		for _, d := range inv.Disks {
+			uses[d.GUID] = append(uses[d.GUID], GUIDUse{inv.Host, d.Path, -1})
+			for _, p := range d.Partitions {
+				uses[p.GUID] = append(uses[p.GUID], GUIDUse{inv.Host, d.Path, p.Index})
+			}
+		}
+	}
+	var dups []Duplicate
+	for g, u := range uses {
+		if len(u) > 1 && !g.IsZero() {
+			dups = append(dups, Duplicate{g, u})
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].GUID.Compare(dups[j].GUID) < 0 })
+	return dups
+}
@@ -0,0 +1,72 @@
+package gpt
+
+import (
+	"iter"
+	"sort"
+)
+
+// A FreeRegion is a run of logical blocks in the usable area of the disk
+// which isn't allocated to any partition.
+type FreeRegion struct {
+	FirstLBA, LastLBA uint64
+}
+
+// Returns the number of logical blocks in the region.
+func (r FreeRegion) Blocks() uint64 {
+	return r.LastLBA - r.FirstLBA + 1
+}
+
+// Returns an iterator over the index and value of every entry in the table,
+// including unused entries.
+func (t *Table) All() iter.Seq2[int, GPTPartitionEntry] {
+	return func(yield func(int, GPTPartitionEntry) bool) {
+		for i, e := range t.Entries {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over the index and value of the entries in the table
+// which are in use, in the order of the table.
+func (t *Table) Used() iter.Seq2[int, GPTPartitionEntry] {
+	return func(yield func(int, GPTPartitionEntry) bool) {
+		for i, e := range t.Entries {
+			if e.PartitionType.IsZero() {
+				continue
+			}
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over the regions of the usable area which aren't
+// allocated to any partition, in order of their location on the disk.
+func (t *Table) Free() iter.Seq[FreeRegion] {
+	return func(yield func(FreeRegion) bool) {
+		var used []int
+		for i := range t.Used() {
+			used = append(used, i)
+		}
+		sort.Slice(used, func(i, j int) bool {
+			return t.Entries[used[i]].StartingLBA < t.Entries[used[j]].StartingLBA
+		})
+		next := t.Primary.FirstUseableLBA
+		for _, i := range used {
+			e := t.Entries[i]
+			if e.StartingLBA > next && !yield(FreeRegion{next, min(e.StartingLBA-1, t.Primary.LastUseableLBA)}) {
+				return
+			}
+			next = max(next, e.EndingLBA+1)
+			if next > t.Primary.LastUseableLBA {
+				return
+			}
+		}
+		if next <= t.Primary.LastUseableLBA {
+			yield(FreeRegion{next, t.Primary.LastUseableLBA})
+		}
+	}
+}
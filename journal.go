@@ -0,0 +1,179 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/fs"
+	"os"
+)
+
+// The operations which can be journaled.
+type JournalOperation uint32
+
+const (
+	// A partition being moved with MovePartition.
+	JournalMove JournalOperation = iota + 1
+
+	// A partition being copied with CopyPartitionWithOptions.
+	JournalCopy
+)
+
+// How often, in bytes copied, the progress of a journaled operation is
+// recorded. The destination is synced before each record, so this is a
+// trade off between the work redone after an interruption and the time
+// spent syncing.
+const journalInterval = 64 << 20
+
+// The signature at the start of a journal file.
+const journalSignature = "GPT JRNL"
+
+// A Journal records the progress of a partition move or copy in a small file,
+// so that an operation which is interrupted (ie. by a power loss) can be
+// detected and resumed or rolled back later.
+type Journal struct {
+	Operation JournalOperation
+
+	// The indexes of the source and destination partitions. For a move,
+	// they're the same.
+	SrcIndex, DstIndex int
+
+	// The extents, in LBAs, being copied from and to.
+	SrcStart, SrcEnd uint64
+	DstStart, DstEnd uint64
+
+	// The number of bytes which have been copied to the destination and
+	// synced.
+	Done uint64
+
+	f *os.File
+}
+
+// The on disk format of a journal.
+type journalRecord struct {
+	Signature          [8]byte
+	Operation          JournalOperation
+	SrcIndex, DstIndex uint32
+	SrcStart, SrcEnd   uint64
+	DstStart, DstEnd   uint64
+	Done               uint64
+	CRC32              uint32
+}
+
+// Reads the journal at path. If there's no journal, the returned error
+// satisfies errors.Is(err, fs.ErrNotExist).
+func ReadJournal(path string) (*Journal, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r journalRecord
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &r); err != nil {
+		return nil, errorf(CodeJournal, "Invalid journal %v: %w", path, err)
+	}
+	if string(r.Signature[:]) != journalSignature {
+		return nil, errorf(CodeJournal, "Invalid journal %v: bad signature", path)
+	}
+	if crc := r.CRC32; crc != r.computeCRC() {
+		return nil, errorf(CodeJournal, "Invalid journal %v: bad CRC", path)
+	}
+	return &Journal{
+		Operation: r.Operation,
+		SrcIndex:  int(r.SrcIndex),
+		DstIndex:  int(r.DstIndex),
+		SrcStart:  r.SrcStart,
+		SrcEnd:    r.SrcEnd,
+		DstStart:  r.DstStart,
+		DstEnd:    r.DstEnd,
+		Done:      r.Done,
+	}, nil
+}
+
+// Returns the CRC of the record, computed with the CRC field zeroed.
+func (r journalRecord) computeCRC() uint32 {
+	r.CRC32 = 0
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, r)
+	return crc32.ChecksumIEEE(buf.Bytes())
+}
+
+// Creates a new journal at path for j, which must not already exist.
+func createJournal(path string, j *Journal) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return wrapf(CodeIO, err, "Could not create journal: %w", err)
+	}
+	j.f = f
+	return j.update(j.Done)
+}
+
+// Returns the journal at path if it records the same operation as want, or
+// creates a new journal for want if there's none.
+func resumeJournal(path string, want *Journal) (*Journal, error) {
+	j, err := ReadJournal(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return want, createJournal(path, want)
+	} else if err != nil {
+		return nil, err
+	}
+	if !j.matches(want) {
+		return nil, errorf(CodeJournal, "Journal %v is for a different operation", path)
+	}
+	return j, j.open(path)
+}
+
+// Opens the existing journal at path, so that j can be updated.
+func (j *Journal) open(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	j.f = f
+	return nil
+}
+
+// Records that done bytes have been copied and synced.
+func (j *Journal) update(done uint64) error {
+	j.Done = done
+	r := journalRecord{
+		Operation: j.Operation,
+		SrcIndex:  uint32(j.SrcIndex),
+		DstIndex:  uint32(j.DstIndex),
+		SrcStart:  j.SrcStart,
+		SrcEnd:    j.SrcEnd,
+		DstStart:  j.DstStart,
+		DstEnd:    j.DstEnd,
+		Done:      done,
+	}
+	copy(r.Signature[:], journalSignature)
+	r.CRC32 = r.computeCRC()
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, r)
+	if _, err := j.f.WriteAt(buf.Bytes(), 0); err != nil {
+		return wrapf(CodeIO, err, "Could not update journal: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return wrapf(CodeIO, err, "Could not update journal: %w", err)
+	}
+	return nil
+}
+
+// Removes the journal once the operation is complete.
+func (j *Journal) remove() error {
+	j.f.Close()
+	return os.Remove(j.f.Name())
+}
+
+// Closes the journal, leaving it in place so the operation can be resumed.
+func (j *Journal) close() {
+	if j != nil && j.f != nil {
+		j.f.Close()
+	}
+}
+
+// Returns true if j records the same operation as o, ignoring its progress.
+func (j *Journal) matches(o *Journal) bool {
+	return j.Operation == o.Operation && j.SrcIndex == o.SrcIndex && j.DstIndex == o.DstIndex &&
+		j.SrcStart == o.SrcStart && j.SrcEnd == o.SrcEnd && j.DstStart == o.DstStart && j.DstEnd == o.DstEnd
+}
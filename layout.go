@@ -0,0 +1,58 @@
+package gpt
+
+import (
+	"sort"
+)
+
+// A Layout is a declarative description of the partitions that should be on
+// a disk, which is independent of the size of any particular disk.
+type Layout struct {
+	Partitions []PartitionSpec
+}
+
+// A PartitionSpec describes a single partition in a Layout.
+type PartitionSpec struct {
+	// The partition type.
+	Type GUID
+
+	// The partition name. May be empty.
+	Name string
+
+	// The unique GUID of the partition. If this is the ZeroGUID, a
+	// random GUID is generated when the partition is created.
+	UUID GUID
+
+	// The minimum and maximum size of the partition, in bytes. A MaxSize
+	// of zero means that the partition has no maximum size.
+	MinSize, MaxSize uint64
+
+	// The attributes of the partition.
+	Attributes GPTPartitionAttribute
+}
+
+// Returns a Layout describing the partitions that are currently in the table,
+// in the order that they appear on disk. Each partition's minimum and maximum
+// size is its current size.
+func (t *Table) Layout() Layout {
+	var used []GPTPartitionEntry
+	for _, e := range t.Entries {
+		if !e.PartitionType.IsZero() {
+			used = append(used, e)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].StartingLBA < used[j].StartingLBA })
+
+	var l Layout
+	for _, e := range used {
+		size := e.SizeBytes(t.BlockSize())
+		l.Partitions = append(l.Partitions, PartitionSpec{
+			Type:       e.PartitionType,
+			Name:       e.GetName(),
+			UUID:       e.UniqueParitition,
+			MinSize:    size,
+			MaxSize:    size,
+			Attributes: e.Attributes,
+		})
+	}
+	return l
+}
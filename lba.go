@@ -0,0 +1,66 @@
+package gpt
+
+// Returns the last LBA at or before lba which is a multiple of align logical
+// blocks. An align of 0 or 1 returns lba. See NextAligned for the first one
+// at or after lba.
+func PrevAligned(lba, align uint64) uint64 {
+	if align <= 1 {
+		return lba
+	}
+	return lba - lba%align
+}
+
+// Returns the number of logical blocks of sectorSize bytes needed to hold n
+// bytes, rounding up to a whole block. A sectorSize of 0 holds nothing, so
+// the result is 0.
+func BytesToLBAs(n, sectorSize uint64) uint64 {
+	if sectorSize == 0 {
+		return 0
+	}
+	blocks := n / sectorSize
+	if n%sectorSize != 0 {
+		blocks++
+	}
+	return blocks
+}
+
+// Returns the offset in bytes of the start of the logical block lba from the
+// start of a disk with logical blocks of sectorSize bytes.
+func LBAToBytes(lba, sectorSize uint64) uint64 {
+	return lba * sectorSize
+}
+
+// Returns the number of logical blocks in the range from first to last. Like
+// a partition's StartingLBA and EndingLBA, both ends are included, so a range
+// from LBA 34 to 34 is one block long. A range which ends before it starts is
+// empty.
+func RangeLength(first, last uint64) uint64 {
+	if last < first {
+		return 0
+	}
+	return last - first + 1
+}
+
+// Returns true if the inclusive ranges of logical blocks from aFirst to aLast
+// and bFirst to bLast have any blocks in common.
+func RangesOverlap(aFirst, aLast, bFirst, bLast uint64) bool {
+	_, _, ok := RangeIntersection(aFirst, aLast, bFirst, bLast)
+	return ok
+}
+
+// Returns the inclusive range of logical blocks which are in both the range
+// from aFirst to aLast and from bFirst to bLast. The result is false if they
+// have no blocks in common, or either range is empty.
+func RangeIntersection(aFirst, aLast, bFirst, bLast uint64) (first, last uint64, ok bool) {
+	first, last = max(aFirst, bFirst), min(aLast, bLast)
+	if aLast < aFirst || bLast < bFirst || last < first {
+		return 0, 0, false
+	}
+	return first, last, true
+}
+
+// Returns true if the partitions e and other have any logical blocks in
+// common.
+func (e GPTPartitionEntry) Overlaps(other GPTPartitionEntry) bool {
+	return RangesOverlap(e.StartingLBA, e.EndingLBA, other.StartingLBA, other.EndingLBA)
+}
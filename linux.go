@@ -0,0 +1,11 @@
+package gpt
+
+// Linux partition types which aren't defined by the Discoverable Partitions
+// Specification.
+var (
+	LinuxLVM      = registerType("E6D6D379-F507-44C2-A23C-238F2A3DF928", "Linux LVM", 0x8E00, OSLinux, CategoryVolumeManager)
+	LinuxRAID     = registerType("A19D880F-05FC-4D3B-A006-743F0F84911E", "Linux RAID", 0xFD00, OSLinux, CategoryRAID)
+	LinuxLUKS     = registerType("CA7D7CCB-63ED-4C53-861C-1742536059CC", "Linux LUKS", 0x8309, OSLinux, CategoryEncrypted)
+	LinuxDMCrypt  = registerType("7FFEC5C9-2D00-49B7-8941-3EA10A5586B7", "Linux dm-crypt", 0x8308, OSLinux, CategoryEncrypted)
+	LinuxReserved = registerType("8DA63339-0007-60C0-C436-083AC8230908", "Linux reserved", 0x8301, OSLinux, CategoryReserved)
+)
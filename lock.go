@@ -0,0 +1,63 @@
+package gpt
+
+import (
+	"os"
+	"sync"
+)
+
+// A DeviceLock is an exclusive advisory lock on a disk, which prevents udev,
+// util-linux tools such as sfdisk, and other instances of this package from
+// reading or modifying the disk while the GPT is being written.
+//
+// A disk which is already locked by the process can be locked again, such as
+// by the writes of a Disk opened with OpenRW, and stays locked until every
+// DeviceLock on it is unlocked.
+type DeviceLock struct {
+	dev  string
+	held *heldLock
+}
+
+// A lock on a disk held by this process, shared by each DeviceLock on it.
+type heldLock struct {
+	f    *os.File
+	refs int
+}
+
+// The locks held by this process, by the whole disk device which is locked.
+// flock(2) locks are held by an open file, so locking a disk again through
+// another open file would wait forever for the process's own lock.
+var heldLocks struct {
+	sync.Mutex
+	m map[string]*heldLock
+}
+
+// Releases the lock. Unlocking a nil or already unlocked DeviceLock does
+// nothing.
+func (l *DeviceLock) Unlock() error {
+	if l == nil || l.held == nil {
+		return nil
+	}
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	h := l.held
+	l.held = nil
+	if h.refs--; h.refs > 0 {
+		return nil
+	}
+	delete(heldLocks.m, l.dev)
+	return h.f.Close()
+}
+
+// Locks the disk that hd is, for the duration of a write, if it's a block
+// device. Other devices, such as disk images, aren't locked, and the returned
+// lock is nil.
+func lockForWrite(hd any) (*DeviceLock, error) {
+	f, ok := hd.(*os.File)
+	if !ok {
+		return nil, nil
+	}
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeDevice == 0 || fi.Mode()&os.ModeCharDevice != 0 {
+		return nil, nil
+	}
+	return LockDevice(f.Name())
+}
@@ -0,0 +1,74 @@
+package gpt
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Takes an exclusive lock on the disk at path, waiting for any other holder
+// to release it. If path is a partition, the whole disk device that it's on
+// is locked instead, since that's the device that udev and util-linux lock.
+//
+// The lock is a BSD file lock (flock(2)) on the device node, following the
+// convention used by systemd-udevd and util-linux's --lock option. If the
+// process already holds a lock on the disk, it's shared rather than waited
+// for.
+func LockDevice(path string) (*DeviceLock, error) {
+	dev, err := wholeDisk(path)
+	if err != nil {
+		return nil, err
+	}
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	if h, ok := heldLocks.m[dev]; ok {
+		h.refs++
+		return &DeviceLock{dev: dev, held: h}, nil
+	}
+	f, err := os.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, wrapf(CodeIO, err, "Could not lock %v: %w", dev, err)
+	}
+	h := &heldLock{f: f, refs: 1}
+	if heldLocks.m == nil {
+		heldLocks.m = make(map[string]*heldLock)
+	}
+	heldLocks.m[dev] = h
+	return &DeviceLock{dev: dev, held: h}, nil
+}
+
+// Returns the whole disk device containing path, if path is a partition's
+// block device. Otherwise returns path unmodified.
+func wholeDisk(path string) (string, error) {
+	dev, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	st, err := os.Stat(dev)
+	if err != nil {
+		return "", err
+	}
+	if st.Mode()&os.ModeDevice == 0 || st.Mode()&os.ModeCharDevice != 0 {
+		return dev, nil
+	}
+	sys := filepath.Join(sysBlockDir, filepath.Base(dev))
+	if _, err := os.Stat(filepath.Join(sys, "partition")); err != nil {
+		return dev, nil
+	}
+	// The partition's sysfs directory is inside the disk's.
+	sys, err = filepath.EvalSymlinks(sys)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join("/dev", filepath.Base(filepath.Dir(sys))), nil
+}
@@ -0,0 +1,35 @@
+package gpt
+
+import (
+	"testing"
+)
+
+func TestLockDeviceShared(t *testing.T) {
+	// LockDevice only needs a file that can be opened, so the test binary
+	// itself stands in for a disk.
+	path := "/proc/self/exe"
+	a, err := LockDevice(path)
+	if err != nil {
+		t.Skip(err)
+	}
+	// Without sharing the lock, this would wait forever for a.
+	b, err := LockDevice(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.held != a.held {
+		t.Fatal("second lock by the same process didn't share the first")
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := heldLocks.m[a.dev]; !ok {
+		t.Fatal("lock released while still held")
+	}
+	if err := b.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := heldLocks.m[a.dev]; ok {
+		t.Fatal("lock not released")
+	}
+}
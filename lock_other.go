@@ -0,0 +1,11 @@
+//go:build !linux
+
+package gpt
+
+// Takes an exclusive lock on the disk at path.
+//
+// BUG(driusan): LockDevice is only implemented on Linux. On other
+// operating systems it returns a lock which does nothing.
+func LockDevice(path string) (*DeviceLock, error) {
+	return &DeviceLock{}, nil
+}
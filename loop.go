@@ -0,0 +1,16 @@
+package gpt
+
+// A LoopDevice is an image file attached to a loop block device, so that the
+// partitions in the image's GPT can be formatted or mounted.
+//
+// Use (*Table).PartitionDevice with the loop device's Path to find the
+// device node of a partition.
+type LoopDevice struct {
+	// The loop device node, ie. "/dev/loop0".
+	Path string
+
+	// The image file which is attached to the device.
+	Image string
+
+	fd uintptr
+}
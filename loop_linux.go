@@ -0,0 +1,123 @@
+package gpt
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ioctls from <linux/loop.h> and <linux/fs.h>.
+const (
+	loopSetFD       = 0x4C00
+	loopClearFD     = 0x4C01
+	loopSetStatus64 = 0x4C04
+	loopCtlGetFree  = 0x4C82
+	blkRRPart       = 0x125F
+
+	loFlagsPartScan = 8
+)
+
+// struct loop_info64 from <linux/loop.h>
+type loopInfo64 struct {
+	Device         uint64
+	Inode          uint64
+	RDevice        uint64
+	Offset         uint64
+	SizeLimit      uint64
+	Number         uint32
+	EncryptType    uint32
+	EncryptKeySize uint32
+	Flags          uint32
+	FileName       [64]byte
+	CryptName      [64]byte
+	EncryptKey     [32]byte
+	Init           [2]uint64
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Attaches the image file to a free loop device, with partition scanning
+// enabled so that the kernel creates device nodes for the partitions in
+// the image's GPT. If readOnly is true, the loop device is read only.
+//
+// The loop device stays attached until Detach is called.
+func AttachLoop(image string, readOnly bool) (*LoopDevice, error) {
+	flags := os.O_RDWR
+	if readOnly {
+		flags = os.O_RDONLY
+	}
+	img, err := os.OpenFile(image, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The loop device holds its own reference to the image once it's
+	// attached.
+	defer img.Close()
+
+	ctl, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer ctl.Close()
+
+	// Another process may take the free device between
+	// LOOP_CTL_GET_FREE and LOOP_SET_FD, in which case LOOP_SET_FD fails
+	// with EBUSY and we try the next free device.
+	for tries := 0; tries < 10; tries++ {
+		n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ctl.Fd(), loopCtlGetFree, 0)
+		if errno != 0 {
+			return nil, wrapf(CodeIO, errno, "Could not get a free loop device: %w", errno)
+		}
+		path := fmt.Sprintf("/dev/loop%d", n)
+		fd, err := syscall.Open(path, flags|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioctl(uintptr(fd), loopSetFD, img.Fd()); err != nil {
+			syscall.Close(fd)
+			if err == syscall.EBUSY {
+				continue
+			}
+			return nil, wrapf(CodeIO, err, "Could not attach %v to %v: %w", image, path, err)
+		}
+
+		info := loopInfo64{Flags: loFlagsPartScan}
+		copy(info.FileName[:len(info.FileName)-1], image)
+		if err := ioctl(uintptr(fd), loopSetStatus64, uintptr(unsafe.Pointer(&info))); err != nil {
+			ioctl(uintptr(fd), loopClearFD, 0)
+			syscall.Close(fd)
+			return nil, wrapf(CodeIO, err, "Could not enable partition scanning on %v: %w", path, err)
+		}
+		return &LoopDevice{Path: path, Image: image, fd: uintptr(fd)}, nil
+	}
+	return nil, errorf(CodeNotFound, "Could not find a free loop device")
+}
+
+// Asks the kernel to reread the partition table of the loop device, after
+// the GPT has been modified through it.
+func (l *LoopDevice) Rescan() error {
+	return ioctl(l.fd, blkRRPart, 0)
+}
+
+// Detaches the image from the loop device. The kernel defers the detach
+// until any partitions which are mounted or open are closed.
+func (l *LoopDevice) Detach() error {
+	err := ioctl(l.fd, loopClearFD, 0)
+	// LOOP_CLR_FD fails with EBUSY if the device is still open elsewhere.
+	// Retry briefly, since udev may still be probing the new partitions.
+	for tries := 0; err == syscall.EBUSY && tries < 10; tries++ {
+		time.Sleep(100 * time.Millisecond)
+		err = ioctl(l.fd, loopClearFD, 0)
+	}
+	if cerr := syscall.Close(int(l.fd)); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package gpt
+
+// Attaches the image file to a free loop device.
+//
+// BUG(driusan): Loop devices are only supported on Linux.
+func AttachLoop(image string, readOnly bool) (*LoopDevice, error) {
+	return nil, errorf(CodeUnsupported, "Loop devices are not supported on this operating system")
+}
+
+// Asks the kernel to reread the partition table of the loop device.
+func (l *LoopDevice) Rescan() error {
+	return errorf(CodeUnsupported, "Loop devices are not supported on this operating system")
+}
+
+// Detaches the image from the loop device.
+func (l *LoopDevice) Detach() error {
+	return errorf(CodeUnsupported, "Loop devices are not supported on this operating system")
+}
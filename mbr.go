@@ -0,0 +1,209 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The MBR partition type of the partition in a protective MBR which covers
+// the GPT.
+const MBRProtectiveType = 0xEE
+
+// An MBRPartition is one of the four partition records in an MBR.
+type MBRPartition struct {
+	// 0x80 if the partition is bootable.
+	Status byte
+
+	// The cylinder, head and sector address of the first block. Unused by
+	// anything modern, but checked by some BIOSes.
+	FirstCHS CHS
+
+	// The MBR partition type, or 0 if the record is unused.
+	Type byte
+
+	// The cylinder, head and sector address of the last block.
+	LastCHS CHS
+
+	// The first logical block and number of blocks in the partition.
+	FirstLBA uint32
+	Sectors  uint32
+}
+
+// The geometry which CHS addresses are converted with, which is what every
+// BIOS since the 1990s reports for a disk using LBA translation.
+const (
+	chsHeads   = 255
+	chsSectors = 63
+)
+
+// A CHS is a cylinder, head and sector address, as stored in an MBR partition
+// record. The head is in the first byte, the sector in the low 6 bits of the
+// second byte, and the cylinder in the top 2 bits of the second byte and the
+// third byte.
+type CHS [3]byte
+
+// Returns the CHS address of lba. Addresses past cylinder 1023, which can't
+// be represented, are saturated to 1023/254/63 as done by fdisk and gdisk.
+func NewCHS(lba uint64) CHS {
+	c := lba / (chsHeads * chsSectors)
+	if c > 1023 {
+		return CHS{254, 0xFF, 0xFF}
+	}
+	h := lba / chsSectors % chsHeads
+	s := lba%chsSectors + 1
+	return CHS{byte(h), byte(s) | byte(c>>2&0xC0), byte(c)}
+}
+
+// Returns the cylinder of the address.
+func (c CHS) Cylinder() uint16 {
+	return uint16(c[1]&0xC0)<<2 | uint16(c[2])
+}
+
+// Returns the head of the address.
+func (c CHS) Head() uint8 {
+	return c[0]
+}
+
+// Returns the sector of the address, which starts at 1.
+func (c CHS) Sector() uint8 {
+	return c[1] & 0x3F
+}
+
+// Returns the LBA of the address. ok is false if the address is invalid (its
+// sector is 0 or its head or sector are outside the geometry), or saturated,
+// in which case the LBA can't be determined from it.
+func (c CHS) LBA() (lba uint64, ok bool) {
+	if c.Sector() == 0 || c.Head() >= chsHeads || c == NewCHS(1<<32) {
+		return 0, false
+	}
+	return (uint64(c.Cylinder())*chsHeads+uint64(c.Head()))*chsSectors + uint64(c.Sector()) - 1, true
+}
+
+// Returns the address formatted as cylinder/head/sector.
+func (c CHS) String() string {
+	return fmt.Sprintf("%d/%d/%d", c.Cylinder(), c.Head(), c.Sector())
+}
+
+// Sets the partition to start at first and be sectors blocks long, setting
+// the CHS addresses to match.
+func (p *MBRPartition) SetRange(first, sectors uint32) {
+	p.FirstLBA, p.Sectors = first, sectors
+	p.FirstCHS = NewCHS(uint64(first))
+	p.LastCHS = NewCHS(uint64(first) + uint64(sectors) - 1)
+}
+
+// An MBR is the master boot record in the first logical block of a disk. On a
+// GPT disk, it should be a protective MBR with a single partition of type
+// MBRProtectiveType covering the disk, so that tools which only understand
+// MBRs don't consider the disk to be empty.
+type MBR struct {
+	BootCode      [440]byte
+	DiskSignature uint32
+	Reserved      uint16
+	Partitions    [4]MBRPartition
+
+	// The boot signature, which is 0xAA55 for a valid MBR.
+	Signature uint16
+}
+
+// Reads the MBR from the first logical block of hd.
+func ReadMBR(hd io.ReadSeeker) (*MBR, error) {
+	if _, err := hd.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var m MBR
+	if err := binary.Read(hd, binary.LittleEndian, &m); err != nil {
+		return nil, wrapf(CodeIO, err, "Could not read MBR: %w", err)
+	}
+	return &m, nil
+}
+
+// Returns true if the MBR has a valid boot signature.
+func (m *MBR) Valid() bool {
+	return m.Signature == 0xAA55
+}
+
+// Returns true if the MBR is a protective MBR, with a partition of type
+// MBRProtectiveType starting at LBA 1 and no other partitions.
+func (m *MBR) IsProtective() bool {
+	if !m.Valid() {
+		return false
+	}
+	for i, p := range m.Partitions {
+		switch {
+		case i == 0 && (p.Type != MBRProtectiveType || p.FirstLBA != 1):
+			return false
+		case i > 0 && p.Type != 0:
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the MBR is a hybrid MBR, which has a partition of type
+// MBRProtectiveType as well as partitions which duplicate some GPT
+// partitions, for operating systems which can't boot from a GPT disk.
+func (m *MBR) IsHybrid() bool {
+	if !m.Valid() {
+		return false
+	}
+	protective, other := false, false
+	for _, p := range m.Partitions {
+		switch p.Type {
+		case 0:
+		case MBRProtectiveType:
+			protective = true
+		default:
+			other = true
+		}
+	}
+	return protective && other
+}
+
+// Encodes the MBR as a logical block.
+func (m *MBR) encode() LogicalBlock {
+	var buf bytes.Buffer
+	// Writing a fixed size struct to a bytes.Buffer can not fail.
+	binary.Write(&buf, binary.LittleEndian, m)
+	var b LogicalBlock
+	copy(b[:], buf.Bytes())
+	return b
+}
+
+// Decodes a logical block as an MBR.
+func decodeMBR(b LogicalBlock) *MBR {
+	var m MBR
+	// Reading a fixed size struct from a large enough buffer can not fail.
+	binary.Read(bytes.NewReader(b[:]), binary.LittleEndian, &m)
+	return &m
+}
+
+// Returns a protective MBR, with a single partition of type 0xEE covering a
+// disk whose last logical block is lastLBA.
+func protectiveMBR(lastLBA uint64) LogicalBlock {
+	m := MBR{Signature: 0xAA55}
+	m.Partitions[0].Type = MBRProtectiveType
+	m.Partitions[0].SetRange(1, uint32(min(lastLBA, 0xFFFFFFFF)))
+	return m.encode()
+}
+
+// Checks that the first logical block of hd is a protective MBR covering the
+// disk described by the primary header h. A hybrid MBR is reported as a
+// warning, since it's deliberate but fragile.
+func verifyMBR(hd io.ReadSeeker, h GPTHeader, r *Report) {
+	m, err := ReadMBR(hd)
+	switch {
+	case err != nil:
+		r.add(SeverityError, err)
+	case !m.Valid():
+		r.add(SeverityWarning, errorf(CodeMBR, "LBA 0 does not contain an MBR, the UEFI specification requires a protective MBR"))
+	case m.IsHybrid():
+		r.add(SeverityWarning, errorf(CodeMBR, "Disk has a hybrid MBR, which must be kept in sync with the GPT"))
+	case !m.IsProtective():
+		r.add(SeverityWarning, errorf(CodeMBR, "MBR is not a protective MBR, tools which don't support GPT may overwrite the disk"))
+	case uint64(m.Partitions[0].Sectors) < min(h.AltLBA, 0xFFFFFFFF):
+		r.add(SeverityWarning, errorf(CodeMBR, "Protective MBR partition covers %d blocks instead of the whole disk", m.Partitions[0].Sectors))
+	}
+}
@@ -0,0 +1,63 @@
+package gpt
+
+// Microsoft partition types.
+var (
+	MicrosoftReserved     = registerType("E3C9E316-0B5C-4DB8-817D-F92DF00215AE", "Microsoft reserved", 0x0C01, OSWindows, CategoryReserved)
+	MicrosoftBasicData    = registerType("EBD0A0A2-B9E5-4433-87C0-68B6B72699C7", "Microsoft basic data", 0x0700, OSWindows, CategoryData)
+	WindowsRecovery       = registerType("DE94BBA4-06D1-4D40-A16A-BFD50179D6AC", "Windows RE", 0x2700, OSWindows, CategoryRecovery)
+	WindowsLDMData        = registerType("AF9B60A0-1431-4F62-BC68-3311714A69AD", "Windows LDM data", 0x4200, OSWindows, CategoryVolumeManager)
+	WindowsLDMMetadata    = registerType("5808C8AA-7E8F-42E0-85D2-E1E90434CFB3", "Windows LDM metadata", 0x4201, OSWindows, CategoryMetadata)
+	WindowsStorageSpaces  = registerType("E75CAF8F-F680-4CEE-AFA3-B001E56EFC2D", "Windows Storage Spaces", 0x4202, OSWindows, CategoryVolumeManager)
+	WindowsStorageReplica = registerType("558D43C5-A1AC-43C0-AAC8-D1472B2923D1", "Windows Storage Replica", 0, OSWindows, CategoryMetadata)
+)
+
+// The attribute bits that Microsoft defines for basic data partitions.
+const (
+	microsoftReadOnlyBit      = 1 << 60
+	microsoftShadowCopyBit    = 1 << 61
+	microsoftHiddenBit        = 1 << 62
+	microsoftNoDriveLetterBit = 1 << 63
+)
+
+// Returns true if Windows mounts the basic data partition read only.
+func (a GPTPartitionAttribute) MicrosoftReadOnly() bool {
+	return a&microsoftReadOnlyBit != 0
+}
+
+// Returns true if the basic data partition is a shadow copy of another
+// partition.
+func (a GPTPartitionAttribute) MicrosoftShadowCopy() bool {
+	return a&microsoftShadowCopyBit != 0
+}
+
+// Returns true if Windows hides the basic data partition.
+func (a GPTPartitionAttribute) MicrosoftHidden() bool {
+	return a&microsoftHiddenBit != 0
+}
+
+// Returns true if Windows doesn't assign a drive letter to the basic data
+// partition.
+func (a GPTPartitionAttribute) MicrosoftNoDriveLetter() bool {
+	return a&microsoftNoDriveLetterBit != 0
+}
+
+// Sets whether Windows mounts the basic data partition read only.
+func (a *GPTPartitionAttribute) SetMicrosoftReadOnly(readOnly bool) {
+	a.setBit(microsoftReadOnlyBit, readOnly)
+}
+
+// Sets whether the basic data partition is a shadow copy.
+func (a *GPTPartitionAttribute) SetMicrosoftShadowCopy(shadowCopy bool) {
+	a.setBit(microsoftShadowCopyBit, shadowCopy)
+}
+
+// Sets whether Windows hides the basic data partition.
+func (a *GPTPartitionAttribute) SetMicrosoftHidden(hidden bool) {
+	a.setBit(microsoftHiddenBit, hidden)
+}
+
+// Sets whether Windows doesn't assign a drive letter to the basic data
+// partition.
+func (a *GPTPartitionAttribute) SetMicrosoftNoDriveLetter(noDriveLetter bool) {
+	a.setBit(microsoftNoDriveLetterBit, noDriveLetter)
+}
@@ -0,0 +1,195 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+)
+
+// Moves the partition at index of the table, which was read from dev, so that
+// it starts at LBA start. The partition's contents are copied to the new
+// location, then the updated table is written to dev. The new location must
+// be in the usable area of the disk and not overlap any other partition. It
+// may overlap the partition's current location, in which case the contents
+// are copied in the direction which reads every block before it's
+// overwritten, as with MoveBlocks.
+//
+// If journal is not empty, the progress of the move is recorded in a journal
+// file at that path, so that an interrupted move can be finished with
+// ResumeMove or undone with RollbackMove. The journal is removed once the
+// table has been written. The table's Progress and RateLimit apply to the
+// copy.
+func (t *Table) MovePartition(dev io.ReadWriteSeeker, index int, start uint64, journal string) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.Entries) || t.Entries[index].PartitionType.IsZero() {
+		return entryErrorf(index, CodeNotInUse, "Partition %d is not in use", index)
+	}
+	e := t.Entries[index]
+	if start == e.StartingLBA {
+		return ErrNoChange
+	}
+	end, err := t.checkMove(index, start)
+	if err != nil {
+		return err
+	}
+
+	var j *Journal
+	if journal != "" {
+		j = &Journal{
+			Operation: JournalMove,
+			SrcIndex:  index,
+			DstIndex:  index,
+			SrcStart:  e.StartingLBA,
+			SrcEnd:    e.EndingLBA,
+			DstStart:  start,
+			DstEnd:    end,
+		}
+		if err := createJournal(journal, j); err != nil {
+			return err
+		}
+		defer j.close()
+	}
+	return t.move(dev, index, start, end, j, 0)
+}
+
+// Checks that the partition at index can be moved to start, which must be in
+// the usable area and not overlap any other partition, and returns the LBA
+// that it would end at.
+func (t *Table) checkMove(index int, start uint64) (uint64, error) {
+	e := t.Entries[index]
+	end := start + e.EndingLBA - e.StartingLBA
+	if start < t.Primary.FirstUseableLBA || end > t.Primary.LastUseableLBA || end < start {
+		return 0, entryErrorf(index, CodeOutsideUsable, "LBA %d-%d is outside of the usable area (LBA %d-%d)", start, end, t.Primary.FirstUseableLBA, t.Primary.LastUseableLBA)
+	}
+	for i, o := range t.Entries {
+		if i == index || o.PartitionType.IsZero() || !RangesOverlap(start, end, o.StartingLBA, o.EndingLBA) {
+			continue
+		}
+		return 0, entryErrorf(index, CodeOverlap, "LBA %d-%d overlaps partition %d (LBA %d-%d)", start, end, i, o.StartingLBA, o.EndingLBA)
+	}
+	return end, nil
+}
+
+// Copies the partition at index to LBA start-end from the byte offset from,
+// then updates the table and writes it to dev. If j is set, it's used to
+// record the progress of the copy and removed once the table is written.
+func (t *Table) move(dev io.ReadWriteSeeker, index int, start, end uint64, j *Journal, from int64) error {
+	e := &t.Entries[index]
+	bs := t.BlockSize()
+	size := int64(RangeLength(start, end) * bs)
+	src, err := NewOffsetDevice(dev, int64(e.StartingLBA*bs), size)
+	if err != nil {
+		return err
+	}
+	dst, err := NewOffsetDevice(dev, int64(start*bs), size)
+	if err != nil {
+		return err
+	}
+
+	p := newProgress(t.Progress, uint64(size))
+	defer p.finish()
+	p.phase(fmt.Sprintf("moving partition %d to LBA %d", index, start))
+	c := &rangeCopy{dev, src, dev, dst, false, p, newRateLimiter(t.RateLimit), j, true}
+	if err := c.run(from); err != nil {
+		return wrapf(CodeIO, err, "Could not move partition %d: %w", index, err)
+	}
+	e.StartingLBA, e.EndingLBA = start, end
+	if err := t.Write(dev); err != nil {
+		return err
+	}
+	if err := syncDevice(dev); err != nil {
+		return err
+	}
+	if j != nil {
+		return j.remove()
+	}
+	return nil
+}
+
+// Finishes a move of a partition in the table, which was read from dev, that
+// was interrupted. journal is the path of the journal that was passed to
+// MovePartition.
+func (t *Table) ResumeMove(dev io.ReadWriteSeeker, journal string) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	j, err := t.readMoveJournal(journal)
+	if err != nil {
+		return err
+	}
+	if err := j.open(journal); err != nil {
+		return err
+	}
+	defer j.close()
+	if t.Entries[j.SrcIndex].StartingLBA == j.DstStart {
+		// The table was written, but the journal wasn't removed.
+		return j.remove()
+	}
+	return t.move(dev, j.SrcIndex, j.DstStart, j.DstEnd, j, int64(j.Done))
+}
+
+// Undoes a move of a partition in the table, which was read from dev, that
+// was interrupted. journal is the path of the journal that was passed to
+// MovePartition. If the new location doesn't overlap the old one, the
+// partition's contents at its old location are still intact. Otherwise, the
+// part of the partition which the journal records as moved is copied back.
+//
+// BUG(driusan): If rolling back a move over a partition's own location is
+// interrupted, the partition's contents may be lost.
+func (t *Table) RollbackMove(dev io.ReadWriteSeeker, journal string) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	j, err := t.readMoveJournal(journal)
+	if err != nil {
+		return err
+	}
+	if j.SrcStart <= j.DstEnd && j.DstStart <= j.SrcEnd {
+		// The blocks which were moved are at the end of the
+		// partition if it was moved backwards from its end.
+		blocks := j.Done / t.BlockSize()
+		var off uint64
+		if j.DstStart > j.SrcStart {
+			off = j.SrcEnd - j.SrcStart + 1 - blocks
+		}
+		if err := MoveBlocks(dev, j.DstStart+off, j.SrcStart+off, blocks, t.BlockSize(), t.Progress); err != nil {
+			return wrapf(CodeIO, err, "Could not roll back the move of partition %d: %w", j.SrcIndex, err)
+		}
+	}
+	if e := &t.Entries[j.SrcIndex]; e.StartingLBA == j.DstStart {
+		e.StartingLBA, e.EndingLBA = j.SrcStart, j.SrcEnd
+		if err := t.Write(dev); err != nil {
+			return err
+		}
+		if err := syncDevice(dev); err != nil {
+			return err
+		}
+	}
+	if err := j.open(journal); err != nil {
+		return err
+	}
+	return j.remove()
+}
+
+// Reads the move journal at path, and checks that it's for a partition in
+// the table.
+func (t *Table) readMoveJournal(path string) (*Journal, error) {
+	j, err := ReadJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if j.Operation != JournalMove {
+		return nil, errorf(CodeJournal, "Journal %v is not for a move", path)
+	}
+	if j.SrcIndex < 0 || j.SrcIndex >= len(t.Entries) {
+		return nil, errorf(CodeJournal, "Journal %v is for partition %d, which is not in the table", path, j.SrcIndex)
+	}
+	switch e := t.Entries[j.SrcIndex]; {
+	case e.StartingLBA == j.SrcStart && e.EndingLBA == j.SrcEnd:
+	case e.StartingLBA == j.DstStart && e.EndingLBA == j.DstEnd:
+	default:
+		return nil, errorf(CodeJournal, "Journal %v does not match partition %d (LBA %d-%d)", path, j.SrcIndex, e.StartingLBA, e.EndingLBA)
+	}
+	return j, nil
+}
@@ -0,0 +1,145 @@
+package gpt_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+)
+
+// A device which fails every Write after the first writes succeed, to
+// interrupt an operation part way through.
+type failingDevice struct {
+	*gpttest.Image
+	writes int
+}
+
+func (d *failingDevice) Write(p []byte) (int, error) {
+	if d.writes == 0 {
+		return 0, errors.New("device removed")
+	}
+	d.writes--
+	return d.Image.Write(p)
+}
+
+// The partition moved by the tests, which is moved 512 sectors forward over
+// its own location, so the move is journaled every 256KiB.
+const (
+	moveStart = 2048
+	moveEnd   = 10239
+	moveTo    = 2560
+)
+
+// Returns an image with one partition whose every sector has different
+// contents, and the partition's contents.
+func moveImage(t *testing.T) (*gpttest.Image, []byte) {
+	t.Helper()
+	d := gpttest.Disk{
+		Sectors:    32768,
+		Partitions: []gpttest.Partition{{Type: gpt.LinuxFilesystem, Start: moveStart, End: moveEnd}},
+	}
+	img, err := d.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := img.Bytes()[moveStart*512 : (moveEnd+1)*512]
+	for i := range data {
+		data[i] = byte(i/512 + i%512)
+	}
+	return img, bytes.Clone(data)
+}
+
+// Starts moving the partition of img with a journal at path, and interrupts
+// the move after a few checkpoints.
+func interruptMove(t *testing.T, img *gpttest.Image, path string) {
+	t.Helper()
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := &failingDevice{img, 4}
+	if err := table.MovePartition(dev, 0, moveTo, path); err == nil {
+		t.Fatal("the interrupted move succeeded")
+	}
+	j, err := gpt.ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := uint64(moveEnd-moveStart+1) * 512; j.Done == 0 || j.Done >= size {
+		t.Fatalf("the journal records %d of %d bytes as moved, want a partial move", j.Done, size)
+	}
+}
+
+// Checks that the partition of img is at start and has the given contents,
+// and that the journal at path was removed.
+func checkMoved(t *testing.T, img *gpttest.Image, start uint64, data []byte, path string) {
+	t.Helper()
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := table.Entries[0]; e.StartingLBA != start || e.EndingLBA != start+moveEnd-moveStart {
+		t.Errorf("partition is at LBA %d-%d, want it to start at %d", e.StartingLBA, e.EndingLBA, start)
+	}
+	if !bytes.Equal(img.Bytes()[start*512:start*512+uint64(len(data))], data) {
+		t.Error("the partition's contents changed")
+	}
+	if _, err := gpt.ReadJournal(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the journal wasn't removed (%v)", err)
+	}
+}
+
+func TestResumeMove(t *testing.T) {
+	img, data := moveImage(t)
+	path := filepath.Join(t.TempDir(), "move.journal")
+	interruptMove(t, img, path)
+
+	// The table isn't written until the move is finished, so it's still
+	// read with the partition at its old location.
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.ResumeMove(img, path); err != nil {
+		t.Fatal(err)
+	}
+	checkMoved(t, img, moveTo, data, path)
+}
+
+func TestRollbackMove(t *testing.T) {
+	img, data := moveImage(t)
+	path := filepath.Join(t.TempDir(), "move.journal")
+	interruptMove(t, img, path)
+
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.RollbackMove(img, path); err != nil {
+		t.Fatal(err)
+	}
+	checkMoved(t, img, moveStart, data, path)
+}
+
+func TestResumeMoveWithoutJournal(t *testing.T) {
+	img, _ := moveImage(t)
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "move.journal")
+	if err := table.ResumeMove(img, path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ResumeMove without a journal: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("not a journal"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.RollbackMove(img, path); gpt.CodeOf(err) != gpt.CodeJournal {
+		t.Errorf("RollbackMove with an invalid journal: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package gpt
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf16"
+)
+
+// A NameError describes a partition name which is valid according to the
+// UEFI specification, but is likely to be mishandled by firmware, udev rules,
+// or other tools.
+type NameError struct {
+	// The index of the partition entry in the table.
+	Index int
+
+	// The name of the partition, as returned by GetName.
+	Name string
+
+	// A description of the problem.
+	Problem string
+}
+
+func (e NameError) Error() string {
+	return fmt.Sprintf("Partition %d name %q: %v", e.Index, e.Name, e.Problem)
+}
+
+// Returns CodeName.
+func (e NameError) Code() ErrorCode {
+	return CodeName
+}
+
+func (e NameError) entry() int {
+	return e.Index
+}
+
+// Returns true if target is ErrName.
+func (e NameError) Is(target error) bool {
+	return target == ErrName
+}
+
+// Checks the names of all partitions in use in the table, returning a
+// NameError for each name which contains characters after a null terminator,
+// invalid UTF-16, or non-printable characters, and for each name which is
+// shared with an earlier partition.
+func (t *Table) VerifyNames() []error {
+	var errs []error
+	seen := make(map[string]int)
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		name := e.GetName()
+		if problem := nameProblem(e.PartitionName); problem != "" {
+			errs = append(errs, NameError{i, name, problem})
+		}
+		if name == "" {
+			continue
+		}
+		if j, ok := seen[name]; ok {
+			errs = append(errs, NameError{i, name, fmt.Sprintf("duplicate of partition %d", j)})
+			continue
+		}
+		seen[name] = i
+	}
+	return errs
+}
+
+// Returns a description of the first problem with the encoded name, or
+// an empty string if there's nothing wrong with it.
+func nameProblem(name [36]uint16) string {
+	end := len(name)
+	for i, c := range name {
+		if c == 0 {
+			end = i
+			break
+		}
+	}
+	for _, c := range name[end:] {
+		if c != 0 {
+			return "embedded null character"
+		}
+	}
+	for i := 0; i < end; i++ {
+		c := rune(name[i])
+		if utf16.IsSurrogate(c) {
+			if i+1 < end && utf16.DecodeRune(c, rune(name[i+1])) != unicode.ReplacementChar {
+				i++
+				continue
+			}
+			return "invalid UTF-16"
+		}
+		if !unicode.IsPrint(c) {
+			return fmt.Sprintf("non-printable character %U", c)
+		}
+	}
+	return ""
+}
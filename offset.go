@@ -0,0 +1,104 @@
+package gpt
+
+import (
+	"io"
+)
+
+// An OffsetDevice is a view of a region of a block device which starts at
+// a byte offset, such as a disk image embedded in another file or a
+// partition which contains its own GPT. All reads, writes and seeks are
+// relative to the start of the region, so a Table read from an OffsetDevice
+// has LBAs relative to the region rather than the underlying device.
+//
+// An OffsetDevice is not safe for concurrent use, and assumes that nothing
+// else moves the underlying device's offset between calls.
+type OffsetDevice struct {
+	dev    io.ReadSeeker
+	offset int64
+	size   int64
+	pos    int64
+}
+
+// Returns a view of dev which starts at offset bytes and is size bytes long.
+// If size is zero, the view extends to the end of dev. The returned device
+// can be written to if dev implements io.Writer.
+func NewOffsetDevice(dev io.ReadSeeker, offset, size int64) (*OffsetDevice, error) {
+	if offset < 0 || size < 0 {
+		return nil, errorf(CodeInvalidArgument, "Invalid region at offset %d of size %d", offset, size)
+	}
+	if size == 0 {
+		end, err := dev.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if end < offset {
+			return nil, errorf(CodeInvalidArgument, "Offset %d is past the end of the device", offset)
+		}
+		size = end - offset
+	}
+	return &OffsetDevice{dev: dev, offset: offset, size: size}, nil
+}
+
+// Reads from the current position in the region.
+func (d *OffsetDevice) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	if remaining := d.size - d.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	if _, err := d.dev.Seek(d.offset+d.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := d.dev.Read(p)
+	d.pos += int64(n)
+	return n, err
+}
+
+// Writes to the current position in the region. It is an error to write past
+// the end of the region.
+func (d *OffsetDevice) Write(p []byte) (int, error) {
+	w, ok := d.dev.(io.Writer)
+	if !ok {
+		return 0, errorf(CodeReadOnly, "Device is read only")
+	}
+	if d.pos+int64(len(p)) > d.size {
+		return 0, errorf(CodeInvalidArgument, "Write past the end of the device")
+	}
+	if _, err := d.dev.Seek(d.offset+d.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(p)
+	d.pos += int64(n)
+	return n, err
+}
+
+// Sets the position in the region for the next Read or Write. SeekEnd is
+// relative to the end of the region.
+func (d *OffsetDevice) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errorf(CodeInvalidArgument, "Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errorf(CodeInvalidArgument, "Seek to negative offset %d", offset)
+	}
+	d.pos = offset
+	return offset, nil
+}
+
+// Returns a view of the partition at index of the table, which was read from
+// dev. This can be used to read a GPT which is nested inside a partition.
+func (t *Table) PartitionView(dev io.ReadSeeker, index int) (*OffsetDevice, error) {
+	if index < 0 || index >= len(t.Entries) || t.Entries[index].PartitionType.IsZero() {
+		return nil, entryErrorf(index, CodeNotInUse, "Partition %d is not in use", index)
+	}
+	e := t.Entries[index]
+	bs := t.BlockSize()
+	return NewOffsetDevice(dev, int64(e.StartOffsetBytes(bs)), int64(e.SizeBytes(bs)))
+}
@@ -0,0 +1,166 @@
+package gpt
+
+import (
+	"os"
+	"slices"
+)
+
+// A Disk is a block device or raw disk image which was opened with Open or
+// OpenRW, along with the GPT that was read from it.
+//
+// The methods of a Disk which modify partitions or their contents make the
+// safety checks of CheckModify or CheckOverwrite first, as overridden by the
+// table's Force level, which the methods of the Table itself don't.
+type Disk struct {
+	// The partition table read from the disk. If the disk was opened
+	// with Open, the methods of the table which would modify it return
+	// ErrReadOnly.
+	Table *Table
+
+	f    *os.File
+	lock *DeviceLock
+
+	// The partition entries as they are on the disk, which changes to
+	// the table are checked against by Commit.
+	committed []GPTPartitionEntry
+}
+
+// Opens the disk at path for reading and reads its partition table. Neither
+// the disk nor the table can be modified through the returned Disk, so it's
+// safe to use for inspecting disks which are in use.
+func Open(path string) (*Disk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ReadTable(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.readOnly = true
+	return &Disk{Table: t, f: f, committed: slices.Clone(t.Entries)}, nil
+}
+
+// Opens the disk at path for reading and writing and reads its partition
+// table. The disk is locked with LockDevice until it's closed.
+func OpenRW(path string) (*Disk, error) {
+	lock, err := LockDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	t, err := ReadTable(f)
+	if err != nil {
+		f.Close()
+		lock.Unlock()
+		return nil, err
+	}
+	return &Disk{Table: t, f: f, lock: lock, committed: slices.Clone(t.Entries)}, nil
+}
+
+// Returns the file that the disk was opened as.
+func (d *Disk) File() *os.File {
+	return d.f
+}
+
+// Writes the table to the disk and flushes it to stable storage, unless
+// opts include WithNoSync. Returns ErrReadOnly if the disk was opened with
+// Open.
+//
+// Each partition on the disk whose entry was changed or deleted since the
+// table was read or last committed is checked with CheckModify first, and
+// the first error is returned without writing anything.
+func (d *Disk) Commit(opts ...Option) error {
+	if err := d.checkChanges(); err != nil {
+		return err
+	}
+	if err := d.Table.Write(d.f, append([]Option{WithSync(SyncAtEnd)}, opts...)...); err != nil {
+		return err
+	}
+	d.committed = slices.Clone(d.Table.Entries)
+	return nil
+}
+
+// Checks each partition on the disk whose entry was changed or deleted since
+// the table was read or last committed with CheckModify.
+func (d *Disk) checkChanges() error {
+	if err := d.Table.checkWritable(); err != nil {
+		return err
+	}
+	// The partitions are found by their entries on the disk, not the
+	// changed ones.
+	old := *d.Table
+	old.Entries = d.committed
+	for i, e := range d.committed {
+		if e.PartitionType.IsZero() || (i < len(d.Table.Entries) && d.Table.Entries[i] == e) {
+			continue
+		}
+		if err := old.CheckModify(d.f.Name(), i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deletes the partition at index of the table, as Table.DeletePartition does,
+// once CheckModify allows it. Use Commit to write the change to the disk.
+func (d *Disk) DeletePartition(index int) error {
+	if err := d.Table.CheckModify(d.f.Name(), index); err != nil {
+		return err
+	}
+	return d.Table.DeletePartition(index)
+}
+
+// Moves the partition at index so that it starts at LBA start, as
+// Table.MovePartition does, once CheckModify allows it. The moved partition's
+// entry is written to the disk along with any other changes to the table, so
+// they're checked as Commit checks them.
+func (d *Disk) MovePartition(index int, start uint64, journal string) error {
+	if err := d.checkChanges(); err != nil {
+		return err
+	}
+	if err := d.Table.CheckModify(d.f.Name(), index); err != nil {
+		return err
+	}
+	if err := d.Table.MovePartition(d.f, index, start, journal); err != nil {
+		return err
+	}
+	d.committed = slices.Clone(d.Table.Entries)
+	return nil
+}
+
+// Zeroes the contents of the partition at index, as Table.WipePartition does,
+// once CheckOverwrite allows it.
+func (d *Disk) WipePartition(index int) error {
+	if err := d.Table.CheckOverwrite(d.f, d.f.Name(), index); err != nil {
+		return err
+	}
+	return d.Table.WipePartition(d.f, index)
+}
+
+// Overwrites the contents of the partition at index with passes passes of
+// random data followed by zeros, as Table.ShredPartition does, once
+// CheckOverwrite allows it.
+func (d *Disk) ShredPartition(index, passes int) error {
+	if err := d.Table.CheckOverwrite(d.f, d.f.Name(), index); err != nil {
+		return err
+	}
+	return d.Table.ShredPartition(d.f, index, passes)
+}
+
+// Closes the disk, releasing its lock if it was opened with OpenRW. Changes
+// to the table which haven't been committed are discarded.
+func (d *Disk) Close() error {
+	err := d.f.Close()
+	if d.lock != nil {
+		if lerr := d.lock.Unlock(); err == nil {
+			err = lerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package gpt_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+)
+
+func TestDiskChecksOverwrite(t *testing.T) {
+	d := gpttest.Disk{
+		Partitions: []gpttest.Partition{{Type: gpt.LinuxFilesystem, Start: 2048, End: 4095}},
+		// An ext4 superblock magic number in the partition.
+		Corruptions: []gpttest.Corruption{gpttest.OverwriteSector(2048+2, []byte{0x38: 0x53, 0x39: 0xEF})},
+	}
+	f, err := d.TempFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ro, err := gpt.Open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.DeletePartition(0); !errors.Is(err, gpt.ErrReadOnly) {
+		t.Errorf("DeletePartition of a read only disk: %v", err)
+	}
+
+	disk, err := gpt.OpenRW(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+	var sig *gpt.SignatureError
+	if err := disk.WipePartition(0); !errors.As(err, &sig) || sig.Filesystem != "ext4" {
+		t.Fatalf("WipePartition of an ext4 partition: %v", err)
+	}
+	disk.Table.Force = gpt.ForceSignature
+	if err := disk.WipePartition(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := disk.DeletePartition(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := disk.Commit(gpt.WithNoSync()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[(2048+2)*512+0x38] != 0 {
+		t.Error("partition wasn't wiped")
+	}
+}
@@ -0,0 +1,111 @@
+package gpt
+
+import (
+	"log/slog"
+)
+
+// An Option configures ReadTable, CreateTable, Verify, Table.Write or
+// Disk.Commit, such as WithDeepVerify or WithNoSync. Options which don't apply
+// to the function they're passed to are ignored, and later options override
+// earlier ones.
+//
+// VerifyOptions and WriteOptions are also Options, which replace all of the
+// settings that they hold.
+type Option interface {
+	apply(*options)
+}
+
+// The settings which can be changed with an Option.
+type options struct {
+	logger    *slog.Logger
+	blockSize uint64
+	verify    VerifyOptions
+	write     WriteOptions
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+func (v VerifyOptions) apply(o *options) {
+	o.verify = v
+}
+
+func (w WriteOptions) apply(o *options) {
+	o.write = w
+}
+
+// Returns the settings of opts, applied in order to the defaults.
+func collectOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return o
+}
+
+// Returns an error if the logical block size set with WithSectorSize isn't
+// valid. A block size of 0 is detected, so it's valid.
+func (o options) checkBlockSize() error {
+	if o.blockSize == 0 {
+		return nil
+	}
+	return validBlockSize(o.blockSize)
+}
+
+// Logs debug records for each structure read by ReadTable to logger, which
+// the returned table also uses as its Logger.
+func WithLogger(logger *slog.Logger) Option {
+	return optionFunc(func(o *options) { o.logger = logger })
+}
+
+// Sets the logical block size of the disk passed to ReadTable or Verify,
+// rather than detecting it with DetectBlockSize, or of the table created by
+// CreateTable. Table.Write returns an error if it's not the table's block
+// size. A size of 0 restores the default.
+func WithSectorSize(size uint64) Option {
+	return optionFunc(func(o *options) { o.blockSize = size })
+}
+
+// Sets how thoroughly Verify checks the disk.
+func WithVerifyLevel(level VerifyLevel) Option {
+	return optionFunc(func(o *options) { o.verify.Level = level })
+}
+
+// Makes Verify read every block of every partition, as VerifyDeep does. If
+// freeSpace is set, the unallocated blocks of the usable area are also read.
+func WithDeepVerify(freeSpace bool) Option {
+	return optionFunc(func(o *options) {
+		o.verify.Level = VerifyDeep
+		o.verify.FreeSpace = freeSpace
+	})
+}
+
+// Makes Verify check the partition names with VerifyNames.
+func WithNameChecks() Option {
+	return optionFunc(func(o *options) { o.verify.Names = true })
+}
+
+// Makes Verify report the progress of reading partition contents to fn.
+func WithProgress(fn ProgressFunc) Option {
+	return optionFunc(func(o *options) { o.verify.Progress = fn })
+}
+
+// Sets when Table.Write or Disk.Commit flush the device to stable storage.
+func WithSync(mode SyncMode) Option {
+	return optionFunc(func(o *options) { o.write.Sync = mode })
+}
+
+// Makes Table.Write or Disk.Commit not flush the device to stable storage.
+// This is the same as WithSync(SyncNever).
+func WithNoSync() Option {
+	return WithSync(SyncNever)
+}
+
+// Makes Table.Write invalidate the primary header while the primary entry
+// array is written, as WriteOptions.InvalidatePrimary does.
+func WithInvalidatePrimary() Option {
+	return optionFunc(func(o *options) { o.write.InvalidatePrimary = true })
+}
@@ -0,0 +1,70 @@
+package gpt
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The directory that the kernel exposes block devices in.
+const sysBlockDir = "/sys/class/block"
+
+// Returns the device node (ie. "/dev/nvme0n1p3") of the kernel partition
+// which corresponds to the entry at index of the table, where the table was
+// read from the whole disk device disk (ie. "/dev/nvme0n1").
+//
+// The kernel's partitions are matched to the entry by their start and size,
+// as reported in /sys/class/block, rather than assuming that the partition
+// number is index+1.
+func (t *Table) PartitionDevice(disk string, index int) (string, error) {
+	if index < 0 || index >= len(t.Entries) || t.Entries[index].PartitionType.IsZero() {
+		return "", entryErrorf(index, CodeNotInUse, "Partition %d is not in use", index)
+	}
+	e := t.Entries[index]
+
+	dev, err := filepath.EvalSymlinks(disk)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(dev)
+	parts, err := os.ReadDir(filepath.Join(sysBlockDir, name))
+	if err != nil {
+		return "", err
+	}
+
+	// sysfs always reports the start and size in 512 byte sectors,
+	// regardless of the logical block size of the device.
+	start := e.StartOffsetBytes(t.BlockSize()) / 512
+	size := e.SizeBytes(t.BlockSize()) / 512
+	for _, p := range parts {
+		if !strings.HasPrefix(p.Name(), name) {
+			continue
+		}
+		dir := filepath.Join(sysBlockDir, name, p.Name())
+		if _, err := os.Stat(filepath.Join(dir, "partition")); err != nil {
+			continue
+		}
+		pstart, err := readSysUint(filepath.Join(dir, "start"))
+		if err != nil {
+			return "", err
+		}
+		psize, err := readSysUint(filepath.Join(dir, "size"))
+		if err != nil {
+			return "", err
+		}
+		if pstart == start && psize == size {
+			return filepath.Join("/dev", p.Name()), nil
+		}
+	}
+	return "", errorf(CodeNotFound, "No kernel partition of %v matches partition %d", dev, index)
+}
+
+// Reads a sysfs file containing a single unsigned integer.
+func readSysUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
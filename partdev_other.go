@@ -0,0 +1,11 @@
+//go:build !linux
+
+package gpt
+
+// Returns the device node of the kernel partition which corresponds to the
+// entry at index of the table.
+//
+// BUG(driusan): PartitionDevice is only implemented on Linux.
+func (t *Table) PartitionDevice(disk string, index int) (string, error) {
+	return "", errorf(CodeUnsupported, "PartitionDevice is not supported on this operating system")
+}
@@ -0,0 +1,45 @@
+package gpt
+
+// Checks that the backup header is where the primary header says it is, and
+// that neither partition entry array overlaps a header or the usable area of
+// the disk. The entry arrays may be anywhere outside of the usable area, not
+// only immediately after the primary header and before the backup header. If size, the size of the
+// device in bytes, is non-zero, the backup header is also checked to be in
+// the last logical block of the device, which catches images which were
+// truncated or extended without updating the GPT.
+func (t *Table) VerifyPlacement(size uint64) []error {
+	var errs []error
+	pstart := t.Primary.PartitionEntryLBA
+	pend := pstart + t.Primary.entryArrayBlocks(t.BlockSize()) - 1
+	if pstart <= t.Primary.MyLBA {
+		errs = append(errs, errorf(CodePlacement, "Primary partition entry array at LBA %d overlaps the primary header at LBA %d", pstart, t.Primary.MyLBA))
+	}
+	if pend >= t.Primary.FirstUseableLBA {
+		errs = append(errs, errorf(CodePlacement, "Primary partition entry array (LBA %d-%d) overlaps the first usable LBA %d", pstart, pend, t.Primary.FirstUseableLBA))
+	}
+	bstart := t.Backup.PartitionEntryLBA
+	bend := bstart + t.Backup.entryArrayBlocks(t.BlockSize()) - 1
+	if bstart <= t.Primary.LastUseableLBA {
+		errs = append(errs, errorf(CodePlacement, "Backup partition entry array (LBA %d-%d) overlaps the last usable LBA %d", bstart, bend, t.Primary.LastUseableLBA))
+	}
+
+	if size > 0 {
+		last := size/t.BlockSize() - 1
+		switch {
+		case t.Primary.AltLBA > last:
+			errs = append(errs, errorf(CodeBackupLocation, "Backup header at LBA %d is past the end of the device (last LBA %d)", t.Primary.AltLBA, last))
+		case t.Primary.AltLBA < last:
+			errs = append(errs, errorf(CodeBackupLocation, "Backup header at LBA %d is not at the end of the device (last LBA %d)", t.Primary.AltLBA, last))
+		}
+	}
+	if t.Backup.MyLBA != t.Primary.AltLBA {
+		errs = append(errs, errorf(CodeBackupLocation, "Backup header claims to be at LBA %d, but is at LBA %d", t.Backup.MyLBA, t.Primary.AltLBA))
+	}
+	if t.Backup.AltLBA != t.Primary.MyLBA {
+		errs = append(errs, errorf(CodeBackupLocation, "Backup header's AltLBA is %d, not the primary header's LBA %d", t.Backup.AltLBA, t.Primary.MyLBA))
+	}
+	if bend >= t.Primary.AltLBA {
+		errs = append(errs, errorf(CodePlacement, "Backup partition entry array (LBA %d-%d) overlaps the backup header at LBA %d", bstart, bend, t.Primary.AltLBA))
+	}
+	return errs
+}
@@ -0,0 +1,68 @@
+package gpt
+
+import (
+	"bytes"
+	"io"
+)
+
+// A filesystem signature, which identifies a filesystem by a magic string at
+// a fixed offset from the start of the partition.
+type fsMagic struct {
+	fstype string
+	offset int64
+	magic  []byte
+}
+
+// Signatures of the filesystems that ProbeFilesystem recognizes. More
+// specific signatures must come first, since (for instance) a FAT boot
+// sector is also present on NTFS and exFAT.
+var fsMagics = []fsMagic{
+	{"crypto_LUKS", 0, []byte("LUKS\xba\xbe")},
+	{"xfs", 0, []byte("XFSB")},
+	{"btrfs", 0x10040, []byte("_BHRfS_M")},
+	{"ext4", 0x438, []byte{0x53, 0xEF}},
+	{"f2fs", 0x400, []byte{0x10, 0x20, 0xF5, 0xF2}},
+	{"ntfs", 3, []byte("NTFS    ")},
+	{"exfat", 3, []byte("EXFAT   ")},
+	{"vfat", 0x52, []byte("FAT32   ")},
+	{"vfat", 0x36, []byte("FAT16   ")},
+	{"vfat", 0x36, []byte("FAT12   ")},
+	{"swap", 4086, []byte("SWAPSPACE2")},
+	{"swap", 16374, []byte("SWAPSPACE2")},
+	{"swap", 65526, []byte("SWAPSPACE2")},
+}
+
+// Probes the start of the partition at index of the table for a known
+// filesystem signature. The filesystem type is returned using the same
+// names as blkid (ie. "ext4", "vfat", "swap"), or an empty string if the
+// filesystem is not recognized.
+//
+// Note that ext2 and ext3 filesystems share the ext4 signature, and are
+// reported as "ext4" (which can mount them.)
+func (t *Table) ProbeFilesystem(hd io.ReadSeeker, index int) (string, error) {
+	if index < 0 || index >= len(t.Entries) {
+		return "", errorf(CodeInvalidArgument, "Invalid partition index %d", index)
+	}
+	e := t.Entries[index]
+	if e.PartitionType.IsZero() {
+		return "", entryErrorf(index, CodeNotInUse, "Partition %d is not in use", index)
+	}
+	start := int64(e.StartOffsetBytes(t.BlockSize()))
+	size := int64(e.SizeBytes(t.BlockSize()))
+	for _, m := range fsMagics {
+		if m.offset+int64(len(m.magic)) > size {
+			continue
+		}
+		if _, err := hd.Seek(start+m.offset, io.SeekStart); err != nil {
+			return "", err
+		}
+		buf := make([]byte, len(m.magic))
+		if _, err := io.ReadFull(hd, buf); err != nil {
+			return "", err
+		}
+		if bytes.Equal(buf, m.magic) {
+			return m.fstype, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,89 @@
+package gpt
+
+import (
+	"time"
+)
+
+// Progress describes how far a long running operation, such as copying or
+// hashing a partition, has got.
+type Progress struct {
+	// The current phase of the operation, such as "hashing partition 2".
+	Phase string
+
+	// The number of bytes processed so far, and the total number of
+	// bytes the operation will process.
+	Done, Total uint64
+
+	// The time since the operation started.
+	Elapsed time.Duration
+}
+
+// Returns the fraction of the operation which is done, between 0 and 1.
+func (p Progress) Fraction() float64 {
+	if p.Total == 0 {
+		return 1
+	}
+	return float64(p.Done) / float64(p.Total)
+}
+
+// Returns the estimated time remaining, based on the average rate so far, or
+// 0 if it can't be estimated yet.
+func (p Progress) ETA() time.Duration {
+	if p.Done == 0 || p.Done >= p.Total {
+		return 0
+	}
+	rate := float64(p.Done) / float64(p.Elapsed)
+	return time.Duration(float64(p.Total-p.Done) / rate)
+}
+
+// A ProgressFunc is called periodically by long running operations to
+// report their progress. It's called from the goroutine doing the operation,
+// so it should return quickly.
+type ProgressFunc func(Progress)
+
+// How often a ProgressFunc is called, at most.
+const progressInterval = 200 * time.Millisecond
+
+// Tracks the progress of an operation, calling a ProgressFunc (which may be
+// nil) at most every progressInterval.
+type progress struct {
+	fn    ProgressFunc
+	p     Progress
+	start time.Time
+	last  time.Time
+}
+
+// Starts tracking an operation which will process total bytes.
+func newProgress(fn ProgressFunc, total uint64) *progress {
+	now := time.Now()
+	return &progress{fn: fn, p: Progress{Total: total}, start: now, last: now}
+}
+
+// Sets the current phase of the operation, and reports it immediately.
+func (p *progress) phase(name string) {
+	p.p.Phase = name
+	p.report()
+}
+
+// Records that n more bytes have been processed.
+func (p *progress) add(n uint64) {
+	p.p.Done += n
+	// The final progress is reported by finish.
+	if p.fn != nil && p.p.Done < p.p.Total && time.Since(p.last) >= progressInterval {
+		p.report()
+	}
+}
+
+// Reports the final progress of the operation.
+func (p *progress) finish() {
+	p.report()
+}
+
+func (p *progress) report() {
+	if p.fn == nil {
+		return
+	}
+	p.last = time.Now()
+	p.p.Elapsed = p.last.Sub(p.start)
+	p.fn(p.p)
+}
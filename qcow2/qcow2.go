@@ -0,0 +1,656 @@
+// Package qcow2 provides access to the virtual disk inside a qcow2 image, the
+// native disk image format of QEMU, so that the GPT in a virtual machine's
+// disk image can be read or modified without converting the image.
+//
+// An Image implements gpt.BlockDevice, so it can be passed to any function in
+// package gpt which expects a block device.
+package qcow2
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/driusan/gpt"
+)
+
+// The magic number at the start of every qcow2 image.
+const Magic = "QFI\xfb"
+
+// Flags and masks for L1 and L2 table entries.
+const (
+	entryOffsetMask = 0x00FFFFFFFFFFFE00
+	entryCopied     = 1 << 63
+	entryCompressed = 1 << 62
+	entryZero       = 1 << 0
+)
+
+// Incompatible feature bits in a version 3 header.
+const (
+	featureDirty   = 1 << 0
+	featureCorrupt = 1 << 1
+)
+
+// The offset of IncompatibleFeatures in the header.
+const incompatibleFeaturesOffset = 72
+
+// The on disk qcow2 header. Fields after SnapshotsOffset only exist in
+// version 3 images.
+type header struct {
+	Magic                 [4]byte
+	Version               uint32
+	BackingFileOffset     uint64
+	BackingFileSize       uint32
+	ClusterBits           uint32
+	Size                  uint64
+	CryptMethod           uint32
+	L1Size                uint32
+	L1TableOffset         uint64
+	RefcountTableOffset   uint64
+	RefcountTableClusters uint32
+	NbSnapshots           uint32
+	SnapshotsOffset       uint64
+
+	IncompatibleFeatures uint64
+	CompatibleFeatures   uint64
+	AutoclearFeatures    uint64
+	RefcountOrder        uint32
+	HeaderLength         uint32
+}
+
+// An Image is an open qcow2 image.
+type Image struct {
+	f        *os.File
+	writable bool
+	hdr      header
+
+	clusterSize uint64
+	l2Entries   uint64
+	l1          []uint64
+	l2Cache     map[uint64][]uint64
+
+	// The decompressed contents of the most recently read compressed
+	// cluster, and its L2 entry.
+	compressedEntry uint64
+	compressed      []byte
+
+	// Set once the image has been marked dirty by a write, until it's
+	// closed.
+	dirty bool
+
+	// The image's backing file, or nil if it has none.
+	backing interface {
+		io.ReaderAt
+		io.Closer
+	}
+
+	pos int64
+}
+
+var _ gpt.BlockDevice = (*Image)(nil)
+
+// The qcow2 format, for registering with gpt.RegisterImageFormat.
+var Format = gpt.ImageFormat{
+	Name: "qcow2",
+	Match: func(r io.ReaderAt, size int64) bool {
+		magic := make([]byte, len(Magic))
+		_, err := r.ReadAt(magic, 0)
+		return err == nil && string(magic) == Magic
+	},
+	Open: func(path string, rw bool) (gpt.BlockDevice, error) {
+		if rw {
+			return OpenRW(path)
+		}
+		return Open(path)
+	},
+}
+
+// Opens the qcow2 image at path for reading.
+func Open(path string) (*Image, error) {
+	return open(path, os.O_RDONLY)
+}
+
+// Opens the qcow2 image at path for reading and writing. Writes never modify
+// the image's backing file, if it has one. Images with internal snapshots
+// can't be opened for writing, since their clusters are shared with the
+// snapshots.
+func OpenRW(path string) (*Image, error) {
+	return open(path, os.O_RDWR)
+}
+
+func open(path string, flag int) (*Image, error) {
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	img, err := newImage(f, flag != os.O_RDONLY)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if img.hdr.BackingFileOffset != 0 {
+		if err := img.openBacking(filepath.Dir(path)); err != nil {
+			// Closes the backing file too, if it was opened.
+			img.Close()
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// Reads and validates the header and L1 table of the image in f.
+func newImage(f *os.File, writable bool) (*Image, error) {
+	img := &Image{f: f, writable: writable, l2Cache: make(map[uint64][]uint64)}
+	h := &img.hdr
+
+	// Version 2 headers end at SnapshotsOffset, so read the common part
+	// first.
+	buf := make([]byte, binary.Size(*h))
+	if _, err := f.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, h); err != nil {
+		return nil, err
+	}
+	if string(h.Magic[:]) != Magic {
+		return nil, fmt.Errorf("Not a qcow2 image")
+	}
+	switch h.Version {
+	case 2:
+		h.IncompatibleFeatures, h.CompatibleFeatures, h.AutoclearFeatures = 0, 0, 0
+		h.RefcountOrder = 4
+	case 3:
+		if unknown := h.IncompatibleFeatures &^ (featureDirty | featureCorrupt); unknown != 0 {
+			return nil, fmt.Errorf("Unsupported qcow2 features %#x", unknown)
+		}
+		if writable && h.IncompatibleFeatures&featureCorrupt != 0 {
+			return nil, fmt.Errorf("Image is marked corrupt, refusing to write")
+		}
+		if writable && h.IncompatibleFeatures&featureDirty != 0 {
+			// Its refcounts can't be trusted to allocate clusters.
+			return nil, fmt.Errorf("Image was not closed cleanly, repair it with \"qemu-img check -r all\" before writing")
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported qcow2 version %d", h.Version)
+	}
+	if h.CryptMethod != 0 {
+		return nil, fmt.Errorf("Encrypted qcow2 images are not supported")
+	}
+	if h.ClusterBits < 9 || h.ClusterBits > 21 {
+		return nil, fmt.Errorf("Invalid cluster size 2^%d", h.ClusterBits)
+	}
+	if writable && h.NbSnapshots != 0 {
+		return nil, fmt.Errorf("Image has %d internal snapshots, refusing to write", h.NbSnapshots)
+	}
+
+	img.clusterSize = 1 << h.ClusterBits
+	img.l2Entries = img.clusterSize / 8
+	img.l1 = make([]uint64, h.L1Size)
+	if err := img.readTable(h.L1TableOffset, img.l1); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Opens the backing file of the image, which may itself be a qcow2 image or a
+// raw disk image. Relative paths are relative to dir.
+func (img *Image) openBacking(dir string) error {
+	if img.hdr.BackingFileSize > 1023 {
+		return fmt.Errorf("Invalid backing file name length %d", img.hdr.BackingFileSize)
+	}
+	name := make([]byte, img.hdr.BackingFileSize)
+	if _, err := img.f.ReadAt(name, int64(img.hdr.BackingFileOffset)); err != nil {
+		return err
+	}
+	path := string(name)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	magic := make([]byte, len(Magic))
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.ReadAt(magic, 0); err == nil && string(magic) == Magic {
+		f.Close()
+		backing, err := Open(path)
+		if err != nil {
+			return err
+		}
+		img.backing = backing
+		return nil
+	}
+	img.backing = f
+	return nil
+}
+
+// Closes the image and its backing file. If the image was written to, it's
+// synced and marked clean first.
+func (img *Image) Close() error {
+	if img.backing != nil {
+		img.backing.Close()
+	}
+	var err error
+	if img.dirty {
+		if err = img.f.Sync(); err == nil {
+			err = img.setFeatures(img.hdr.IncompatibleFeatures &^ featureDirty)
+		}
+	}
+	if cerr := img.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Marks a version 3 image as dirty before its first write, so that if it's
+// not closed cleanly QEMU knows to repair its refcounts. The mark is synced
+// before anything else is written.
+func (img *Image) markDirty() error {
+	if img.dirty || img.hdr.Version < 3 {
+		return nil
+	}
+	if err := img.setFeatures(img.hdr.IncompatibleFeatures | featureDirty); err != nil {
+		return err
+	}
+	img.dirty = true
+	return img.f.Sync()
+}
+
+// Sets and writes the incompatible feature bits of the header.
+func (img *Image) setFeatures(features uint64) error {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], features)
+	if _, err := img.f.WriteAt(b[:], incompatibleFeaturesOffset); err != nil {
+		return err
+	}
+	img.hdr.IncompatibleFeatures = features
+	return nil
+}
+
+// Returns the size of the virtual disk in bytes.
+func (img *Image) Size() int64 {
+	return int64(img.hdr.Size)
+}
+
+// Reads a table of big endian uint64s from the image at offset.
+func (img *Image) readTable(offset uint64, table []uint64) error {
+	buf := make([]byte, len(table)*8)
+	if _, err := img.f.ReadAt(buf, int64(offset)); err != nil {
+		return err
+	}
+	for i := range table {
+		table[i] = binary.BigEndian.Uint64(buf[i*8:])
+	}
+	return nil
+}
+
+// Returns the L2 table at offset, reading it from the image if it hasn't been
+// read already.
+func (img *Image) l2Table(offset uint64) ([]uint64, error) {
+	if t, ok := img.l2Cache[offset]; ok {
+		return t, nil
+	}
+	t := make([]uint64, img.l2Entries)
+	if err := img.readTable(offset, t); err != nil {
+		return nil, err
+	}
+	img.l2Cache[offset] = t
+	return t, nil
+}
+
+// Returns the L2 entry for the guest cluster containing the virtual disk
+// offset off, or 0 if there is no L2 table for the cluster.
+func (img *Image) l2Entry(off uint64) (uint64, error) {
+	cluster := off / img.clusterSize
+	l1Index := cluster / img.l2Entries
+	if l1Index >= uint64(len(img.l1)) {
+		return 0, nil
+	}
+	l2Offset := img.l1[l1Index] & entryOffsetMask
+	if l2Offset == 0 {
+		return 0, nil
+	}
+	t, err := img.l2Table(l2Offset)
+	if err != nil {
+		return 0, err
+	}
+	return t[cluster%img.l2Entries], nil
+}
+
+// Reads len(p) bytes of the virtual disk starting at off.
+func (img *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		if pos >= img.hdr.Size {
+			return n, io.EOF
+		}
+		inCluster := pos % img.clusterSize
+		chunk := p[n:]
+		if max := min(img.clusterSize-inCluster, img.hdr.Size-pos); uint64(len(chunk)) > max {
+			chunk = chunk[:max]
+		}
+		if err := img.readCluster(chunk, pos); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// Reads p, which must not cross a cluster boundary, from the virtual disk at
+// offset pos.
+func (img *Image) readCluster(p []byte, pos uint64) error {
+	entry, err := img.l2Entry(pos)
+	if err != nil {
+		return err
+	}
+	inCluster := pos % img.clusterSize
+	switch {
+	case entry&entryCompressed != 0:
+		data, err := img.decompress(entry)
+		if err != nil {
+			return err
+		}
+		copy(p, data[inCluster:])
+	case entry&entryZero != 0:
+		clear(p)
+	case entry&entryOffsetMask != 0:
+		_, err := img.f.ReadAt(p, int64(entry&entryOffsetMask+inCluster))
+		return err
+	case img.backing != nil:
+		// Unallocated clusters come from the backing file, which
+		// may be shorter than this image.
+		n, err := img.backing.ReadAt(p, int64(pos))
+		if err == io.EOF {
+			clear(p[n:])
+			err = nil
+		}
+		return err
+	default:
+		clear(p)
+	}
+	return nil
+}
+
+// Returns the decompressed contents of the compressed cluster described by
+// the L2 entry.
+func (img *Image) decompress(entry uint64) ([]byte, error) {
+	if img.compressed != nil && img.compressedEntry == entry {
+		return img.compressed, nil
+	}
+	x := 62 - (img.hdr.ClusterBits - 8)
+	offset := entry & (1<<x - 1)
+	sectors := (entry>>x)&(1<<(62-x)-1) + 1
+	size := sectors*512 - offset%512
+
+	buf := make([]byte, size)
+	n, err := img.f.ReadAt(buf, int64(offset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data := make([]byte, img.clusterSize)
+	if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(buf[:n])), data); err != nil {
+		return nil, fmt.Errorf("Could not decompress cluster at %d: %v", offset, err)
+	}
+	img.compressedEntry, img.compressed = entry, data
+	return data, nil
+}
+
+// Writes len(p) bytes to the virtual disk starting at off.
+//
+// Clusters which are not yet allocated in the image are allocated at the end
+// of the file. Allocation requires that the image already has a refcount
+// block which covers the new cluster, which is always the case for images
+// created by qemu-img unless they have grown by a large amount.
+//
+// BUG(driusan): Overwriting a compressed cluster leaks the space used by the
+// compressed data (which "qemu-img check -r leaks" can reclaim.)
+func (img *Image) WriteAt(p []byte, off int64) (int, error) {
+	if !img.writable {
+		return 0, fmt.Errorf("Image is not open for writing")
+	}
+	if off < 0 || uint64(off)+uint64(len(p)) > img.hdr.Size {
+		return 0, fmt.Errorf("Write outside of the virtual disk")
+	}
+	if err := img.markDirty(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		chunk := p[n:]
+		if max := img.clusterSize - pos%img.clusterSize; uint64(len(chunk)) > max {
+			chunk = chunk[:max]
+		}
+		if err := img.writeCluster(chunk, pos); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// Writes p, which must not cross a cluster boundary, to the virtual disk at
+// offset pos. Only clusters with the COPIED flag, whose refcount is 1, are
+// written in place. Other clusters are copied to a new cluster first.
+func (img *Image) writeCluster(p []byte, pos uint64) error {
+	entry, err := img.l2Entry(pos)
+	if err != nil {
+		return err
+	}
+	inCluster := pos % img.clusterSize
+	if entry&(entryCompressed|entryZero) == 0 && entry&entryCopied != 0 && entry&entryOffsetMask != 0 {
+		_, err := img.f.WriteAt(p, int64(entry&entryOffsetMask+inCluster))
+		return err
+	}
+	if entry == 0 && img.backing == nil && isZero(p) {
+		// Unallocated clusters already read as zeros.
+		return nil
+	}
+
+	// Copy the current contents of the cluster, apply the write, and
+	// store the result in a newly allocated cluster.
+	start := pos - inCluster
+	data := make([]byte, min(img.clusterSize, img.hdr.Size-start))
+	if err := img.readCluster(data, start); err != nil {
+		return err
+	}
+	copy(data[inCluster:], p)
+	offset, err := img.allocate()
+	if err != nil {
+		return err
+	}
+	if _, err := img.f.WriteAt(data, int64(offset)); err != nil {
+		return err
+	}
+	if err := img.setL2Entry(pos, offset|entryCopied); err != nil {
+		return err
+	}
+	if old := entry & entryOffsetMask; entry&(entryCompressed|entryCopied) == 0 && old != 0 {
+		// The old cluster is still used by whatever else refers to
+		// it, but not by this image.
+		count, err := img.refcount(old)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return img.setRefcount(old, count-1)
+		}
+	}
+	return nil
+}
+
+// Allocates a new cluster at the end of the image file, and returns its
+// offset.
+func (img *Image) allocate() (uint64, error) {
+	fi, err := img.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	offset := (uint64(fi.Size()) + img.clusterSize - 1) / img.clusterSize * img.clusterSize
+	if err := img.setRefcount(offset, 1); err != nil {
+		return 0, err
+	}
+	// Extend the file so the next allocation doesn't return the same
+	// cluster.
+	if err := img.f.Truncate(int64(offset + img.clusterSize)); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// Returns the location in the image file of the reference count of the host
+// cluster at offset, and its width in bytes.
+func (img *Image) refcountEntry(offset uint64) (int64, uint64, error) {
+	bits := uint64(1) << img.hdr.RefcountOrder
+	if bits < 8 {
+		return 0, 0, fmt.Errorf("Refcount width of %d bits is not supported", bits)
+	}
+	perBlock := img.clusterSize * 8 / bits
+	cluster := offset / img.clusterSize
+	tableIndex := cluster / perBlock
+	if tableIndex >= uint64(img.hdr.RefcountTableClusters)*img.clusterSize/8 {
+		return 0, 0, fmt.Errorf("Image refcount table is full, can not allocate cluster")
+	}
+	var b [8]byte
+	if _, err := img.f.ReadAt(b[:], int64(img.hdr.RefcountTableOffset+tableIndex*8)); err != nil {
+		return 0, 0, err
+	}
+	block := binary.BigEndian.Uint64(b[:]) & entryOffsetMask
+	if block == 0 {
+		return 0, 0, fmt.Errorf("Image has no refcount block for cluster %d, can not allocate it", cluster)
+	}
+	width := bits / 8
+	return int64(block + (cluster%perBlock)*width), width, nil
+}
+
+// Returns the reference count of the host cluster at offset.
+func (img *Image) refcount(offset uint64) (uint64, error) {
+	at, width, err := img.refcountEntry(offset)
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, width)
+	if _, err := img.f.ReadAt(buf, at); err != nil {
+		return 0, err
+	}
+	var count uint64
+	for _, b := range buf {
+		count = count<<8 | uint64(b)
+	}
+	return count, nil
+}
+
+// Sets the reference count of the host cluster at offset.
+func (img *Image) setRefcount(offset, count uint64) error {
+	at, width, err := img.refcountEntry(offset)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, width)
+	for i := range buf {
+		buf[i] = byte(count >> (8 * (width - 1 - uint64(i))))
+	}
+	_, err = img.f.WriteAt(buf, at)
+	return err
+}
+
+// Sets the L2 entry for the guest cluster containing pos, allocating an L2
+// table for it if necessary.
+func (img *Image) setL2Entry(pos, entry uint64) error {
+	cluster := pos / img.clusterSize
+	l1Index := cluster / img.l2Entries
+	if l1Index >= uint64(len(img.l1)) {
+		return fmt.Errorf("Offset %d is not covered by the L1 table", pos)
+	}
+	l2Offset := img.l1[l1Index] & entryOffsetMask
+	if l2Offset != 0 && img.l1[l1Index]&entryCopied == 0 {
+		// Without internal snapshots, which OpenRW refuses, an L2
+		// table is only shared if the image is inconsistent.
+		return fmt.Errorf("L2 table for offset %d is shared, refusing to modify it", pos)
+	}
+	if l2Offset == 0 {
+		var err error
+		if l2Offset, err = img.allocate(); err != nil {
+			return err
+		}
+		if _, err := img.f.WriteAt(make([]byte, img.clusterSize), int64(l2Offset)); err != nil {
+			return err
+		}
+		img.l2Cache[l2Offset] = make([]uint64, img.l2Entries)
+		img.l1[l1Index] = l2Offset | entryCopied
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], img.l1[l1Index])
+		if _, err := img.f.WriteAt(b[:], int64(img.hdr.L1TableOffset+l1Index*8)); err != nil {
+			return err
+		}
+	}
+	t, err := img.l2Table(l2Offset)
+	if err != nil {
+		return err
+	}
+	l2Index := cluster % img.l2Entries
+	t[l2Index] = entry
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], entry)
+	_, err = img.f.WriteAt(b[:], int64(l2Offset+l2Index*8))
+	return err
+}
+
+// Returns true if every byte of p is zero.
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads from the current position in the virtual disk.
+func (img *Image) Read(p []byte) (int, error) {
+	n, err := img.ReadAt(p, img.pos)
+	img.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Writes to the current position in the virtual disk.
+func (img *Image) Write(p []byte) (int, error) {
+	n, err := img.WriteAt(p, img.pos)
+	img.pos += int64(n)
+	return n, err
+}
+
+// Sets the position in the virtual disk for the next Read or Write.
+func (img *Image) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += img.pos
+	case io.SeekEnd:
+		offset += img.Size()
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("Seek to negative offset %d", offset)
+	}
+	img.pos = offset
+	return offset, nil
+}
+
+// Flushes the image file to stable storage.
+func (img *Image) Sync() error {
+	return img.f.Sync()
+}
@@ -0,0 +1,207 @@
+package qcow2_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+	"github.com/driusan/gpt/qcow2"
+)
+
+// The layout of the images built by testImage, in 64KiB clusters: the header
+// and backing file name, the refcount table, one refcount block, the L1 table
+// and the L2 table for the first 512MiB of the disk.
+const (
+	clusterBits    = 16
+	clusterSize    = 1 << clusterBits
+	refcountTable  = 1 * clusterSize
+	refcountBlock  = 2 * clusterSize
+	l1Table        = 3 * clusterSize
+	l2Table        = 4 * clusterSize
+	backingNameOff = 0x100
+)
+
+// Returns a version 3 qcow2 image of a size byte disk, which has no clusters
+// allocated and the given backing file (if it's not empty.) Its clusters
+// are all reference counted, so more can be allocated.
+func testImage(size uint64, backing string) []byte {
+	img := make([]byte, 5*clusterSize)
+	h := img[:104]
+	copy(h, qcow2.Magic)
+	binary.BigEndian.PutUint32(h[4:], 3)
+	if backing != "" {
+		binary.BigEndian.PutUint64(h[8:], backingNameOff)
+		binary.BigEndian.PutUint32(h[16:], uint32(len(backing)))
+		copy(img[backingNameOff:], backing)
+	}
+	binary.BigEndian.PutUint32(h[20:], clusterBits)
+	binary.BigEndian.PutUint64(h[24:], size)
+	l1Size := (size + clusterSize*clusterSize/8 - 1) / (clusterSize * clusterSize / 8)
+	binary.BigEndian.PutUint32(h[36:], uint32(l1Size))
+	binary.BigEndian.PutUint64(h[40:], l1Table)
+	binary.BigEndian.PutUint64(h[48:], refcountTable)
+	binary.BigEndian.PutUint32(h[56:], 1)
+	binary.BigEndian.PutUint32(h[96:], 4) // 16 bit refcounts
+	binary.BigEndian.PutUint32(h[100:], 104)
+
+	binary.BigEndian.PutUint64(img[refcountTable:], refcountBlock)
+	for c := 0; c < 5; c++ {
+		setRefcount(img, c*clusterSize, 1)
+	}
+	binary.BigEndian.PutUint64(img[l1Table:], l2Table|1<<63)
+	return img
+}
+
+// Sets the 16 bit refcount of the cluster at offset in img.
+func setRefcount(img []byte, offset, count int) {
+	binary.BigEndian.PutUint16(img[refcountBlock+offset/clusterSize*2:], uint16(count))
+}
+
+// Returns the 16 bit refcount of the cluster at offset in img.
+func refcount(img []byte, offset uint64) uint16 {
+	return binary.BigEndian.Uint16(img[refcountBlock+offset/clusterSize*2:])
+}
+
+// Returns the L2 entry of the first guest cluster of img.
+func firstL2Entry(img []byte) uint64 {
+	return binary.BigEndian.Uint64(img[l2Table:])
+}
+
+func TestWriteCopiesSharedCluster(t *testing.T) {
+	// The first guest cluster is a data cluster which is shared (ie. with
+	// a snapshot that has since been deleted, without qemu-img having
+	// set the COPIED flag again), so writing it must not modify it.
+	img := testImage(1<<20, "")
+	shared := uint64(len(img))
+	data := bytes.Repeat([]byte{0xAB}, clusterSize)
+	img = append(img, data...)
+	setRefcount(img, int(shared), 2)
+	binary.BigEndian.PutUint64(img[l2Table:], shared)
+	path := filepath.Join(t.TempDir(), "shared.qcow2")
+	if err := os.WriteFile(path, img, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := qcow2.OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.WriteAt([]byte("hello"), 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := firstL2Entry(img)
+	if entry&(1<<63) == 0 || entry&^(1<<63) == shared {
+		t.Errorf("L2 entry after the write is %#x, want a new cluster with the COPIED flag", entry)
+	}
+	if !bytes.Equal(img[shared:shared+clusterSize], data) {
+		t.Error("the shared cluster was modified")
+	}
+	if n := refcount(img, shared); n != 1 {
+		t.Errorf("refcount of the shared cluster is %d, want 1", n)
+	}
+	if features := binary.BigEndian.Uint64(img[72:]); features != 0 {
+		t.Errorf("incompatible features are %#x after Close, want the image clean", features)
+	}
+
+	q, err = qcow2.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	want := bytes.Clone(data)
+	copy(want[100:], "hello")
+	got := make([]byte, clusterSize)
+	if _, err := q.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("the written cluster doesn't read back as the old contents with the write applied")
+	}
+}
+
+func TestWriteTableOverBackingFile(t *testing.T) {
+	dir := t.TempDir()
+	d := gpttest.Disk{
+		Sectors:    8192,
+		Partitions: []gpttest.Partition{{Type: gpt.EFISystemPartition, Start: 2048, End: 4095}},
+	}
+	base, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "base.img"), base, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "overlay.qcow2")
+	if err := os.WriteFile(path, testImage(uint64(len(base)), "base.img"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := qcow2.OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := gpt.ReadTable(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := table.AddPartition(gpt.LinuxFilesystem, 1<<20, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Write(q); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = qcow2.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	read, err := gpt.ReadTable(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Equal(table) || read.Entries[i].PartitionType != gpt.LinuxFilesystem {
+		t.Error("the table read back from the overlay isn't the table that was written")
+	}
+	if after, err := os.ReadFile(filepath.Join(dir, "base.img")); err != nil || !bytes.Equal(after, base) {
+		t.Errorf("the backing file was modified (%v)", err)
+	}
+}
+
+func TestOpenMissingBackingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overlay.qcow2")
+	if err := os.WriteFile(path, testImage(1<<20, "missing.img"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Where the open file descriptors can be listed, none should be left
+	// open by the failed opens.
+	fds, fdErr := os.ReadDir("/proc/self/fd")
+	for i := 0; i < 10; i++ {
+		if _, err := qcow2.Open(path); err == nil {
+			t.Fatal("opened an image whose backing file is missing")
+		}
+	}
+	if fdErr != nil {
+		return
+	}
+	if after, err := os.ReadDir("/proc/self/fd"); err == nil && len(after) > len(fds) {
+		t.Errorf("%d file descriptors were leaked", len(after)-len(fds))
+	}
+}
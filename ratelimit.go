@@ -0,0 +1,36 @@
+package gpt
+
+import (
+	"time"
+)
+
+// Limits the bandwidth of a bulk operation, such as copying or wiping a
+// partition, so that it doesn't starve other I/O on the device.
+type rateLimiter struct {
+	// The maximum bytes per second, or 0 for no limit.
+	rate uint64
+
+	// The time the bytes processed so far are allowed to have finished by.
+	next time.Time
+}
+
+// Returns a limiter allowing rate bytes per second. If rate is 0, the
+// limiter never waits.
+func newRateLimiter(rate uint64) *rateLimiter {
+	return &rateLimiter{rate: rate}
+}
+
+// Records that n bytes of I/O were done, and sleeps until the rate is no
+// longer exceeded. Time spent not doing I/O isn't saved up, so the rate is
+// never exceeded by a burst after a pause.
+func (l *rateLimiter) wait(n uint64) {
+	if l.rate == 0 {
+		return
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	time.Sleep(l.next.Sub(now))
+}
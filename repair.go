@@ -0,0 +1,288 @@
+package gpt
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A Repair is a fix for a problem found by Verify, which can be applied with
+// ApplyRepairs.
+type Repair int
+
+const (
+	// Recompute the CRC stored in the primary header.
+	RepairPrimaryHeaderCRC Repair = iota + 1
+
+	// Recompute the CRC stored in the backup header.
+	RepairBackupHeaderCRC
+
+	// Recompute the partition entry array CRC stored in the primary
+	// header from the primary entry array.
+	RepairPrimaryArrayCRC
+
+	// Recompute the partition entry array CRC stored in the backup
+	// header from the backup entry array.
+	RepairBackupArrayCRC
+
+	// Overwrite the primary header and entry array with the backup.
+	RestorePrimaryFromBackup
+
+	// Overwrite the backup header and entry array with the primary.
+	RestoreBackupFromPrimary
+)
+
+var repairNames = map[Repair]string{
+	RepairPrimaryHeaderCRC:   "primary-header-crc",
+	RepairBackupHeaderCRC:    "backup-header-crc",
+	RepairPrimaryArrayCRC:    "primary-array-crc",
+	RepairBackupArrayCRC:     "backup-array-crc",
+	RestorePrimaryFromBackup: "restore-primary",
+	RestoreBackupFromPrimary: "restore-backup",
+}
+
+// Returns a short, machine readable name for the repair, such as
+// "primary-header-crc".
+func (r Repair) String() string {
+	if s, ok := repairNames[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Repair(%d)", int(r))
+}
+
+func (r Repair) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Parses the name of a repair, as returned by Repair.String.
+func ParseRepair(s string) (Repair, error) {
+	for r, name := range repairNames {
+		if name == s {
+			return r, nil
+		}
+	}
+	return 0, errorf(CodeInvalidArgument, "Unknown repair \"%v\"", s)
+}
+
+// The result of checking the checksums of one header and its entry array.
+type crcState struct {
+	h GPTHeader
+
+	// The size of the disk's logical blocks.
+	bs uint64
+
+	// Set if the header size is valid and its stored CRC is correct.
+	headerOK bool
+
+	// The CRC of the entry array on disk, if it could be read.
+	arrayCRC uint32
+	arrayErr error
+}
+
+// Returns true if the entry array's stored CRC is correct.
+func (s crcState) arrayOK() bool {
+	return s.arrayErr == nil && s.arrayCRC == s.h.PartitionEntryArrayCRC32
+}
+
+// Computes the checksums of h and the entry array that it points to, on a
+// disk with logical blocks of bs bytes.
+func checkCRCs(hd io.ReadSeeker, h GPTHeader, bs uint64) crcState {
+	s := crcState{h: h, bs: bs}
+	s.headerOK = validHeaderSize(h, bs) && h.computeCRC() == h.HeaderCRC32
+	array, err := readEntryArray(hd, h, bs)
+	if err != nil {
+		s.arrayErr = err
+		return s
+	}
+	s.arrayCRC = crc32.ChecksumIEEE(array)
+	return s
+}
+
+// Returns true if h has a header size that a CRC can be computed over, for a
+// header in a logical block of bs bytes.
+func validHeaderSize(h GPTHeader, bs uint64) bool {
+	return h.HeaderSize >= 92 && uint64(h.HeaderSize) <= bs
+}
+
+// Reads the raw partition entry array that h points to, on a disk with
+// logical blocks of bs bytes.
+func readEntryArray(hd io.ReadSeeker, h GPTHeader, bs uint64) ([]byte, error) {
+	size, err := deviceSize(hd)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.verifyEntryArray(bs, size); err != nil {
+		return nil, err
+	}
+	if _, err := hd.Seek(int64(h.PartitionEntryLBA*bs), io.SeekStart); err != nil {
+		return nil, err
+	}
+	array := make([]byte, h.entryArraySize())
+	if _, err := io.ReadFull(hd, array); err != nil {
+		return nil, err
+	}
+	return array, nil
+}
+
+// Returns true if a and b are the two headers of the same table, ignoring
+// their CRCs.
+func sameTable(a, b GPTHeader) bool {
+	return a.Disk == b.Disk &&
+		a.MyLBA == b.AltLBA && a.AltLBA == b.MyLBA &&
+		a.FirstUseableLBA == b.FirstUseableLBA && a.LastUseableLBA == b.LastUseableLBA &&
+		a.MaxNumberPartitionEntries == b.MaxNumberPartitionEntries &&
+		a.SizeOfPartitionEntry == b.SizeOfPartitionEntry &&
+		a.PartitionEntryArrayCRC32 == b.PartitionEntryArrayCRC32
+}
+
+// Adds a finding to r for each checksum of s which is wrong, using other
+// (the other header of the table, if it could be read) to decide whether
+// the data or the stored CRC is more likely to be wrong, and suggesting the
+// corresponding repair.
+func (r *Report) diagnoseCRCs(which, otherName string, s crcState, other *crcState, fixHeader, fixArray, restore Repair) {
+	add := func(err error, repairs ...Repair) {
+		r.add(SeverityError, err, repairs...)
+	}
+	otherOK := other != nil && other.headerOK && other.arrayOK()
+	switch {
+	case !validHeaderSize(s.h, s.bs):
+		err := errorf(CodeInvalidHeader, "%v header has invalid size %d", which, s.h.HeaderSize)
+		if otherOK {
+			add(err, restore)
+		} else {
+			add(err)
+		}
+	case !s.headerOK:
+		err := errorf(CodeHeaderCRC, "%v header CRC is %#08x, should be %#08x", which, s.h.HeaderCRC32, s.h.computeCRC())
+		switch {
+		case otherOK && sameTable(s.h, other.h):
+			add(wrapf(CodeHeaderCRC, err, "%w; the header agrees with the %v header, so the stored CRC is likely wrong", err, otherName), fixHeader)
+		case otherOK:
+			add(wrapf(CodeHeaderCRC, err, "%w; the header does not agree with the %v header, so the header is likely corrupt", err, otherName), restore)
+		default:
+			add(err)
+		}
+	}
+
+	if s.arrayErr != nil {
+		err := errorf(CodeUnreadableBlock, "Could not read %v partition entry array: %w", which, s.arrayErr)
+		if otherOK {
+			add(err, restore)
+		} else {
+			add(err)
+		}
+		return
+	}
+	if s.arrayOK() {
+		return
+	}
+	err := errorf(CodeArrayCRC, "%v partition entry array CRC is %#08x, should be %#08x", which, s.h.PartitionEntryArrayCRC32, s.arrayCRC)
+	switch {
+	case other != nil && other.arrayErr == nil && other.arrayCRC == s.arrayCRC:
+		repairs := []Repair{fixArray}
+		if !s.headerOK && !otherOK {
+			repairs = append(repairs, fixHeader)
+		}
+		add(wrapf(CodeArrayCRC, err, "%w; the array is identical to the %v array, so the stored CRC is likely wrong", err, otherName), repairs...)
+	case otherOK:
+		add(wrapf(CodeArrayCRC, err, "%w; the %v array is intact, so the array is likely corrupt", err, otherName), restore)
+	default:
+		add(err)
+	}
+}
+
+// Applies the repairs suggested by Verify (see Report.Repairs) to hd. The
+// logical block size of hd is found as ReadTable finds it.
+func ApplyRepairs(hd io.ReadWriteSeeker, repairs []Repair) error {
+	bs := findBlockSize(hd)
+	for _, r := range repairs {
+		if err := applyRepair(hd, bs, r); err != nil {
+			return wrapf(CodeIO, err, "%v: %w", r, err)
+		}
+	}
+	return nil
+}
+
+// Applies the repair r to hd, which has logical blocks of bs bytes.
+func applyRepair(hd io.ReadWriteSeeker, bs uint64, r Repair) error {
+	primary, err := readHeader(hd, 1, bs)
+	if err != nil {
+		return err
+	}
+	switch r {
+	case RepairPrimaryHeaderCRC, RepairPrimaryArrayCRC:
+		return fixCRCs(hd, bs, primary, r == RepairPrimaryArrayCRC, r)
+	case RepairBackupHeaderCRC, RepairBackupArrayCRC:
+		backup, err := readHeader(hd, primary.AltLBA, bs)
+		if err != nil {
+			return err
+		}
+		return fixCRCs(hd, bs, backup, r == RepairBackupArrayCRC, r)
+	case RestorePrimaryFromBackup:
+		size, err := deviceSize(hd)
+		if err != nil {
+			return err
+		}
+		// The primary's AltLBA can't be trusted, so the backup is
+		// assumed to be at the end of the device.
+		backup, err := readHeader(hd, size/bs-1, bs)
+		if err != nil {
+			return err
+		}
+		h := backup
+		h.MyLBA, h.AltLBA = backup.AltLBA, backup.MyLBA
+		h.PartitionEntryLBA = 2
+		return copyHeader(hd, bs, backup, h, r)
+	case RestoreBackupFromPrimary:
+		h := primary
+		h.MyLBA, h.AltLBA = primary.AltLBA, primary.MyLBA
+		h.PartitionEntryLBA = primary.AltLBA - primary.entryArrayBlocks(bs)
+		return copyHeader(hd, bs, primary, h, r)
+	}
+	return errorf(CodeInvalidArgument, "Unknown repair")
+}
+
+// Recomputes the header CRC of h, and the entry array CRC if array is set,
+// and writes it back to hd, which has logical blocks of bs bytes.
+func fixCRCs(hd io.ReadWriteSeeker, bs uint64, h GPTHeader, array bool, r Repair) error {
+	if array {
+		a, err := readEntryArray(hd, h, bs)
+		if err != nil {
+			return err
+		}
+		h.PartitionEntryArrayCRC32 = crc32.ChecksumIEEE(a)
+	}
+	if !validHeaderSize(h, bs) {
+		return errorf(CodeInvalidHeader, "Invalid header size %d", h.HeaderSize)
+	}
+	h.HeaderCRC32 = h.computeCRC()
+	return execute(hd, discardLogger, &Plan{
+		Operation: r.String(),
+		BlockSize: bs,
+		Writes:    []PlannedWrite{{h.MyLBA, h.encodeBlock(bs), "header"}},
+	}, SyncNever)
+}
+
+// Copies the entry array of src to the location in dst, and writes dst with
+// a recomputed CRC, on a disk with logical blocks of bs bytes.
+func copyHeader(hd io.ReadWriteSeeker, bs uint64, src, dst GPTHeader, r Repair) error {
+	if string(src.Signature[:]) != "EFI PART" {
+		return errorf(CodeInvalidHeader, "No valid GPT header at LBA %d", src.MyLBA)
+	}
+	array, err := readEntryArray(hd, src, bs)
+	if err != nil {
+		return err
+	}
+	if !validHeaderSize(dst, bs) {
+		return errorf(CodeInvalidHeader, "Invalid header size %d", dst.HeaderSize)
+	}
+	dst.HeaderCRC32 = dst.computeCRC()
+	return execute(hd, discardLogger, &Plan{
+		Operation: r.String(),
+		BlockSize: bs,
+		Writes: []PlannedWrite{
+			{dst.PartitionEntryLBA, array, "partition entry array"},
+			{dst.MyLBA, dst.encodeBlock(bs), "header"},
+		},
+	}, SyncNever)
+}
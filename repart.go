@@ -0,0 +1,237 @@
+package gpt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Maps the symbolic partition type identifiers used by systemd-repart which
+// are not architecture specific to their partition type.
+var repartTypes = map[string]GUID{
+	"esp":           EFISystemPartition,
+	"xbootldr":      DPSExtendedBoot,
+	"swap":          DPSSwap,
+	"home":          DPSHome,
+	"srv":           DPSSrv,
+	"var":           DPSVar,
+	"tmp":           DPSTmp,
+	"user-home":     DPSUserHome,
+	"linux-generic": LinuxFilesystem,
+}
+
+// Returns the partition type for a systemd-repart Type= value, which may be
+// either a symbolic identifier or a GUID.
+func repartType(s string) (GUID, error) {
+	if g, ok := repartTypes[s]; ok {
+		return g, nil
+	}
+	for _, prefix := range []string{"root", "usr"} {
+		if s != prefix && !strings.HasPrefix(s, prefix+"-") {
+			continue
+		}
+		arch := strings.TrimPrefix(strings.TrimPrefix(s, prefix), "-")
+		if arch == "" {
+			arch = dpsArchName(runtime.GOARCH)
+		}
+		if a, ok := dpsArches[arch]; ok {
+			if prefix == "root" {
+				return a.root, nil
+			}
+			return a.usr, nil
+		}
+	}
+	if g, err := ParseGUID(s); err == nil {
+		return g, nil
+	}
+	return ZeroGUID, errorf(CodeUnknownType, "Unknown partition type \"%v\"", s)
+}
+
+// Returns the systemd-repart Type= value for the partition type g, using a
+// symbolic identifier where one exists.
+func repartTypeName(g GUID) string {
+	for name, t := range repartTypes {
+		if t == g {
+			return name
+		}
+	}
+	for arch, a := range dpsArches {
+		switch g {
+		case a.root:
+			return "root-" + arch
+		case a.usr:
+			return "usr-" + arch
+		}
+	}
+	return strings.ToLower(g.String())
+}
+
+// Parses a systemd-repart partition definition (a repart.d(5) *.conf file.)
+//
+// The Type=, Label=, UUID=, SizeMinBytes=, SizeMaxBytes=, Flags=,
+// ReadOnly=, NoAuto= and GrowFileSystem= settings of the [Partition] section
+// are supported. Other settings, which describe the contents of the partition
+// rather than the partition itself, are ignored.
+func ParseRepart(r io.Reader) (PartitionSpec, error) {
+	var p PartitionSpec
+	section := ""
+	hasType := false
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || s[0] == '#' || s[0] == ';' {
+			continue
+		}
+		if s[0] == '[' && s[len(s)-1] == ']' {
+			section = s[1 : len(s)-1]
+			continue
+		}
+		if section != "Partition" {
+			continue
+		}
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return p, errorf(CodeInvalidArgument, "Line %d: invalid setting \"%v\"", line, s)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "Type":
+			p.Type, err = repartType(value)
+			hasType = true
+		case "Label":
+			p.Name = value
+		case "UUID":
+			p.UUID, err = ParseGUID(value)
+		case "SizeMinBytes":
+			p.MinSize, err = parseRepartSize(value)
+		case "SizeMaxBytes":
+			p.MaxSize, err = parseRepartSize(value)
+		case "Flags":
+			var flags uint64
+			flags, err = strconv.ParseUint(value, 0, 64)
+			p.Attributes = GPTPartitionAttribute(flags)
+		case "ReadOnly":
+			err = setRepartFlag(&p.Attributes, DPSReadOnly, value)
+		case "NoAuto":
+			err = setRepartFlag(&p.Attributes, DPSNoAuto, value)
+		case "GrowFileSystem":
+			err = setRepartFlag(&p.Attributes, DPSGrowFS, value)
+		}
+		if err != nil {
+			return p, wrapf(CodeInvalidArgument, err, "Line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return p, err
+	}
+	if !hasType {
+		return p, errorf(CodeInvalidArgument, "Partition definition has no Type=")
+	}
+	return p, nil
+}
+
+// Writes the partition spec as a systemd-repart partition definition.
+func (p PartitionSpec) WriteRepart(w io.Writer) error {
+	fmt.Fprintf(w, "[Partition]\nType=%s\n", repartTypeName(p.Type))
+	if p.Name != "" {
+		fmt.Fprintf(w, "Label=%s\n", p.Name)
+	}
+	if !p.UUID.IsZero() {
+		fmt.Fprintf(w, "UUID=%s\n", strings.ToLower(p.UUID.String()))
+	}
+	if p.MinSize != 0 {
+		fmt.Fprintf(w, "SizeMinBytes=%d\n", p.MinSize)
+	}
+	if p.MaxSize != 0 {
+		fmt.Fprintf(w, "SizeMaxBytes=%d\n", p.MaxSize)
+	}
+	_, err := fmt.Fprintf(w, "Flags=%#x\n", uint64(p.Attributes))
+	return err
+}
+
+// Reads the systemd-repart partition definitions in dir into a Layout. As with
+// systemd-repart, the *.conf files are ordered by their file name.
+func ReadRepartDir(dir string) (Layout, error) {
+	var l Layout
+	files, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		return l, err
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return l, err
+		}
+		p, err := ParseRepart(f)
+		f.Close()
+		if err != nil {
+			return l, wrapf(CodeInvalidArgument, err, "%v: %w", name, err)
+		}
+		l.Partitions = append(l.Partitions, p)
+	}
+	return l, nil
+}
+
+// Writes each partition of the layout to dir as a systemd-repart partition
+// definition. The files are named so that they sort in the order of the
+// layout (ie. "00-esp.conf", "01-root-x86-64.conf".)
+func (l Layout) WriteRepartDir(dir string) error {
+	for i, p := range l.Partitions {
+		name := filepath.Join(dir, fmt.Sprintf("%02d-%s.conf", i, repartTypeName(p.Type)))
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := p.WriteRepart(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets or clears the attribute flag according to the systemd boolean value.
+func setRepartFlag(attrs *GPTPartitionAttribute, flag GPTPartitionAttribute, value string) error {
+	switch strings.ToLower(value) {
+	case "1", "yes", "y", "true", "t", "on":
+		*attrs |= flag
+	case "0", "no", "n", "false", "f", "off":
+		*attrs &^= flag
+	default:
+		return errorf(CodeInvalidArgument, "Invalid boolean \"%v\"", value)
+	}
+	return nil
+}
+
+// Parses a systemd size, which is a number of bytes with an optional base
+// 1024 suffix (K, M, G, T, P or E.)
+func parseRepartSize(s string) (uint64, error) {
+	s = strings.TrimSuffix(s, "B")
+	mult := uint64(1)
+	if i := strings.IndexFunc(s, unicode.IsLetter); i >= 0 {
+		exp := strings.Index("KMGTPE", strings.ToUpper(s[i:]))
+		if exp < 0 || len(s[i:]) != 1 {
+			return 0, errorf(CodeInvalidArgument, "Invalid size \"%v\"", s)
+		}
+		mult = 1 << (10 * uint(exp+1))
+		s = s[:i]
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, errorf(CodeInvalidArgument, "Invalid size \"%v\"", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,173 @@
+package gpt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The Severity of a verification Finding.
+type Severity int
+
+const (
+	// A condition which is legal but non-standard, or likely to cause
+	// problems with some tools, such as a misaligned partition.
+	SeverityWarning Severity = iota
+
+	// A condition which violates the UEFI specification, such as an
+	// incorrect checksum or overlapping partitions.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// A Finding is a single problem found by Verify or Validate.
+type Finding struct {
+	Severity Severity
+	Err      error
+
+	// The index of the partition entry that the problem is with, or -1 if
+	// it isn't with a single entry (or is with the disk GUID).
+	Index int
+
+	// The repairs which would fix the problem, if any are known.
+	Repairs []Repair
+}
+
+func (f Finding) Error() string {
+	return f.Err.Error()
+}
+
+func (f Finding) Unwrap() error {
+	return f.Err
+}
+
+// Returns the code of the problem, as returned by CodeOf.
+func (f Finding) Code() ErrorCode {
+	return CodeOf(f.Err)
+}
+
+// Encodes the finding as a JSON object with its severity, code, message, the
+// index of the partition entry (if any) and the names of the repairs.
+func (f Finding) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Severity Severity  `json:"severity"`
+		Code     ErrorCode `json:"code"`
+		Message  string    `json:"message"`
+		Index    *int      `json:"index,omitempty"`
+		Repairs  []Repair  `json:"repairs,omitempty"`
+	}{f.Severity, f.Code(), f.Error(), nil, f.Repairs}
+	if f.Index >= 0 {
+		v.Index = &f.Index
+	}
+	return json.Marshal(v)
+}
+
+// A Report is the result of verifying a disk with Verify, or a table with
+// Validate.
+type Report struct {
+	// The problems found, in the order they were found.
+	Findings []Finding
+}
+
+// Encodes the report as a JSON object with a "findings" array, which is empty
+// if nothing was found.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	findings := r.Findings
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return json.Marshal(struct {
+		Findings []Finding `json:"findings"`
+	}{findings})
+}
+
+// Adds a finding of severity sev, which repairs would fix, to the report if
+// err is not nil.
+func (r *Report) add(sev Severity, err error, repairs ...Repair) {
+	if err != nil {
+		r.Findings = append(r.Findings, Finding{sev, err, entryOf(err), repairs})
+	}
+}
+
+// Adds a finding of severity sev to the report for each error in errs.
+func (r *Report) addAll(sev Severity, errs []error) {
+	for _, err := range errs {
+		r.add(sev, err)
+	}
+}
+
+// Returns an error combining all of the findings which are errors, or nil if
+// there are none. Warnings are ignored, so a nil result means the disk is
+// valid.
+func (r *Report) Err() error {
+	var errs []error
+	for _, f := range r.Errors() {
+		errs = append(errs, f)
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the findings which are errors.
+func (r *Report) Errors() []Finding {
+	var e []Finding
+	for _, f := range r.Findings {
+		if f.Severity >= SeverityError {
+			e = append(e, f)
+		}
+	}
+	return e
+}
+
+// Returns the findings which are warnings.
+func (r *Report) Warnings() []Finding {
+	var w []Finding
+	for _, f := range r.Findings {
+		if f.Severity == SeverityWarning {
+			w = append(w, f)
+		}
+	}
+	return w
+}
+
+// Returns the findings as text, one per line with the severity, followed by
+// a line for each suggested repair.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "%v: %v\n", f.Severity, f)
+		for _, rep := range f.Repairs {
+			fmt.Fprintf(&b, "\tsuggested repair: %v\n", rep)
+		}
+	}
+	return b.String()
+}
+
+// Returns the repairs suggested by all of the findings, without duplicates,
+// in an order that they can be passed to ApplyRepairs.
+func (r *Report) Repairs() []Repair {
+	var repairs []Repair
+	seen := make(map[Repair]bool)
+	for _, f := range r.Findings {
+		for _, rep := range f.Repairs {
+			if !seen[rep] {
+				seen[rep] = true
+				repairs = append(repairs, rep)
+			}
+		}
+	}
+	return repairs
+}
@@ -0,0 +1,51 @@
+package gpt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Checks that reading the table on hd and writing it back without any
+// modifications would leave every sector of the table unchanged, including
+// reserved fields, padding and the order of the entries. Returns an error
+// describing each sector which would change, or nil if none would. Nothing
+// is written to hd.
+//
+// A table which has to be read from its backup header always fails the
+// check, since writing it restores the primary header.
+func RoundTripCheck(hd io.ReadSeeker) error {
+	t, err := ReadTable(hd)
+	if err != nil {
+		return err
+	}
+	if t.FromBackup {
+		return errorf(CodeBackupOnly, "Table was read from the backup header, writing it would restore the primary header")
+	}
+	p, err := t.writePlan("round trip check")
+	if err != nil {
+		return err
+	}
+	bs := t.BlockSize()
+	var diffs []string
+	for _, w := range p.Writes {
+		if _, err := hd.Seek(int64(w.LBA*bs), io.SeekStart); err != nil {
+			return err
+		}
+		old := make([]byte, len(w.Data))
+		if _, err := io.ReadFull(hd, old); err != nil {
+			return wrapf(CodeIO, err, "Could not read %s at LBA %d: %w", w.Description, w.LBA, err)
+		}
+		for off := uint64(0); off < uint64(len(old)); off += bs {
+			end := min(off+bs, uint64(len(old)))
+			if !bytes.Equal(old[off:end], w.Data[off:end]) {
+				diffs = append(diffs, fmt.Sprintf("LBA %d (%s)", w.LBA+off/bs, w.Description))
+			}
+		}
+	}
+	if len(diffs) > 0 {
+		return errorf(CodeRoundTrip, "Writing the table back would change %s", strings.Join(diffs, ", "))
+	}
+	return nil
+}
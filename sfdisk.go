@@ -0,0 +1,258 @@
+package gpt
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The names that sfdisk uses for the attribute bits defined by the UEFI
+// specification.
+var sfdiskAttributes = map[string]GPTPartitionAttribute{
+	"RequiredPartition":  GPTPartitionSystem,
+	"NoBlockIOProtocol":  GPTPartitionNoBlockIOProtocol,
+	"LegacyBIOSBootable": GPTPartitionLegacyBIOSBootable,
+}
+
+// Parses a partition table written by "sfdisk --dump" (or "sfdisk -d") into a
+// new table for a device which is size bytes long.
+//
+// The label-id, first-lba, last-lba and table-length headers are used if
+// they're present, and the disk GUID, usable area and number of partition
+// entries are otherwise the same as sfdisk's defaults. The start, size, type,
+// uuid, name and attrs fields of each partition are supported. Each partition
+// is put in the entry numbered by the trailing digits of its device name
+// (ie. /dev/sda3 is index 2), or the next entry if the name has no number.
+// Partitions without a uuid are given a random unique GUID.
+//
+// If size is zero, the device is assumed to end right after the backup
+// partition entry array which follows the dump's last-lba, as it does on the
+// disk that was dumped.
+//
+// The dump must be of a GPT label. Its sector-size is the logical block size
+// of the table, which defaults to 512 bytes.
+func ParseSfdisk(r io.Reader, size uint64) (*Table, error) {
+	entries := uint32(128)
+	bs := LogicalBlockSize
+	var disk GUID
+	var firstLBA, lastLBA uint64
+	type partition struct {
+		line  int
+		index int
+		e     GPTPartitionEntry
+	}
+	var parts []partition
+	next := 0
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || s[0] == '#' {
+			continue
+		}
+		if name, fields, ok := strings.Cut(s, " : "); ok {
+			e, err := parseSfdiskPartition(fields)
+			if err != nil {
+				return nil, wrapf(CodeInvalidArgument, err, "Line %d: %w", line, err)
+			}
+			idx := next
+			name = strings.TrimSpace(name)
+			if n, err := strconv.Atoi(name[len(strings.TrimRight(name, "0123456789")):]); err == nil {
+				idx = n - 1
+			}
+			next = idx + 1
+			parts = append(parts, partition{line, idx, e})
+			continue
+		}
+
+		key, value, ok := strings.Cut(s, ":")
+		if !ok {
+			return nil, errorf(CodeInvalidArgument, "Line %d: invalid header \"%v\"", line, s)
+		}
+		value = strings.TrimSpace(value)
+		var err error
+		switch strings.TrimSpace(key) {
+		case "label":
+			if value != "gpt" {
+				err = errorf(CodeUnsupported, "Unsupported label \"%v\", only gpt is supported", value)
+			}
+		case "label-id":
+			disk, err = ParseGUID(value)
+		case "unit":
+			if value != "sectors" {
+				err = errorf(CodeUnsupported, "Unsupported unit \"%v\"", value)
+			}
+		case "sector-size":
+			if bs, err = strconv.ParseUint(value, 10, 64); err == nil {
+				err = validBlockSize(bs)
+			}
+		case "first-lba":
+			firstLBA, err = strconv.ParseUint(value, 10, 64)
+		case "last-lba":
+			lastLBA, err = strconv.ParseUint(value, 10, 64)
+		case "table-length":
+			var n uint64
+			n, err = strconv.ParseUint(value, 10, 32)
+			entries = uint32(n)
+		}
+		if err != nil {
+			return nil, wrapf(CodeInvalidArgument, err, "Line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if size == 0 {
+		if lastLBA == 0 {
+			return nil, errorf(CodeInvalidArgument, "The device size is required for a dump without a last-lba")
+		}
+		size = (lastLBA + BytesToLBAs(uint64(entries)*128, bs) + 2) * bs
+	}
+	t, err := newTable(size, entries, bs)
+	if err != nil {
+		return nil, err
+	}
+	if !disk.IsZero() {
+		t.Primary.Disk = disk
+	}
+	if firstLBA != 0 {
+		if firstLBA < t.Primary.FirstUseableLBA || firstLBA > t.Primary.LastUseableLBA {
+			return nil, errorf(CodeInvalidArgument, "first-lba %d is outside of the usable area of the device (LBA %d-%d)", firstLBA, t.Primary.FirstUseableLBA, t.Primary.LastUseableLBA)
+		}
+		t.Primary.FirstUseableLBA = firstLBA
+	}
+	if lastLBA != 0 {
+		if lastLBA < t.Primary.FirstUseableLBA || lastLBA > t.Primary.LastUseableLBA {
+			return nil, errorf(CodeInvalidArgument, "last-lba %d is outside of the usable area of the device (LBA %d-%d)", lastLBA, t.Primary.FirstUseableLBA, t.Primary.LastUseableLBA)
+		}
+		t.Primary.LastUseableLBA = lastLBA
+	}
+
+	for _, p := range parts {
+		if p.index < 0 || p.index >= len(t.Entries) {
+			return nil, errorf(CodeInvalidArgument, "Line %d: partition number %d is not in the table (1-%d)", p.line, p.index+1, len(t.Entries))
+		}
+		if !t.Entries[p.index].PartitionType.IsZero() {
+			return nil, errorf(CodeInvalidArgument, "Line %d: partition number %d is used more than once", p.line, p.index+1)
+		}
+		if p.e.StartingLBA < t.Primary.FirstUseableLBA || p.e.EndingLBA > t.Primary.LastUseableLBA {
+			return nil, errorf(CodeOutsideUsable, "Line %d: partition (LBA %d-%d) is outside of the usable area (LBA %d-%d)", p.line, p.e.StartingLBA, p.e.EndingLBA, t.Primary.FirstUseableLBA, t.Primary.LastUseableLBA)
+		}
+		if p.e.UniqueParitition.IsZero() {
+			if p.e.UniqueParitition, err = NewGUID(); err != nil {
+				return nil, err
+			}
+		}
+		t.Entries[p.index] = p.e
+	}
+	t.syncBackup()
+	return t, nil
+}
+
+// Parses the comma separated fields of a partition in an sfdisk dump.
+func parseSfdiskPartition(fields string) (GPTPartitionEntry, error) {
+	var e GPTPartitionEntry
+	var start, size uint64
+	var hasStart, hasSize, hasType bool
+	for fields = strings.TrimSpace(fields); fields != ""; {
+		key, rest, ok := strings.Cut(fields, "=")
+		if !ok {
+			return e, errorf(CodeInvalidArgument, "Invalid field \"%v\"", fields)
+		}
+		key = strings.TrimSpace(key)
+		value, rest, err := sfdiskValue(strings.TrimSpace(rest))
+		if err != nil {
+			return e, err
+		}
+		fields = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+
+		switch key {
+		case "start":
+			start, err = strconv.ParseUint(value, 10, 64)
+			hasStart = true
+		case "size":
+			size, err = strconv.ParseUint(value, 10, 64)
+			hasSize = true
+		case "type":
+			e.PartitionType, err = ParseType(value)
+			hasType = true
+		case "uuid":
+			e.UniqueParitition, err = ParseGUID(value)
+		case "name":
+			err = e.SetName(value)
+		case "attrs":
+			e.Attributes, err = parseSfdiskAttributes(value)
+		}
+		if err != nil {
+			return e, err
+		}
+	}
+	switch {
+	case !hasStart:
+		return e, errorf(CodeInvalidArgument, "Partition has no start")
+	case !hasSize:
+		return e, errorf(CodeInvalidArgument, "Partition has no size")
+	case !hasType:
+		return e, errorf(CodeInvalidArgument, "Partition has no type")
+	case size == 0:
+		return e, errorf(CodeInvalidArgument, "Partition is empty")
+	case e.PartitionType.IsZero():
+		return e, errorf(CodeInvalidArgument, "Partition has the unused partition type")
+	}
+	e.StartingLBA = start
+	e.EndingLBA = start + size - 1
+	return e, nil
+}
+
+// Returns the value at the start of s, which is either quoted, with \xNN
+// escapes, or ends at the next comma, and the rest of s after it.
+func sfdiskValue(s string) (value, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		value, rest, _ = strings.Cut(s, ",")
+		return strings.TrimSpace(value), rest, nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			return b.String(), s[i+1:], nil
+		case s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x':
+			c, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			if err != nil {
+				return "", "", errorf(CodeInvalidArgument, "Invalid escape \"%v\"", s[i:i+4])
+			}
+			b.WriteByte(byte(c))
+			i += 3
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", errorf(CodeInvalidArgument, "Unterminated quoted value %v", s)
+}
+
+// Parses the attrs field of a partition in an sfdisk dump, which is a space
+// separated list of attribute names and "GUID:" followed by a comma separated
+// list of GUID specific attribute bits.
+func parseSfdiskAttributes(s string) (GPTPartitionAttribute, error) {
+	var a GPTPartitionAttribute
+	for _, name := range strings.Fields(s) {
+		if bit, ok := sfdiskAttributes[name]; ok {
+			a |= bit
+			continue
+		}
+		bits, ok := strings.CutPrefix(name, "GUID:")
+		if !ok {
+			return a, errorf(CodeInvalidArgument, "Unknown attribute \"%v\"", name)
+		}
+		for _, b := range strings.Split(bits, ",") {
+			n, err := strconv.ParseUint(b, 10, 8)
+			if err != nil || n < 48 || n > 63 {
+				return a, errorf(CodeInvalidArgument, "Invalid GUID specific attribute bit \"%v\"", b)
+			}
+			a |= 1 << n
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,214 @@
+package gpt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"os"
+	"strconv"
+)
+
+// Overwrites the contents of the partition at index of the table, which was
+// read from dev, with passes passes of random data followed by a pass of
+// zeros. dev is synced after each pass.
+//
+// The table's Progress function, if any, is called as the partition is
+// overwritten, and the data is written at no more than the table's RateLimit.
+//
+// Note that overwriting doesn't reliably erase data on flash storage, which
+// remaps writes. Use DiscardPartition or DestroyLUKSHeader on those devices.
+func (t *Table) ShredPartition(dev io.ReadWriteSeeker, index, passes int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if passes < 0 {
+		return errorf(CodeInvalidArgument, "Invalid number of passes %d", passes)
+	}
+	view, err := t.PartitionView(dev, index)
+	if err != nil {
+		return err
+	}
+	p := newProgress(t.Progress, uint64(view.size)*uint64(passes+1))
+	defer p.finish()
+	l := newRateLimiter(t.RateLimit)
+	buf := make([]byte, copyBufferSize)
+	for pass := 1; pass <= passes+1; pass++ {
+		p.phase(fmt.Sprintf("shredding partition %d (pass %d of %d)", index, pass, passes+1))
+		var src io.Reader = zeroReader{}
+		if pass <= passes {
+			var seed [32]byte
+			if _, err := rand.Read(seed[:]); err != nil {
+				return err
+			}
+			src = mrand.NewChaCha8(seed)
+		}
+		if err := fillRange(view, 0, view.size, src, buf, p, l); err != nil {
+			return wrapf(CodeIO, err, "Could not shred partition %d: %w", index, err)
+		}
+		if err := syncDevice(dev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A reader which reads zeros forever.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Writes n bytes read from src to view, starting at off, using buf.
+func fillRange(view *OffsetDevice, off, n int64, src io.Reader, buf []byte, p *progress, l *rateLimiter) error {
+	if _, err := view.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	for end := off + n; off < end; {
+		chunk := buf[:min(int64(len(buf)), end-off)]
+		if _, err := io.ReadFull(src, chunk); err != nil {
+			return err
+		}
+		if _, err := view.Write(chunk); err != nil {
+			return wrapf(CodeIO, err, "Could not write at byte %d: %w", view.offset+off, err)
+		}
+		p.add(uint64(len(chunk)))
+		l.wait(uint64(len(chunk)))
+		off += int64(len(chunk))
+	}
+	return nil
+}
+
+// Discards the contents of the partition at index of the table, which was
+// read from dev. dev must be an *os.File. If it's a block device, the
+// partition's blocks are discarded with BLKDISCARD (the same as
+// blkdiscard(8)), otherwise a hole is punched in the file.
+//
+// Whether discarded blocks read as zeros, or can still be recovered, depends
+// on the device.
+func (t *Table) DiscardPartition(dev io.ReadWriteSeeker, index int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	f, ok := dev.(*os.File)
+	if !ok {
+		return errorf(CodeUnsupported, "Can only discard partitions of a block device or file")
+	}
+	view, err := t.PartitionView(dev, index)
+	if err != nil {
+		return err
+	}
+	p := newProgress(t.Progress, uint64(view.size))
+	defer p.finish()
+	p.phase(fmt.Sprintf("discarding partition %d", index))
+	if err := discardRange(f, view.offset, view.size); err != nil {
+		return wrapf(CodeIO, err, "Could not discard partition %d: %w", index, err)
+	}
+	p.add(uint64(view.size))
+	return nil
+}
+
+// The LUKS header fields which locate the key material. HeaderSize is the
+// size of the LUKS2 binary header and JSON metadata, and overlaps the cipher
+// name in LUKS1. PayloadOffset is the offset of the encrypted data in LUKS1,
+// in sectors.
+type luksHeader struct {
+	Magic         [6]byte
+	Version       uint16
+	HeaderSize    uint64
+	_             [88]byte
+	PayloadOffset uint32
+}
+
+// Overwrites the LUKS header and key slots of the LUKS encrypted partition at
+// index of the table, which was read from dev, with random data, and syncs
+// dev. Without the key slots, the encrypted contents of the partition can't
+// be decrypted even with the passphrase, which is the quickest way to make
+// them unrecoverable.
+func (t *Table) DestroyLUKSHeader(dev io.ReadWriteSeeker, index int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	fstype, err := t.ProbeFilesystem(dev, index)
+	if err != nil {
+		return err
+	}
+	if fstype != "crypto_LUKS" {
+		return errorf(CodeInvalidArgument, "Partition %d is not LUKS encrypted", index)
+	}
+	view, err := t.PartitionView(dev, index)
+	if err != nil {
+		return err
+	}
+	size, err := luksMetadataSize(view)
+	if err != nil {
+		return wrapf(CodeIO, err, "Could not read LUKS header of partition %d: %w", index, err)
+	}
+	size = min(size, view.size)
+
+	p := newProgress(t.Progress, uint64(size))
+	defer p.finish()
+	p.phase(fmt.Sprintf("destroying LUKS header of partition %d", index))
+	var seed [32]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return err
+	}
+	if err := fillRange(view, 0, size, mrand.NewChaCha8(seed), make([]byte, copyBufferSize), p, newRateLimiter(t.RateLimit)); err != nil {
+		return wrapf(CodeIO, err, "Could not destroy LUKS header of partition %d: %w", index, err)
+	}
+	return syncDevice(dev)
+}
+
+// Returns the size in bytes of the LUKS headers and key slots at the start of
+// view.
+func luksMetadataSize(view *OffsetDevice) (int64, error) {
+	if _, err := view.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var h luksHeader
+	if err := binary.Read(view, binary.BigEndian, &h); err != nil {
+		return 0, err
+	}
+	switch h.Version {
+	case 1:
+		if h.PayloadOffset == 0 {
+			return 0, errorf(CodeUnsupported, "LUKS1 header has no payload offset")
+		}
+		return int64(h.PayloadOffset) * 512, nil
+	case 2:
+	default:
+		return 0, errorf(CodeUnsupported, "Unknown LUKS version %d", h.Version)
+	}
+
+	// The binary header is followed by the JSON metadata, and then by a
+	// second copy of both. The key slots follow the second copy.
+	const binaryHeaderSize = 4096
+	if h.HeaderSize <= binaryHeaderSize || h.HeaderSize > 4<<20 {
+		return 0, errorf(CodeUnsupported, "Invalid LUKS2 header size %d", h.HeaderSize)
+	}
+	js := make([]byte, h.HeaderSize-binaryHeaderSize)
+	if _, err := view.Seek(binaryHeaderSize, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(view, js); err != nil {
+		return 0, err
+	}
+	var meta struct {
+		Config struct {
+			KeyslotsSize string `json:"keyslots_size"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(bytes.TrimRight(js, "\x00"), &meta); err != nil {
+		return 0, err
+	}
+	keyslots, err := strconv.ParseInt(meta.Config.KeyslotsSize, 10, 64)
+	if err != nil {
+		return 0, errorf(CodeUnsupported, "Invalid LUKS2 keyslots_size \"%v\"", meta.Config.KeyslotsSize)
+	}
+	return 2*int64(h.HeaderSize) + keyslots, nil
+}
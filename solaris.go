@@ -0,0 +1,18 @@
+package gpt
+
+// Solaris and illumos partition types.
+var (
+	SolarisBoot      = registerType("6A82CB45-1DD2-11B2-99A6-080020736631", "Solaris boot", 0xBE00, OSSolaris, CategoryBoot)
+	SolarisRoot      = registerType("6A85CF4D-1DD2-11B2-99A6-080020736631", "Solaris root", 0xBF00, OSSolaris, CategorySystem)
+	SolarisUsr       = registerType("6A898CC3-1DD2-11B2-99A6-080020736631", "Solaris /usr & Apple ZFS", 0xBF01, OSSolaris, CategorySystem)
+	SolarisSwap      = registerType("6A87C46F-1DD2-11B2-99A6-080020736631", "Solaris swap", 0xBF02, OSSolaris, CategorySwap)
+	SolarisBackup    = registerType("6A8B642B-1DD2-11B2-99A6-080020736631", "Solaris backup", 0xBF03, OSSolaris, CategoryReserved)
+	SolarisVar       = registerType("6A8EF2E9-1DD2-11B2-99A6-080020736631", "Solaris /var", 0xBF04, OSSolaris, CategoryData)
+	SolarisHome      = registerType("6A90BA39-1DD2-11B2-99A6-080020736631", "Solaris /home", 0xBF05, OSSolaris, CategoryData)
+	SolarisAlternate = registerType("6A9283A5-1DD2-11B2-99A6-080020736631", "Solaris alternate sector", 0xBF06, OSSolaris, CategoryReserved)
+	SolarisReserved1 = registerType("6A945A3B-1DD2-11B2-99A6-080020736631", "Solaris Reserved 1", 0xBF07, OSSolaris, CategoryReserved)
+	SolarisReserved2 = registerType("6A9630D1-1DD2-11B2-99A6-080020736631", "Solaris Reserved 2", 0xBF08, OSSolaris, CategoryReserved)
+	SolarisReserved3 = registerType("6A980767-1DD2-11B2-99A6-080020736631", "Solaris Reserved 3", 0xBF09, OSSolaris, CategoryReserved)
+	SolarisReserved4 = registerType("6A96237F-1DD2-11B2-99A6-080020736631", "Solaris Reserved 4", 0xBF0A, OSSolaris, CategoryReserved)
+	SolarisReserved5 = registerType("6A8D2AC7-1DD2-11B2-99A6-080020736631", "Solaris Reserved 5", 0xBF0B, OSSolaris, CategoryReserved)
+)
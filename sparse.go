@@ -0,0 +1,238 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Returns true if every byte of p is zero.
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Zeroes n bytes at off of view, which is a view of dev. If dev is a file
+// which supports it, a hole is punched instead of writing zeros. Otherwise,
+// zeros are written with buf, skipping any holes in dev since they already
+// read as zeros. Only the writes are limited by l.
+func zeroRange(dev io.Writer, view *OffsetDevice, off, n int64, buf []byte, p *progress, l *rateLimiter) error {
+	f, _ := dev.(*os.File)
+	if f != nil && punchHole(f, view.offset+off, n) == nil {
+		p.add(uint64(n))
+		return nil
+	}
+	clear(buf)
+	for end := off + n; off < end; {
+		if f != nil {
+			data, err := nextData(f, view.offset+off)
+			if err != nil {
+				return err
+			}
+			if data < 0 || data-view.offset > end {
+				data = view.offset + end
+			}
+			if hole := data - view.offset - off; hole > 0 {
+				p.add(uint64(hole))
+				off += hole
+				continue
+			}
+		}
+		chunk := min(int64(len(buf)), end-off)
+		if _, err := view.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := view.Write(buf[:chunk]); err != nil {
+			return err
+		}
+		p.add(uint64(chunk))
+		l.wait(uint64(chunk))
+		off += chunk
+	}
+	return nil
+}
+
+// A copy of the contents of the view src of srcDev to the start of the view
+// dst of dstDev.
+type rangeCopy struct {
+	srcDev io.Reader
+	src    *OffsetDevice
+	dstDev io.Writer
+	dst    *OffsetDevice
+
+	// If set, all-zero chunks and holes in srcDev are zeroed with
+	// zeroRange rather than written. Overlapping copies are never sparse.
+	sparse bool
+
+	p *progress
+	l *rateLimiter
+
+	// If set, dstDev is synced and the number of bytes copied is recorded
+	// in the journal every journalInterval bytes.
+	j *Journal
+
+	// Set if srcDev and dstDev are the same device, so src and dst may
+	// overlap.
+	sameDev bool
+}
+
+// Returns true if the copy is within one device and its source and
+// destination overlap.
+func (c *rangeCopy) overlapping() bool {
+	return c.sameDev && c.src.offset < c.dst.offset+c.src.size && c.dst.offset < c.src.offset+c.src.size
+}
+
+// Copies the rest of the region, starting from the byte offset from.
+//
+// If the source and destination overlap, the copy is made in the direction
+// which reads every chunk before it's overwritten, ie. backwards from the end
+// if the destination is after the source. from and the progress recorded in
+// the journal are then the number of bytes copied from the end. The journal
+// is also updated at least every time the copy has moved as many bytes as
+// the distance between the source and destination, so that resuming the
+// copy never reads a chunk of the source that was overwritten after the
+// journal was last updated.
+func (c *rangeCopy) run(from int64) error {
+	src, dst := c.src, c.dst
+	buf := make([]byte, copyBufferSize)
+	srcFile, _ := c.srcDev.(*os.File)
+	sparse := c.sparse
+	backward := false
+	limit := int64(journalInterval)
+	if c.overlapping() {
+		sparse = false
+		backward = dst.offset > src.offset
+		if c.j != nil {
+			limit = min(limit, max(dst.offset-src.offset, src.offset-dst.offset))
+		}
+	}
+	c.p.add(uint64(from))
+	checkpoint := from
+	for done := from; done < src.size; {
+		n := min(int64(len(buf)), src.size-done, limit)
+		if c.j != nil && done+n-checkpoint > limit {
+			if err := syncDevice(c.dstDev); err != nil {
+				return err
+			}
+			if err := c.j.update(uint64(done)); err != nil {
+				return err
+			}
+			checkpoint = done
+		}
+		off := done
+		if backward {
+			off = src.size - done - n
+		}
+		if sparse && srcFile != nil {
+			data, err := nextData(srcFile, src.offset+off)
+			if err != nil {
+				return err
+			}
+			if data < 0 || data-src.offset > src.size {
+				data = src.offset + src.size
+			}
+			if hole := min(data-src.offset-off, journalInterval); hole > 0 {
+				if err := zeroRange(c.dstDev, dst, off, hole, buf, c.p, c.l); err != nil {
+					return err
+				}
+				done += hole
+				continue
+			}
+		}
+
+		if _, err := src.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(src, buf[:n]); err != nil {
+			return wrapf(CodeIO, err, "Could not read at byte %d: %w", src.offset+off, err)
+		}
+		c.l.wait(uint64(n))
+		if sparse && isZero(buf[:n]) {
+			if err := zeroRange(c.dstDev, dst, off, n, buf, c.p, c.l); err != nil {
+				return err
+			}
+			done += n
+			continue
+		}
+		if _, err := dst.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := dst.Write(buf[:n]); err != nil {
+			return wrapf(CodeIO, err, "Could not write at byte %d: %w", dst.offset+off, err)
+		}
+		c.p.add(uint64(n))
+		c.l.wait(uint64(n))
+		done += n
+	}
+	if err := syncDevice(c.dstDev); err != nil {
+		return err
+	}
+	if c.j != nil {
+		return c.j.update(uint64(src.size))
+	}
+	return nil
+}
+
+// Copies count logical blocks of sectorSize bytes of dev from LBA src to LBA
+// dst, which may overlap. The blocks are copied in the direction which reads every block
+// before it's overwritten, so that a region of the disk can be shifted in
+// place, and dev is synced once they've been copied. If fn is set, it's
+// called with the progress of the copy.
+//
+// If the copy is interrupted, the overlapping part of the source may already
+// have been overwritten, so it can't simply be restarted. MovePartition
+// records its progress in a journal to avoid this.
+func MoveBlocks(dev io.ReadWriteSeeker, src, dst, count, sectorSize uint64, fn ProgressFunc) error {
+	if count == 0 || src == dst {
+		return nil
+	}
+	size := int64(count * sectorSize)
+	srcView, err := NewOffsetDevice(dev, int64(src*sectorSize), size)
+	if err != nil {
+		return err
+	}
+	dstView, err := NewOffsetDevice(dev, int64(dst*sectorSize), size)
+	if err != nil {
+		return err
+	}
+	p := newProgress(fn, uint64(size))
+	defer p.finish()
+	c := &rangeCopy{srcDev: dev, src: srcView, dstDev: dev, dst: dstView, p: p, l: newRateLimiter(0), sameDev: true}
+	return c.run(0)
+}
+
+// Syncs dev if it has a Sync method (ie. it's an *os.File.)
+func syncDevice(dev io.Writer) error {
+	if s, ok := dev.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Zeroes the contents of the partition at index of the table, which was read
+// from dev, and syncs dev. If dev is a file which supports it, holes are
+// punched rather than writing zeros (which also discards the blocks of a
+// block device), and holes which already exist are skipped.
+//
+// The table's Progress function, if any, is called as the partition is
+// wiped, and zeros are written at no more than the table's RateLimit.
+func (t *Table) WipePartition(dev io.ReadWriteSeeker, index int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	view, err := t.PartitionView(dev, index)
+	if err != nil {
+		return err
+	}
+	p := newProgress(t.Progress, uint64(view.size))
+	defer p.finish()
+	p.phase(fmt.Sprintf("wiping partition %d", index))
+	if err := zeroRange(dev, view, 0, view.size, make([]byte, copyBufferSize), p, newRateLimiter(t.RateLimit)); err != nil {
+		return wrapf(CodeIO, err, "Could not wipe partition %d: %w", index, err)
+	}
+	return syncDevice(dev)
+}
@@ -0,0 +1,52 @@
+package gpt
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Flags and whences which aren't in the syscall package.
+const (
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+
+	seekData = 3
+
+	blkDiscard = 0x1277
+)
+
+// Deallocates n bytes of f at off, so that they read as zeros.
+func punchHole(f *os.File, off, n int64) error {
+	return syscall.Fallocate(int(f.Fd()), fallocPunchHole|fallocKeepSize, off, n)
+}
+
+// Returns the offset of the first data in f at or after off, or -1 if the
+// rest of f is a hole. If f doesn't support finding holes, off is returned.
+func nextData(f *os.File, off int64) (int64, error) {
+	data, err := syscall.Seek(int(f.Fd()), off, seekData)
+	switch err {
+	case nil:
+		return data, nil
+	case syscall.ENXIO:
+		return -1, nil
+	case syscall.EINVAL:
+		return off, nil
+	default:
+		return 0, err
+	}
+}
+
+// Discards n bytes of f at off. If f is a block device, the blocks are
+// discarded with BLKDISCARD, otherwise a hole is punched.
+func discardRange(f *os.File, off, n int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeDevice == 0 {
+		return punchHole(f, off, n)
+	}
+	r := [2]uint64{uint64(off), uint64(n)}
+	return ioctl(f.Fd(), blkDiscard, uintptr(unsafe.Pointer(&r)))
+}
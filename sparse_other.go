@@ -0,0 +1,28 @@
+//go:build !linux
+
+package gpt
+
+import (
+	"errors"
+	"os"
+)
+
+// Deallocates n bytes of f at off, so that they read as zeros.
+//
+// BUG(driusan): Holes are only punched and detected on Linux. On other
+// operating systems, sparse copies and wipes write every zero chunk.
+func punchHole(f *os.File, off, n int64) error {
+	return errors.ErrUnsupported
+}
+
+// Returns the offset of the first data in f at or after off.
+func nextData(f *os.File, off int64) (int64, error) {
+	return off, nil
+}
+
+// Discards n bytes of f at off.
+//
+// BUG(driusan): DiscardPartition is only supported on Linux.
+func discardRange(f *os.File, off, n int64) error {
+	return errors.ErrUnsupported
+}
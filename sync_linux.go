@@ -0,0 +1,12 @@
+package gpt
+
+import (
+	"os"
+	"syscall"
+)
+
+// Flushes the data written to f to stable storage, without the metadata
+// which fsync also flushes.
+func fdatasync(f *os.File) error {
+	return syscall.Fdatasync(int(f.Fd()))
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package gpt
+
+import "os"
+
+// Flushes the data written to f to stable storage. This is a full fsync,
+// since fdatasync is only used on Linux.
+func fdatasync(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,736 @@
+package gpt
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+)
+
+// A Table is a complete GPT partition table, consisting of the primary
+// header, the backup header, and the partition entry array that they both
+// describe.
+type Table struct {
+	// The primary header, which is at LBA 1.
+	Primary GPTHeader
+
+	// The backup header, at the primary header's AltLBA.
+	Backup GPTHeader
+
+	// Set if the primary header was invalid or its checksums were wrong
+	// when the table was read, so the table was read from the backup
+	// header and entry array instead. Primary is then reconstructed from
+	// the backup, and writing the table restores it.
+	FromBackup bool
+
+	// The MBR in LBA 0 when the table was read, or nil if the table wasn't
+	// read from a disk. It's informational only, and isn't written by
+	// Write.
+	MBR *MBR
+
+	// The partition entries. There are always exactly
+	// Primary.MaxNumberPartitionEntries entries, unused entries have a
+	// PartitionType of ZeroGUID.
+	Entries []GPTPartitionEntry
+
+	// If set, debug records are logged for every read and write of the
+	// table, including the sectors and CRCs involved.
+	Logger *slog.Logger
+
+	// If set, called with the progress of long running operations on the
+	// partitions' contents, such as HashPartition.
+	Progress ProgressFunc
+
+	// If non-zero, the maximum bytes per second that operations on the
+	// partitions' contents, such as HashPartition and WipePartition, read
+	// or write.
+	RateLimit uint64
+
+	// The safety checks which are overridden when the table is modified.
+	// See ForceLevel.
+	Force ForceLevel
+
+	// Set if the table was read with Open, in which case the methods
+	// which modify it return ErrReadOnly, unless Force is at least
+	// ForceReadOnly.
+	readOnly bool
+
+	// The bytes after the end of the primary and backup entry arrays in
+	// their last blocks when the table was read, which are written back
+	// unchanged.
+	primaryTail, backupTail []byte
+
+	// The size in bytes of the logical blocks of the disk that the table
+	// is for, or 0 for LogicalBlockSize. See BlockSize.
+	blockSize uint64
+}
+
+// A logger which discards everything, used when a Table has no Logger.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Returns the table's logger, or a logger which discards everything if it has
+// none.
+func (t *Table) logger() *slog.Logger {
+	if t.Logger == nil {
+		return discardLogger
+	}
+	return t.Logger
+}
+
+// Reads the GPT partition table from hd, which should be a io.ReadSeeker
+// (usually an os.File) pointing to the block device for the drive being read.
+// The logical block size of the disk is found with DetectBlockSize, so disks
+// (and images of disks) with 4096 byte blocks are read too, unless it's set
+// with WithSectorSize. The MBR and
+// primary header are read together, and the primary header and the checksums
+// of it and its entry array are verified before the partitions are read. If
+// they're wrong, the table is read from the backup header and entry array
+// instead, and FromBackup is set.
+//
+// The options WithLogger and WithSectorSize apply to ReadTable.
+func ReadTable(hd io.ReadSeeker, opts ...Option) (*Table, error) {
+	o := collectOptions(opts)
+	if err := o.checkBlockSize(); err != nil {
+		return nil, err
+	}
+	return readTable(hd, o.logger, o.blockSize)
+}
+
+// Reads the GPT partition table from hd like ReadTable, logging debug records
+// for each structure read to logger. The returned table uses logger as its
+// Logger.
+func ReadTableWithLogger(hd io.ReadSeeker, logger *slog.Logger) (*Table, error) {
+	return readTable(hd, logger, 0)
+}
+
+// Reads the GPT partition table from hd like ReadTable, for a disk with
+// logical blocks of bs bytes. If bs is 0, the block size is detected.
+func readTable(hd io.ReadSeeker, logger *slog.Logger, bs uint64) (*Table, error) {
+	t := &Table{Logger: logger}
+	log := t.logger()
+	// The block sizes that the backup is looked for with, if the primary
+	// header is invalid.
+	var sizes []uint64
+	if bs == 0 {
+		var err error
+		if bs, err = DetectBlockSize(hd); err != nil {
+			// Without a valid primary header, the block size can
+			// only be found from the backup.
+			bs = DeviceBlockSize(hd)
+			for _, size := range probedBlockSizes {
+				if size != bs {
+					sizes = append(sizes, size)
+				}
+			}
+		}
+	}
+	sizes = append([]uint64{bs}, sizes...)
+	log.Debug("logical block size", "bytes", bs)
+
+	// LBA 0 and 1 are read in one go, since they're adjacent.
+	blocks := make([]byte, 2*bs)
+	if _, err := hd.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(hd, blocks); err != nil {
+		return nil, err
+	}
+	t.MBR = decodeMBR(LogicalBlock(blocks[:LogicalBlockSize]))
+	primary := decodeHeader(blocks[bs:])
+	log.Debug("read MBR", "valid", t.MBR.Valid(), "protective", t.MBR.IsProtective(), "hybrid", t.MBR.IsHybrid())
+	logHeader(log, "primary", primary)
+	err := primary.Verify()
+	if err == nil && !isZero(blocks[bs+LogicalBlockSize:]) {
+		err = errorf(CodeInvalidHeader, "Invalid GPT Header. Header not zero padded.")
+	}
+	if err == nil {
+		// A corrupt entry count or size would otherwise have the entry
+		// array read from past the end of hd before its CRC is checked.
+		var size uint64
+		if size, err = deviceSize(hd); err == nil {
+			err = primary.verifyEntryArray(bs, size)
+		}
+	}
+	if err != nil {
+		log.Debug("primary header invalid", "err", err)
+		for _, size := range sizes {
+			berr := t.readBackupOnly(hd, size)
+			if berr == nil {
+				return t, nil
+			}
+			log.Debug("backup header unusable", "block_size", size, "err", berr)
+		}
+		return nil, err
+	}
+	// Verify doesn't check the checksums, so a corrupt entry array would
+	// otherwise be read as it is, and writing the table would replace the
+	// intact backup with it.
+	if s := checkCRCs(hd, primary, bs); !s.headerOK || !s.arrayOK() {
+		log.Debug("primary checksums incorrect", "header", s.headerOK, "array", s.arrayOK())
+		berr := t.readBackupOnly(hd, bs)
+		if berr == nil {
+			return t, nil
+		}
+		// Neither copy is intact, so the primary is read as it is, and
+		// left for Verify to diagnose.
+		log.Debug("backup header unusable", "err", berr)
+	}
+	t.blockSize = bs
+	entries, err := primary.readPartitions(hd, bs, blocks, nil)
+	if err != nil {
+		return nil, err
+	}
+	// readPartitions leaves the last block of the array in blocks.
+	tail := primary.entryArraySize() % bs
+	if tail != 0 {
+		t.primaryTail = bytes.Clone(blocks[tail:bs])
+	}
+	log.Debug("read partition entries",
+		"lba", primary.PartitionEntryLBA,
+		"blocks", primary.entryArrayBlocks(bs),
+		"entries", len(entries),
+	)
+	for i, e := range entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		log.Debug("partition",
+			"index", i,
+			"type", e.PartitionType.HumanString(),
+			"guid", e.UniqueParitition,
+			"start", e.StartingLBA,
+			"end", e.EndingLBA,
+			"attributes", fmt.Sprintf("%#016x", uint64(e.Attributes)),
+			"name", e.GetName(),
+		)
+	}
+	backup, err := readHeader(hd, primary.AltLBA, bs)
+	if err != nil {
+		return nil, errorf(CodeUnreadableBlock, "Could not read backup header at LBA %d: %w", primary.AltLBA, err)
+	}
+	logHeader(log, "backup", backup)
+	if tail != 0 && backup.SizeOfPartitionEntry == primary.SizeOfPartitionEntry && backup.MaxNumberPartitionEntries == primary.MaxNumberPartitionEntries {
+		if err := readBlocksAt(hd, backup.PartitionEntryLBA+backup.entryArrayBlocks(bs)-1, bs, blocks[:bs]); err == nil {
+			t.backupTail = bytes.Clone(blocks[tail:bs])
+		}
+	}
+	t.Primary, t.Backup, t.Entries = primary, backup, entries
+	return t, nil
+}
+
+// Reads the table from the backup header at the end of hd and the entry
+// array it points to, for a disk with logical blocks of bs bytes whose primary
+// header is invalid. Both the backup header and its entry array must have
+// correct checksums.
+func (t *Table) readBackupOnly(hd io.ReadSeeker, bs uint64) error {
+	log := t.logger()
+	size, err := deviceSize(hd)
+	if err != nil {
+		return err
+	}
+	// The primary's AltLBA can't be trusted, so the backup is assumed to
+	// be at the end of the device.
+	lba := size/bs - 1
+	backup, err := readHeader(hd, lba, bs)
+	if err != nil {
+		return err
+	}
+	logHeader(log, "backup", backup)
+	if string(backup.Signature[:]) != "EFI PART" || backup.MyLBA != lba {
+		return errorf(CodeInvalidHeader, "No backup header at LBA %d", lba)
+	}
+	if s := checkCRCs(hd, backup, bs); !s.headerOK || !s.arrayOK() {
+		return errorf(CodeHeaderCRC, "Backup header at LBA %d has invalid checksums", lba)
+	}
+	entries, err := backup.readPartitions(hd, bs, nil, nil)
+	if err != nil {
+		return err
+	}
+	primary := backup
+	primary.MyLBA, primary.AltLBA = backup.AltLBA, backup.MyLBA
+	primary.PartitionEntryLBA = 2
+	primary.HeaderCRC32 = primary.computeCRC()
+	log.Debug("read table from backup header", "lba", lba, "entries", len(entries))
+	t.Primary, t.Backup, t.Entries, t.FromBackup = primary, backup, entries, true
+	t.blockSize = bs
+	return nil
+}
+
+// Returns a new, empty table for a device of size bytes, with room for
+// entries partition entries and a random disk GUID. The UEFI specification
+// requires room for at least 128 entries, but more (ie. 256, 512 or 1024) can
+// be used for disks with many partitions, at the cost of a smaller usable
+// area. Use WriteNew to write the table to the device.
+//
+// The table is for 512 byte logical blocks unless another size is set with
+// WithSectorSize, such as the DeviceBlockSize of the device.
+func CreateTable(size uint64, entries uint32, opts ...Option) (*Table, error) {
+	o := collectOptions(opts)
+	if err := o.checkBlockSize(); err != nil {
+		return nil, err
+	}
+	if entries < 128 {
+		return nil, errorf(CodeEntryArraySize, "A partition table must have at least 128 partition entries, not %d", entries)
+	}
+	return newTable(size, entries, cmp.Or(o.blockSize, LogicalBlockSize))
+}
+
+// Writes the table to hd along with a protective MBR covering the disk, for a
+// table created by CreateTable (or a disk which doesn't have a GPT yet.)
+func (t *Table) WriteNew(hd io.WriteSeeker) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if rs, ok := hd.(io.ReadSeeker); ok {
+		if bs, ok := deviceBlockSize(rs); ok && bs != t.BlockSize() {
+			return errorf(CodeBlockSize, "Table is for %d byte logical blocks, but the device has %d byte blocks", t.BlockSize(), bs)
+		}
+	}
+	p, err := t.writePlan("create table")
+	if err != nil {
+		return err
+	}
+	mbr := make([]byte, t.BlockSize())
+	protective := protectiveMBR(t.Primary.AltLBA)
+	copy(mbr, protective[:])
+	p.Writes = append([]PlannedWrite{{0, mbr, "protective MBR"}}, p.Writes...)
+	return execute(hd, t.logger(), p, SyncNever)
+}
+
+// Returns an empty table for a device of size bytes with logical blocks of bs
+// bytes, with room for entries partition entries. The partition entry arrays
+// take up the blocks between the headers and the usable area, which is the
+// rest of the device.
+func newTable(size uint64, entries uint32, bs uint64) (*Table, error) {
+	t := &Table{Entries: make([]GPTPartitionEntry, entries), blockSize: bs}
+	h := &t.Primary
+	copy(h.Signature[:], "EFI PART")
+	h.Revision = 0x00010000
+	h.HeaderSize = 92
+	h.MaxNumberPartitionEntries = entries
+	h.SizeOfPartitionEntry = 128
+	arrayBlocks := h.entryArrayBlocks(bs)
+	if size/bs < 2*arrayBlocks+4 {
+		return nil, errorf(CodeDeviceTooSmall, "Device is too small for a partition table (%d blocks)", size/bs)
+	}
+	disk, err := NewGUID()
+	if err != nil {
+		return nil, err
+	}
+	h.Disk = disk
+	h.MyLBA = 1
+	h.AltLBA = size/bs - 1
+	h.PartitionEntryLBA = 2
+	h.FirstUseableLBA = 2 + arrayBlocks
+	h.LastUseableLBA = h.AltLBA - arrayBlocks - 1
+	t.syncBackup()
+	return t, nil
+}
+
+// Logs the fields of the header h, which is the which header of the table.
+func logHeader(log *slog.Logger, which string, h GPTHeader) {
+	log.Debug("read header",
+		"which", which,
+		"signature", string(h.Signature[:]),
+		"revision", fmt.Sprintf("%#08x", h.Revision),
+		"size", h.HeaderSize,
+		"crc", fmt.Sprintf("%#08x", h.HeaderCRC32),
+		"my_lba", h.MyLBA,
+		"alt_lba", h.AltLBA,
+		"first_usable", h.FirstUseableLBA,
+		"last_usable", h.LastUseableLBA,
+		"entry_lba", h.PartitionEntryLBA,
+		"entries", h.MaxNumberPartitionEntries,
+		"entry_size", h.SizeOfPartitionEntry,
+		"array_crc", fmt.Sprintf("%#08x", h.PartitionEntryArrayCRC32),
+	)
+}
+
+// Returns true if the table was read from a disk opened with Open, and can
+// not be modified.
+func (t *Table) ReadOnly() bool {
+	return t.readOnly
+}
+
+// Returns the size in bytes of the logical blocks of the disk that the table
+// is for, which all of its LBAs are in. This is the block size that the table
+// was read with, or that it was created for.
+func (t *Table) BlockSize() uint64 {
+	if t.blockSize == 0 {
+		return LogicalBlockSize
+	}
+	return t.blockSize
+}
+
+// Returns ErrReadOnly if the table can not be modified.
+func (t *Table) checkWritable() error {
+	if t.readOnly && t.Force < ForceReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// Reads the GPT header at the logical block lba of hd, which has logical
+// blocks of bs bytes. The rest of the block after the header must be zero.
+func readHeader(hd io.ReadSeeker, lba, bs uint64) (GPTHeader, error) {
+	block := make([]byte, bs)
+	if err := readBlocksAt(hd, lba, bs, block); err != nil {
+		return GPTHeader{}, err
+	}
+	if !isZero(block[LogicalBlockSize:]) {
+		return GPTHeader{}, errorf(CodeInvalidHeader, "Invalid GPT Header at LBA %d. Header not zero padded.", lba)
+	}
+	return decodeHeader(block), nil
+}
+
+// When Write flushes the table to stable storage.
+type SyncMode int
+
+const (
+	// Never sync, leaving it to the caller or the operating system. This
+	// is the fastest, and is suitable for image files which will be
+	// synced or copied later.
+	SyncNever SyncMode = iota
+
+	// Sync once, after the whole table has been written.
+	SyncAtEnd
+
+	// Sync after writing each structure, so that the backup is on stable
+	// storage before the primary is overwritten.
+	SyncEach
+)
+
+// WriteOptions control how WriteWithOptions writes a table.
+type WriteOptions struct {
+	// When the device is flushed to stable storage. Devices without a
+	// Sync method are never flushed.
+	Sync SyncMode
+
+	// If set, the primary header's signature is cleared before the
+	// primary entry array is written, and only restored by writing the
+	// primary header last. If the write is interrupted, the primary header
+	// is then obviously invalid, and ReadTable falls back to the backup,
+	// rather than the primary header describing a partially written
+	// entry array. This is best combined with SyncEach.
+	InvalidatePrimary bool
+}
+
+// Writes the table to hd. The checksums of both headers are updated
+// before writing, and the backup header is kept in sync with the primary.
+// Any hooks registered with RegisterHooks are called around the write, and
+// if hd is a block device it's locked with LockDevice while it's written.
+//
+// The backup entry array and header are written before the primary entry
+// array and header, as the UEFI specification recommends, so that if the
+// write is interrupted at least one of the copies is valid.
+//
+// hd isn't synced unless opts ask for it, such as with WithSync.
+func (t *Table) Write(hd io.WriteSeeker, opts ...Option) error {
+	o := collectOptions(opts)
+	if err := o.checkBlockSize(); err != nil {
+		return err
+	}
+	if o.blockSize != 0 && o.blockSize != t.BlockSize() {
+		return errorf(CodeBlockSize, "Table is for %d byte logical blocks, not %d", t.BlockSize(), o.blockSize)
+	}
+	return t.WriteWithOptions(hd, o.write)
+}
+
+// Writes the table to hd like Write, flushing it to stable storage as opts
+// requires. The After hooks are only called once the flush is done.
+func (t *Table) WriteWithOptions(hd io.WriteSeeker, opts WriteOptions) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	p, err := t.writePlan("write table")
+	if err != nil {
+		return err
+	}
+	if opts.InvalidatePrimary {
+		invalid := t.Primary
+		invalid.Signature = [8]byte{}
+		// Before the primary entry array, which follows the backup.
+		p.Writes = slices.Insert(p.Writes, 2, PlannedWrite{invalid.MyLBA, invalid.encodeBlock(t.BlockSize()), "invalidated primary header"})
+	}
+	return execute(hd, t.logger(), p, opts.Sync)
+}
+
+// Syncs the backup header and updates the checksums of the table, and
+// returns the plan for the operation op which writes it. The backup is
+// written first and the primary header last, which every write of a whole
+// table relies on.
+func (t *Table) writePlan(op string) (*Plan, error) {
+	t.syncBackup()
+	entries, err := t.encodeEntries()
+	if err != nil {
+		return nil, err
+	}
+	t.updateChecksums(entries)
+	t.logger().Debug("computed checksums",
+		"array_crc", fmt.Sprintf("%#08x", t.Primary.PartitionEntryArrayCRC32),
+		"primary_crc", fmt.Sprintf("%#08x", t.Primary.HeaderCRC32),
+		"backup_crc", fmt.Sprintf("%#08x", t.Backup.HeaderCRC32),
+	)
+	return &Plan{
+		Operation: op,
+		Table:     t,
+		BlockSize: t.BlockSize(),
+		Writes: []PlannedWrite{
+			{t.Backup.PartitionEntryLBA, withTail(entries, t.Primary.entryArraySize(), t.backupTail), "backup partition entry array"},
+			{t.Backup.MyLBA, t.Backup.encodeBlock(t.BlockSize()), "backup header"},
+			{t.Primary.PartitionEntryLBA, withTail(entries, t.Primary.entryArraySize(), t.primaryTail), "primary partition entry array"},
+			{t.Primary.MyLBA, t.Primary.encodeBlock(t.BlockSize()), "primary header"},
+		},
+	}, nil
+}
+
+// Returns the encoded entry array entries, which is size bytes long before
+// its padding, with the padding replaced by tail. entries itself is returned
+// if tail isn't the same length as the padding.
+func withTail(entries []byte, size uint64, tail []byte) []byte {
+	if len(tail) == 0 || uint64(len(entries))-size != uint64(len(tail)) {
+		return entries
+	}
+	buf := bytes.Clone(entries)
+	copy(buf[len(buf)-len(tail):], tail)
+	return buf
+}
+
+// Copies the fields which must be identical between the two headers from
+// the primary to the backup header. The backup's location fields are derived
+// from the primary's. If the backup header is still at the primary's AltLBA
+// and its entry array still fits between the usable area and the header,
+// the entry array and the header's padding are left as they are, otherwise
+// the entry array is placed immediately before the backup header.
+func (t *Table) syncBackup() {
+	arrayBlocks := t.Primary.entryArrayBlocks(t.BlockSize())
+	entryLBA := t.Primary.AltLBA - arrayBlocks
+	padding := t.Primary.Padding
+	if b := t.Backup; b.MyLBA == t.Primary.AltLBA && b.PartitionEntryLBA > t.Primary.LastUseableLBA && b.PartitionEntryLBA+arrayBlocks <= t.Primary.AltLBA {
+		entryLBA = b.PartitionEntryLBA
+		padding = b.Padding
+	}
+	t.Backup = t.Primary
+	t.Backup.Padding = padding
+	t.Backup.MyLBA = t.Primary.AltLBA
+	t.Backup.AltLBA = t.Primary.MyLBA
+	t.Backup.PartitionEntryLBA = entryLBA
+}
+
+// Recomputes the CRC of the entry array from Entries, then the CRCs of both
+// headers, for a table whose fields have been modified directly. Unlike
+// Write, the backup header isn't synced with the primary first, so any
+// changes made to it directly are kept. It's an error if Entries doesn't
+// match the primary header.
+func (t *Table) UpdateChecksums() error {
+	entries, err := t.encodeEntries()
+	if err != nil {
+		return err
+	}
+	t.updateChecksums(entries)
+	return nil
+}
+
+// Recomputes the entry array CRC and header CRCs of both headers, given the
+// encoded entry array.
+func (t *Table) updateChecksums(entries []byte) {
+	arrayCRC := crc32.ChecksumIEEE(entries[:t.Primary.entryArraySize()])
+	t.Primary.PartitionEntryArrayCRC32 = arrayCRC
+	t.Backup.PartitionEntryArrayCRC32 = arrayCRC
+	t.Primary.HeaderCRC32 = t.Primary.computeCRC()
+	t.Backup.HeaderCRC32 = t.Backup.computeCRC()
+}
+
+// Encodes the partition entry array in its on disk format, padded to a whole
+// number of logical blocks with zeros.
+func (t *Table) encodeEntries() ([]byte, error) {
+	if uint32(len(t.Entries)) != t.Primary.MaxNumberPartitionEntries {
+		return nil, errorf(CodeEntryArraySize, "Table has %d entries, header requires %d.", len(t.Entries), t.Primary.MaxNumberPartitionEntries)
+	}
+	if t.Primary.SizeOfPartitionEntry < 128 {
+		return nil, errorf(CodeInvalidHeader, "Invalid partition entry size %d.", t.Primary.SizeOfPartitionEntry)
+	}
+	buf := make([]byte, t.Primary.entryArrayBlocks(t.BlockSize())*t.BlockSize())
+	for i, e := range t.Entries {
+		encodeEntry(buf[uint32(i)*t.Primary.SizeOfPartitionEntry:], e)
+	}
+	return buf, nil
+}
+
+// Adds a partition of type typ which is at least size bytes long to the first
+// free region of the table which can hold it, starting at a multiple of align
+// logical blocks. The new partition is given a random unique GUID.
+//
+// Returns the index of the new entry in t.Entries.
+func (t *Table) AddPartition(typ GUID, size, align uint64) (int, error) {
+	return t.addPartition(typ, size, align, 0)
+}
+
+// Adds a partition like AddPartition, leaving at least gap free logical
+// blocks between it and the partitions before and after it.
+func (t *Table) addPartition(typ GUID, size, align, gap uint64) (int, error) {
+	if err := t.checkWritable(); err != nil {
+		return -1, err
+	}
+	if typ.IsZero() {
+		return -1, errorf(CodeUnknownType, "Can not add a partition with the unused partition type.")
+	}
+	blocks := BytesToLBAs(size, t.BlockSize())
+	if blocks == 0 {
+		return -1, errorf(CodeInvalidRange, "Can not add an empty partition.")
+	}
+	idx := -1
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return -1, errorf(CodeNoFreeEntries, "No unused partition entries.")
+	}
+	start, ok := t.findFree(blocks, align, gap)
+	if !ok {
+		return -1, errorf(CodeNoFreeSpace, "No free space for a partition of %d blocks.", blocks)
+	}
+	guid, err := NewGUID()
+	if err != nil {
+		return -1, err
+	}
+	t.Entries[idx] = GPTPartitionEntry{
+		PartitionType:    typ,
+		UniqueParitition: guid,
+		StartingLBA:      start,
+		EndingLBA:        start + blocks - 1,
+	}
+	return idx, nil
+}
+
+// Changes the number of entries in the partition entry array to entries,
+// which must be at least 128. The backup entry array is moved to immediately
+// precede the backup header.
+//
+// Growing the arrays moves the first and last usable LBAs to make room for
+// them, and it's an error if a partition is in the way. Shrinking them is
+// only possible if the entries being removed are unused, and the space they
+// took up is added to the usable area, so the first usable LBA immediately
+// follows the primary entry array and the last usable LBA immediately
+// precedes the backup entry array.
+func (t *Table) ResizeEntryArray(entries uint32) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if entries < 128 {
+		return errorf(CodeEntryArraySize, "A partition table must have at least 128 partition entries, not %d", entries)
+	}
+	shrink := entries < t.Primary.MaxNumberPartitionEntries
+	if shrink {
+		for i, e := range t.Entries[entries:] {
+			if !e.PartitionType.IsZero() {
+				return errorf(CodeNoFreeEntries, "Partition entry %d is in use", int(entries)+i)
+			}
+		}
+	}
+	h := t.Primary
+	h.MaxNumberPartitionEntries = entries
+	blocks := h.entryArrayBlocks(t.BlockSize())
+	backupLBA := h.AltLBA - blocks
+	if shrink || h.PartitionEntryLBA+blocks > h.FirstUseableLBA {
+		h.FirstUseableLBA = h.PartitionEntryLBA + blocks
+	}
+	if shrink || backupLBA <= h.LastUseableLBA {
+		h.LastUseableLBA = backupLBA - 1
+	}
+	if h.FirstUseableLBA > h.LastUseableLBA {
+		return errorf(CodeDeviceTooSmall, "Device is too small for %d partition entries", entries)
+	}
+	for i, e := range t.Entries {
+		if !e.PartitionType.IsZero() && (e.StartingLBA < h.FirstUseableLBA || e.EndingLBA > h.LastUseableLBA) {
+			return errorf(CodeNoFreeSpace, "Partition %d (LBA %d-%d) is in the way of a partition entry array of %d entries (usable LBA %d-%d)", i, e.StartingLBA, e.EndingLBA, entries, h.FirstUseableLBA, h.LastUseableLBA)
+		}
+	}
+	t.Primary = h
+	t.Backup.PartitionEntryLBA = backupLBA
+	t.primaryTail, t.backupTail = nil, nil
+	if shrink {
+		t.Entries = t.Entries[:entries]
+	} else {
+		t.Entries = append(t.Entries, make([]GPTPartitionEntry, int(entries)-len(t.Entries))...)
+	}
+	return nil
+}
+
+// Deletes the partition at index of the table by clearing its entry. The
+// partition's contents are left on the disk.
+func (t *Table) DeletePartition(index int) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.Entries) || t.Entries[index].PartitionType.IsZero() {
+		return entryErrorf(index, CodeNotInUse, "Partition %d is not in use", index)
+	}
+	t.Entries[index] = GPTPartitionEntry{}
+	return nil
+}
+
+// Finds the first LBA that is a multiple of align which is followed by
+// at least blocks unallocated logical blocks, and is at least gap blocks
+// away from any partition.
+func (t *Table) findFree(blocks, align, gap uint64) (uint64, bool) {
+	if align == 0 {
+		align = 1
+	}
+	var used []GPTPartitionEntry
+	for _, e := range t.Entries {
+		if !e.PartitionType.IsZero() {
+			used = append(used, e)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].StartingLBA < used[j].StartingLBA })
+
+	start := alignUp(t.Primary.FirstUseableLBA, align)
+	for _, u := range used {
+		if u.EndingLBA+gap < start {
+			continue
+		}
+		if start+blocks-1+gap < u.StartingLBA {
+			return start, true
+		}
+		start = alignUp(u.EndingLBA+1+gap, align)
+	}
+	if start+blocks-1 <= t.Primary.LastUseableLBA {
+		return start, true
+	}
+	return 0, false
+}
+
+// Rounds lba up to the next multiple of align.
+func alignUp(lba, align uint64) uint64 {
+	if r := lba % align; r != 0 {
+		return lba + align - r
+	}
+	return lba
+}
+
+// Returns the first LBA at or after lba which is a multiple of align logical
+// blocks. An align of 0 or 1 returns lba.
+func NextAligned(lba, align uint64) uint64 {
+	if align <= 1 {
+		return lba
+	}
+	return alignUp(lba, align)
+}
+
+// Writes data to hd starting at the logical block lba, for logical blocks of
+// bs bytes.
+func writeBlocks(hd io.WriteSeeker, lba, bs uint64, data []byte) error {
+	if _, err := hd.Seek(int64(lba*bs), io.SeekStart); err != nil {
+		return err
+	}
+	_, err := hd.Write(data)
+	return err
+}
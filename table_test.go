@@ -0,0 +1,148 @@
+package gpt_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+)
+
+func TestReadTableSectorSizes(t *testing.T) {
+	for _, ss := range []uint64{512, 2048, 4096} {
+		d := gpttest.Disk{
+			SectorSize: ss,
+			Sectors:    (64 << 20) / ss,
+			Partitions: []gpttest.Partition{{Type: gpt.EFISystemPartition, Start: 256, End: 511}},
+		}
+		img, err := d.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := bytes.Clone(img.Bytes())
+		table, err := gpt.ReadTable(img)
+		if err != nil {
+			t.Fatalf("%d byte sectors: %v", ss, err)
+		}
+		if table.BlockSize() != ss {
+			t.Errorf("%d byte sectors: BlockSize() = %d", ss, table.BlockSize())
+		}
+		if e := table.Entries[0]; e.StartingLBA != 256 || e.EndingLBA != 511 {
+			t.Errorf("%d byte sectors: partition at LBA %d-%d, want 256-511", ss, e.StartingLBA, e.EndingLBA)
+		}
+		if err := table.Write(img); err != nil {
+			t.Fatalf("%d byte sectors: %v", ss, err)
+		}
+		if !bytes.Equal(img.Bytes(), orig) {
+			t.Errorf("%d byte sectors: writing the table changed the image", ss)
+		}
+	}
+}
+
+func TestSectorSizeOption(t *testing.T) {
+	table, err := gpt.CreateTable(64<<20, 128, gpt.WithSectorSize(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.BlockSize() != 4096 || table.Primary.AltLBA != (64<<20)/4096-1 {
+		t.Fatalf("BlockSize() = %d, AltLBA = %d", table.BlockSize(), table.Primary.AltLBA)
+	}
+	img := gpttest.NewImage(make([]byte, 64<<20))
+	if err := table.WriteNew(img); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gpt.ReadTable(img, gpt.WithSectorSize(512)); err == nil {
+		t.Error("read a 4096 byte block table with 512 byte blocks")
+	}
+	read, err := gpt.ReadTable(img, gpt.WithSectorSize(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Equal(table) {
+		t.Error("read table isn't the table that was written")
+	}
+	if err := gpt.Verify(img, gpt.WithSectorSize(4096)).Err(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	// The header is one 512 byte LogicalBlock followed by zeros, so it can
+	// be read with encoding/binary.
+	var h gpt.GPTHeader
+	if err := binary.Read(bytes.NewReader(img.Bytes()[4096:]), binary.LittleEndian, &h); err != nil {
+		t.Fatal(err)
+	}
+	if h != read.Primary {
+		t.Errorf("binary.Read read %v, want %v", h, read.Primary)
+	}
+	if !bytes.Equal(img.Bytes()[4096+512:2*4096], make([]byte, 4096-512)) {
+		t.Error("primary header block isn't zero padded")
+	}
+	if err := read.Write(img, gpt.WithSectorSize(512)); gpt.CodeOf(err) != gpt.CodeBlockSize {
+		t.Errorf("Write with the wrong block size: %v", err)
+	}
+	if _, err := gpt.CreateTable(64<<20, 128, gpt.WithSectorSize(1000)); gpt.CodeOf(err) != gpt.CodeBlockSize {
+		t.Errorf("CreateTable with an invalid block size: %v", err)
+	}
+}
+
+func TestReadTableFallsBackOnBadCRC(t *testing.T) {
+	for _, off := range []int{
+		512 + 16,  // the primary header's CRC
+		1024 + 32, // the first entry's starting LBA, so the array CRC is wrong
+	} {
+		d := gpttest.Disk{
+			Partitions: []gpttest.Partition{{Type: gpt.EFISystemPartition, Start: 2048, End: 4095}},
+			Corruptions: []gpttest.Corruption{func(img []byte, d gpttest.Disk) []byte {
+				img[off] ^= 0xff
+				return img
+			}},
+		}
+		img, err := d.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := gpt.ReadTable(img)
+		if err != nil {
+			t.Fatalf("corrupt byte %d: %v", off, err)
+		}
+		if !table.FromBackup || table.Entries[0].StartingLBA != 2048 {
+			t.Errorf("corrupt byte %d: FromBackup = %v, partition starts at %d", off, table.FromBackup, table.Entries[0].StartingLBA)
+		}
+	}
+}
+
+func TestReadTableBoundsEntryArray(t *testing.T) {
+	for _, test := range []struct {
+		entries, size uint32
+	}{
+		{0xFFFFFFFF, 0x80000000}, // entries larger than a block
+		{0xFFFFFFFF, 128},        // an array past the end of the disk
+		{128, 192},               // entries which aren't a power of two
+	} {
+		d := gpttest.Disk{
+			Partitions: []gpttest.Partition{{Type: gpt.EFISystemPartition, Start: 2048, End: 4095}},
+			Corruptions: []gpttest.Corruption{func(img []byte, d gpttest.Disk) []byte {
+				// The header CRC is correct, so only the entry
+				// array's bounds make it invalid.
+				h := d.PrimaryHeader()
+				h.MaxNumberPartitionEntries, h.SizeOfPartitionEntry = test.entries, test.size
+				copy(img[512:1024], gpttest.EncodeHeader(h))
+				return img
+			}},
+		}
+		img, err := d.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := gpt.ReadTable(img)
+		if err != nil {
+			t.Fatalf("%d entries of %d bytes: %v", test.entries, test.size, err)
+		}
+		if !table.FromBackup || table.Entries[0].StartingLBA != 2048 {
+			t.Errorf("%d entries of %d bytes: FromBackup = %v, partition starts at %d", test.entries, test.size, table.FromBackup, table.Entries[0].StartingLBA)
+		}
+		if err := gpt.Verify(img).Err(); gpt.CodeOf(err) != gpt.CodeInvalidHeader {
+			t.Errorf("%d entries of %d bytes: Verify = %v", test.entries, test.size, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package gpt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// The number of 100 nanosecond intervals between the start of the Gregorian
+// calendar (October 15, 1582), which version 1 GUID timestamps count from,
+// and the Unix epoch.
+const gregorianOffset = 0x01B21DD213814000
+
+// A V1Generator generates time based (version 1) GUIDs, as described by RFC
+// 4122, which record when and where (by node ID) they were generated. Its
+// node ID and clock can be set for environments which need traceable
+// identifiers, or reproducible ones in tests. The zero value uses a random
+// node ID and the system clock, and is safe for use by multiple goroutines.
+//
+// The GUIDs generated by a V1Generator are unique and their timestamps always
+// increase, even if the clock doesn't advance between calls or is set back.
+type V1Generator struct {
+	// The node ID, usually the MAC address of a network interface. If it's
+	// zero, a random node ID with the multicast bit set is used, as RFC
+	// 4122 requires for node IDs which aren't MAC addresses.
+	Node [6]byte
+
+	// Returns the current time. If nil, time.Now is used.
+	Clock func() time.Time
+
+	mu         sync.Mutex
+	seeded     bool
+	randomNode [6]byte
+	clockSeq   uint16
+	last       uint64
+}
+
+// The generator used by NewV1GUID.
+var defaultV1Generator V1Generator
+
+// Generates a new time based (version 1) GUID with a random node ID, which is
+// the same for every GUID generated by the program. Use a V1Generator to set
+// the node ID.
+func NewV1GUID() (GUID, error) {
+	return defaultV1Generator.New()
+}
+
+// Generates a new time based (version 1) GUID.
+func (v *V1Generator) New() (GUID, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.seeded {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return ZeroGUID, err
+		}
+		copy(v.randomNode[:], b[:6])
+		v.randomNode[0] |= 0x01
+		v.clockSeq = binary.BigEndian.Uint16(b[6:]) & 0x3FFF
+		v.seeded = true
+	}
+	node := v.Node
+	if node == ([6]byte{}) {
+		node = v.randomNode
+	}
+	now := time.Now
+	if v.Clock != nil {
+		now = v.Clock
+	}
+	ts := uint64(now().UnixNano()/100) + gregorianOffset
+	if ts <= v.last {
+		ts = v.last + 1
+	}
+	v.last = ts
+	return GUID{
+		TimeLow:             uint32(ts),
+		TimeMid:             uint16(ts >> 32),
+		TimeHighAndVersion:  uint16(ts>>48)&0x0FFF | 0x1000,
+		ClockSeqAndReserved: uint8(v.clockSeq>>8)&0x3F | 0x80,
+		ClockSeqLow:         uint8(v.clockSeq),
+		Node:                node,
+	}, nil
+}
+
+// Returns the version of the GUID, such as 4 for a random GUID or 1 for a
+// time based GUID.
+func (g GUID) Version() int {
+	return int(g.TimeHighAndVersion >> 12)
+}
+
+// Returns the time that a time based (version 1) GUID was generated. The
+// result is false for GUIDs of other versions.
+func (g GUID) Time() (time.Time, bool) {
+	if g.Version() != 1 {
+		return time.Time{}, false
+	}
+	ts := int64(g.TimeHighAndVersion&0x0FFF)<<48 | int64(g.TimeMid)<<32 | int64(g.TimeLow)
+	ts -= gregorianOffset
+	return time.Unix(ts/1e7, ts%1e7*100), true
+}
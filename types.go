@@ -0,0 +1,263 @@
+package gpt
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The operating system (or other software) that a partition type is used by.
+type OSFamily string
+
+// Operating system families.
+const (
+	OSUnknown   OSFamily = ""
+	OSUEFI      OSFamily = "uefi"
+	OSLinux     OSFamily = "linux"
+	OSChromeOS  OSFamily = "chromeos"
+	OSWindows   OSFamily = "windows"
+	OSMacOS     OSFamily = "macos"
+	OSFreeBSD   OSFamily = "freebsd"
+	OSNetBSD    OSFamily = "netbsd"
+	OSOpenBSD   OSFamily = "openbsd"
+	OSDragonFly OSFamily = "dragonfly"
+	OSSolaris   OSFamily = "solaris"
+	OSVMware    OSFamily = "vmware"
+	OSHaiku     OSFamily = "haiku"
+	OSPlan9     OSFamily = "plan9"
+)
+
+// The documentation of the partition types used by each OSFamily.
+var osReferences = map[OSFamily]string{
+	OSUEFI:     "https://uefi.org/specifications",
+	OSLinux:    "https://uapi-group.org/specifications/specs/discoverable_partitions_specification/",
+	OSChromeOS: "https://www.chromium.org/chromium-os/chromiumos-design-docs/disk-format/",
+	OSWindows:  "https://learn.microsoft.com/en-us/windows/win32/api/vds/ns-vds-create_partition_parameters",
+	OSMacOS:    "https://developer.apple.com/library/archive/technotes/tn2166/_index.html",
+}
+
+// The documentation of partition types used by families without their own
+// entry in osReferences.
+const defaultTypeReference = "https://en.wikipedia.org/wiki/GUID_Partition_Table#Partition_type_GUIDs"
+
+// What a partition type is used for.
+type TypeCategory string
+
+// Partition type categories.
+const (
+	CategoryUnknown TypeCategory = ""
+
+	// Partitions used to boot, such as the EFI System Partition or a
+	// kernel partition.
+	CategoryBoot TypeCategory = "boot"
+
+	// Operating system partitions, such as root or /usr.
+	CategorySystem TypeCategory = "system"
+
+	// Filesystems for data.
+	CategoryData TypeCategory = "data"
+
+	CategorySwap TypeCategory = "swap"
+
+	// Members of a software RAID or concatenated set.
+	CategoryRAID TypeCategory = "raid"
+
+	// Physical volumes of a volume manager, such as LVM or Storage
+	// Spaces.
+	CategoryVolumeManager TypeCategory = "volume-manager"
+
+	// Encrypted volumes.
+	CategoryEncrypted TypeCategory = "encrypted"
+
+	// dm-verity hashes of another partition.
+	CategoryVerity TypeCategory = "verity"
+
+	// Recovery environments.
+	CategoryRecovery TypeCategory = "recovery"
+
+	// Space reserved for use by an operating system, which has no
+	// filesystem.
+	CategoryReserved TypeCategory = "reserved"
+
+	CategoryFirmware TypeCategory = "firmware"
+
+	// Metadata for other partitions, such as journals or volume manager
+	// databases.
+	CategoryMetadata TypeCategory = "metadata"
+
+	// Partitions which contain their own partitioning, such as a BSD
+	// disklabel.
+	CategoryContainer TypeCategory = "container"
+)
+
+// Information about a known partition type.
+type TypeInfo struct {
+	GUID GUID
+
+	// A human readable name for the type.
+	Name string
+
+	// The short hand code used by gdisk, or 0 if there is none.
+	GdiskCode uint16
+
+	OS       OSFamily
+	Category TypeCategory
+
+	// The URL of documentation for the type. May be empty.
+	URL string
+}
+
+// The known partition types, keyed by their type GUID.
+var partitionTypes = map[GUID]TypeInfo{}
+
+// The aliases of partition types registered with RegisterType, keyed by the
+// lower case alias.
+var typeAliases = map[string]GUID{}
+
+// Registers a known partition type, and returns the GUID parsed from s.
+func registerType(s, name string, gdisk uint16, os OSFamily, category TypeCategory) GUID {
+	g, err := ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	url, ok := osReferences[os]
+	if !ok {
+		url = defaultTypeReference
+	}
+	partitionTypes[g] = TypeInfo{g, name, gdisk, os, category, url}
+	return g
+}
+
+// Well known partition types.
+var (
+	EFISystemPartition = registerType("C12A7328-F81F-11D2-BA4B-00A0C93EC93B", "EFI System Partition", 0xEF00, OSUEFI, CategoryBoot)
+	LinuxFilesystem    = registerType("0FC63DAF-8483-4772-8E79-3D69D8477DE4", "Linux", 0x8300, OSLinux, CategoryData)
+	LinuxSwap          = registerType("0657FD6D-A4AB-43C4-84E5-0933C84B4F4F", "Linux Swap", 0x8200, OSLinux, CategorySwap)
+	Plan9              = registerType("C91818F9-8025-47AF-89D2-F030D7000C2C", "Plan 9", 0x3900, OSPlan9, CategoryData)
+)
+
+// Registers a partition type which isn't known to this package, such as a
+// vendor specific type, so that it's shown with a human readable name and can
+// be parsed by ParseType from any of its aliases. Aliases are case
+// insensitive.
+//
+// RegisterType is intended to be called from an init function, and is not
+// safe to call concurrently with other functions in this package.
+func RegisterType(g GUID, name string, aliases ...string) error {
+	return RegisterTypeInfo(TypeInfo{GUID: g, Name: name}, aliases...)
+}
+
+// Registers a partition type like RegisterType, with all of the information
+// in info.
+func RegisterTypeInfo(info TypeInfo, aliases ...string) error {
+	g := info.GUID
+	if g.IsZero() {
+		return errorf(CodeUnknownType, "Can not register the unused partition type")
+	}
+	if t, ok := partitionTypes[g]; ok {
+		return errorf(CodeInvalidArgument, "Partition type %v is already registered as \"%v\"", g, t.Name)
+	}
+	for _, a := range aliases {
+		if other, err := ParseType(a); err == nil {
+			return errorf(CodeInvalidArgument, "Alias \"%v\" is already used by partition type %v", a, other)
+		}
+	}
+	partitionTypes[g] = info
+	for _, a := range aliases {
+		typeAliases[strings.ToLower(a)] = g
+	}
+	return nil
+}
+
+// The single letter short hands for partition types accepted by sfdisk.
+var sfdiskTypes = map[string]GUID{
+	"L": LinuxFilesystem,
+	"S": LinuxSwap,
+	"H": DPSHome,
+	"U": EFISystemPartition,
+	"R": LinuxRAID,
+	"V": LinuxLVM,
+}
+
+// Returns the partition type with the short hand code used by gdisk (ie.
+// 0x8300 for a Linux filesystem.)
+func TypeForGdiskCode(code uint16) (GUID, bool) {
+	if code == 0 {
+		return ZeroGUID, false
+	}
+	for g, t := range partitionTypes {
+		if t.GdiskCode == code {
+			return g, true
+		}
+	}
+	return ZeroGUID, false
+}
+
+// Parses a partition type, which may be an alias registered with
+// RegisterType, one of sfdisk's single letter short hands (ie. "L" or "U"),
+// a four digit gdisk hex code (ie. "8300" or "EF00"), a systemd-repart type
+// identifier (ie. "esp" or "root-x86-64"), the human readable name of a
+// known type (ie. "Linux LVM"), or a GUID. Aliases, hex codes and names are
+// case insensitive.
+func ParseType(s string) (GUID, error) {
+	if g, ok := typeAliases[strings.ToLower(s)]; ok {
+		return g, nil
+	}
+	if g, ok := sfdiskTypes[s]; ok {
+		return g, nil
+	}
+	if len(s) == 4 {
+		if code, err := strconv.ParseUint(s, 16, 16); err == nil {
+			if g, ok := TypeForGdiskCode(uint16(code)); ok {
+				return g, nil
+			}
+		}
+	}
+	if g, err := repartType(s); err == nil {
+		return g, nil
+	}
+	for g, t := range partitionTypes {
+		if strings.EqualFold(t.Name, s) {
+			return g, nil
+		}
+	}
+	return ZeroGUID, errorf(CodeUnknownType, "Unknown partition type \"%v\"", s)
+}
+
+// Returns the information about the partition type g, if it's known.
+func LookupType(g GUID) (TypeInfo, bool) {
+	t, ok := partitionTypes[g]
+	return t, ok
+}
+
+// Returns all of the known partition types, sorted by name.
+func Types() []TypeInfo {
+	types := make([]TypeInfo, 0, len(partitionTypes))
+	for _, t := range partitionTypes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// Returns the known partition types used by os, sorted by name.
+func TypesForOS(os OSFamily) []TypeInfo {
+	var types []TypeInfo
+	for _, t := range Types() {
+		if t.OS == os {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Returns the known partition types in category, sorted by name.
+func TypesInCategory(category TypeCategory) []TypeInfo {
+	var types []TypeInfo
+	for _, t := range Types() {
+		if t.Category == category {
+			types = append(types, t)
+		}
+	}
+	return types
+}
@@ -0,0 +1,46 @@
+package gpt_test
+
+import (
+	"testing"
+
+	"github.com/driusan/gpt"
+)
+
+func TestGdiskCodes(t *testing.T) {
+	// A sample of gdisk's parttypes.cc.
+	tests := []struct {
+		code string
+		typ  gpt.GUID
+	}{
+		{"EF00", gpt.EFISystemPartition},
+		{"8300", gpt.LinuxFilesystem},
+		{"F800", gpt.CephOSD},
+		{"F801", gpt.CephDMCryptOSD},
+		{"F802", gpt.CephJournal},
+		{"F806", gpt.CephBlock},
+		{"F809", gpt.CephLockbox},
+		{"F810", gpt.CephDMCryptBlock},
+		{"F817", gpt.CephLUKSOSD},
+	}
+	for _, test := range tests {
+		g, err := gpt.ParseType(test.code)
+		if err != nil || g != test.typ {
+			t.Errorf("ParseType(%q) = %v, %v, want %v", test.code, g.HumanString(), err, test.typ.HumanString())
+		}
+	}
+	if _, err := gpt.ParseType("F818"); err == nil {
+		t.Error("ParseType(\"F818\") found a type past the last Ceph type")
+	}
+
+	// TypeForGdiskCode would be ambiguous if two types shared a code.
+	seen := map[uint16]string{}
+	for _, info := range gpt.Types() {
+		if info.GdiskCode == 0 {
+			continue
+		}
+		if other, ok := seen[info.GdiskCode]; ok {
+			t.Errorf("%q and %q both have gdisk code %04X", other, info.Name, info.GdiskCode)
+		}
+		seen[info.GdiskCode] = info.Name
+	}
+}
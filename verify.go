@@ -0,0 +1,324 @@
+package gpt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A VerifyLevel controls how thoroughly Verify checks a disk. Each level
+// includes the checks of the levels before it.
+type VerifyLevel int
+
+const (
+	// Check the fields of the primary header.
+	VerifyHeader VerifyLevel = iota
+
+	// Also check the CRCs of the primary header and partition entry
+	// array.
+	VerifyChecksums
+
+	// Also check the backup header and entry array, their CRCs, that they
+	// agree with the primary, and that they're in the right place.
+	VerifyBackup
+
+	// Also check that the partitions are inside the usable area of the
+	// disk, don't overlap, and have unique GUIDs.
+	VerifyPartitions
+
+	// Also check that the first and last block of every partition can be
+	// read.
+	VerifyContents
+
+	// Also read every block of every partition (and optionally the free
+	// space), reporting the blocks which can't be read. This reads the
+	// whole disk, so is very slow.
+	VerifyDeep
+)
+
+// VerifyOptions control what Verify checks.
+type VerifyOptions struct {
+	// How thoroughly to check the disk.
+	Level VerifyLevel
+
+	// If set, the partition names are also checked with VerifyNames at
+	// VerifyPartitions and above.
+	Names bool
+
+	// If set, called with the progress of reading partition contents at
+	// VerifyContents and above.
+	Progress ProgressFunc
+
+	// If set, the unallocated blocks of the usable area are also read at
+	// VerifyDeep.
+	FreeSpace bool
+}
+
+// The alignment, in bytes, that partitions are expected to start at.
+// Partitioning tools have aligned partitions to 1MiB by default since disks
+// with 4096 byte physical sectors became common.
+const verifyAlignment = 1 << 20
+
+// Verifies the GPT on hd, returning a report of every problem found. Cheap
+// checks, such as VerifyHeader, are suitable for use before every operation
+// on a disk while VerifyContents is intended for fsck-like audits.
+//
+// Verification only stops early if the primary header or partition entries
+// can't be read, since none of the other checks can be done without them.
+//
+// What's checked is controlled by opts, such as WithVerifyLevel, or a
+// VerifyOptions.
+func Verify(hd io.ReadSeeker, opts ...Option) *Report {
+	o := collectOptions(opts)
+	if err := o.checkBlockSize(); err != nil {
+		r := &Report{}
+		r.add(SeverityError, err)
+		return r
+	}
+	bs := o.blockSize
+	if bs == 0 {
+		bs = findBlockSize(hd)
+	}
+	return verify(hd, bs, o.verify)
+}
+
+// Verifies the GPT on hd, which has logical blocks of bs bytes, as opts
+// requires.
+func verify(hd io.ReadSeeker, bs uint64, opts VerifyOptions) *Report {
+	r := &Report{}
+	primary, err := readHeader(hd, 1, bs)
+	if err != nil {
+		r.add(SeverityError, err)
+		return r
+	}
+	if string(primary.Signature[:]) != "EFI PART" {
+		// The backup's location isn't known without the primary, but
+		// it should be at the end of the device.
+		err := primary.Verify()
+		if size, serr := deviceSize(hd); serr == nil {
+			backup, berr := readHeader(hd, size/bs-1, bs)
+			if berr == nil && string(backup.Signature[:]) == "EFI PART" {
+				if s := checkCRCs(hd, backup, bs); s.headerOK && s.arrayOK() {
+					err = errorf(CodeBackupOnly, "%w, the table can only be read from the backup header", err)
+					r.add(SeverityError, err, RestorePrimaryFromBackup)
+					return r
+				}
+			}
+		}
+		r.add(SeverityError, err)
+		return r
+	}
+	r.add(SeverityError, primary.Verify())
+	if size, err := deviceSize(hd); err == nil {
+		r.add(SeverityError, primary.verifyEntryArray(bs, size))
+	}
+	if primary.Revision != 0x00010000 {
+		r.add(SeverityWarning, errorf(CodeHeaderRevision, "Primary header has non-standard revision %#08x", primary.Revision))
+	}
+	verifyMBR(hd, primary, r)
+	verifyBlockSize(hd, bs, r)
+	if opts.Level < VerifyChecksums {
+		return r
+	}
+	// The backup is used to diagnose checksum errors, even if it isn't
+	// being verified.
+	var other *crcState
+	if backup, err := readHeader(hd, primary.AltLBA, bs); err == nil && string(backup.Signature[:]) == "EFI PART" {
+		s := checkCRCs(hd, backup, bs)
+		other = &s
+	}
+	r.diagnoseCRCs("Primary", "backup", checkCRCs(hd, primary, bs), other, RepairPrimaryHeaderCRC, RepairPrimaryArrayCRC, RestorePrimaryFromBackup)
+	if opts.Level < VerifyBackup {
+		return r
+	}
+
+	entries, err := primary.readPartitions(hd, bs, nil, nil)
+	if err != nil {
+		r.add(SeverityError, err)
+		return r
+	}
+	t := &Table{Primary: primary, Entries: entries, blockSize: bs}
+	t.verifyBackup(hd, r)
+	if opts.Level < VerifyPartitions {
+		return r
+	}
+	t.verifyPartitions(opts, r)
+	if opts.Level < VerifyContents {
+		return r
+	}
+	r.addAll(SeverityError, t.verifyContents(hd, opts.Progress))
+	if opts.Level < VerifyDeep {
+		return r
+	}
+	r.addAll(SeverityError, t.verifyDeep(hd, opts))
+	return r
+}
+
+// Validates the table in memory without reading the disk, returning a report
+// of every problem found. This is intended for checking a table before it's
+// written. The primary header's fields, where the entry arrays would be
+// written, and the partitions are checked as Verify would check them at
+// VerifyPartitions, with the partition names checked too. Checksums aren't
+// checked, since they're recomputed when the table is written.
+func (t *Table) Validate() *Report {
+	r := &Report{}
+	r.add(SeverityError, t.Primary.Verify())
+	if _, err := t.encodeEntries(); err != nil {
+		r.add(SeverityError, err)
+	}
+	// The backup is synced with the primary when the table is written.
+	written := *t
+	written.syncBackup()
+	r.addAll(SeverityError, written.VerifyPlacement(0))
+	t.verifyPartitions(VerifyOptions{Names: true}, r)
+	return r
+}
+
+// Reads the backup header into t, and checks its fields and checksums, that
+// it describes the same table as the primary, and that it's at the end of hd.
+func (t *Table) verifyBackup(hd io.ReadSeeker, r *Report) {
+	backup, err := readHeader(hd, t.Primary.AltLBA, t.BlockSize())
+	if err != nil {
+		r.add(SeverityError, errorf(CodeUnreadableBlock, "Could not read backup header at LBA %d: %w", t.Primary.AltLBA, err))
+	} else {
+		t.Backup = backup
+		r.addAll(SeverityError, t.verifyBackupHeader())
+		if string(backup.Signature[:]) == "EFI PART" {
+			primary := checkCRCs(hd, t.Primary, t.BlockSize())
+			r.diagnoseCRCs("Backup", "primary", checkCRCs(hd, backup, t.BlockSize()), &primary, RepairBackupHeaderCRC, RepairBackupArrayCRC, RestoreBackupFromPrimary)
+		}
+		r.addAll(SeverityError, t.VerifyPlacement(0))
+	}
+
+	// A backup header before the end of the device is still usable, the
+	// device has just grown since the GPT was written.
+	size, err := deviceSize(hd)
+	if err != nil {
+		r.add(SeverityError, err)
+		return
+	}
+	last := size/t.BlockSize() - 1
+	switch {
+	case t.Primary.AltLBA > last:
+		r.add(SeverityError, errorf(CodeBackupLocation, "Backup header at LBA %d is past the end of the device (last LBA %d)", t.Primary.AltLBA, last))
+	case t.Primary.AltLBA < last:
+		r.add(SeverityWarning, errorf(CodeBackupLocation, "Backup header at LBA %d is not at the end of the device (last LBA %d)", t.Primary.AltLBA, last))
+	}
+}
+
+// Checks the fields of the backup header, and that it describes the same
+// table as the primary.
+func (t *Table) verifyBackupHeader() []error {
+	b := t.Backup
+	if string(b.Signature[:]) != "EFI PART" {
+		return []error{errorf(CodeInvalidHeader, "Invalid backup GPT Header \"%v\"", string(b.Signature[:]))}
+	}
+	var errs []error
+	if b.Reserved != 0 || !bytes.Equal(b.Padding[:], make([]byte, len(b.Padding))) {
+		errs = append(errs, errorf(CodeInvalidHeader, "Invalid backup GPT Header. Reserved area not zero."))
+	}
+	p := t.Primary
+	if b.Disk != p.Disk {
+		errs = append(errs, errorf(CodeBackupMismatch, "Backup header disk GUID %v does not match primary %v", b.Disk, p.Disk))
+	}
+	if b.FirstUseableLBA != p.FirstUseableLBA || b.LastUseableLBA != p.LastUseableLBA {
+		errs = append(errs, errorf(CodeBackupMismatch, "Backup header usable area (LBA %d-%d) does not match primary (LBA %d-%d)", b.FirstUseableLBA, b.LastUseableLBA, p.FirstUseableLBA, p.LastUseableLBA))
+	}
+	if b.MaxNumberPartitionEntries != p.MaxNumberPartitionEntries || b.SizeOfPartitionEntry != p.SizeOfPartitionEntry {
+		errs = append(errs, errorf(CodeBackupMismatch, "Backup header partition entry array (%d entries of %d bytes) does not match primary (%d entries of %d bytes)", b.MaxNumberPartitionEntries, b.SizeOfPartitionEntry, p.MaxNumberPartitionEntries, p.SizeOfPartitionEntry))
+	}
+	if b.PartitionEntryArrayCRC32 != p.PartitionEntryArrayCRC32 {
+		errs = append(errs, errorf(CodeBackupMismatch, "Backup partition entry array does not match primary"))
+	}
+	return errs
+}
+
+// Checks that the partitions in use are inside the usable area of the disk
+// and don't overlap, and that their GUIDs (and optionally names) are valid.
+// Unknown partition types, misaligned partitions, and entry arrays smaller
+// than the UEFI specification's minimum are warnings. Partitions on disks
+// created by Apple's tools are only expected to be aligned to AppleAlignment.
+func (t *Table) verifyPartitions(opts VerifyOptions, r *Report) {
+	var errs []error
+	if t.Primary.entryArraySize() < 16384 {
+		r.add(SeverityWarning, errorf(CodeEntryArraySize, "Partition entry array is %d bytes, smaller than the minimum of 16384", t.Primary.entryArraySize()))
+	}
+	align, alignName := t.expectedAlignment()
+	var used []int
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() {
+			continue
+		}
+		used = append(used, i)
+		if _, ok := partitionTypes[e.PartitionType]; !ok {
+			r.add(SeverityWarning, entryErrorf(i, CodeUnknownType, "Partition %d has unknown type %v", i, e.PartitionType))
+		}
+		if e.StartingLBA%align != 0 {
+			r.add(SeverityWarning, entryErrorf(i, CodeMisaligned, "Partition %d (LBA %d) is not aligned to %s", i, e.StartingLBA, alignName))
+		}
+		if e.StartingLBA > e.EndingLBA {
+			errs = append(errs, entryErrorf(i, CodeInvalidRange, "Partition %d ends (LBA %d) before it starts (LBA %d)", i, e.EndingLBA, e.StartingLBA))
+			continue
+		}
+		if e.StartingLBA < t.Primary.FirstUseableLBA || e.EndingLBA > t.Primary.LastUseableLBA {
+			errs = append(errs, entryErrorf(i, CodeOutsideUsable, "Partition %d (LBA %d-%d) is outside of the usable area (LBA %d-%d)", i, e.StartingLBA, e.EndingLBA, t.Primary.FirstUseableLBA, t.Primary.LastUseableLBA))
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		return t.Entries[used[i]].StartingLBA < t.Entries[used[j]].StartingLBA
+	})
+	for k := 1; k < len(used); k++ {
+		prev, cur := t.Entries[used[k-1]], t.Entries[used[k]]
+		if cur.StartingLBA <= prev.EndingLBA {
+			errs = append(errs, entryErrorf(used[k], CodeOverlap, "Partition %d (LBA %d-%d) overlaps partition %d (LBA %d-%d)", used[k], cur.StartingLBA, cur.EndingLBA, used[k-1], prev.StartingLBA, prev.EndingLBA))
+		}
+	}
+	errs = append(errs, t.VerifyGUIDs()...)
+	r.addAll(SeverityError, errs)
+	if opts.Names {
+		r.addAll(SeverityWarning, t.VerifyNames())
+	}
+}
+
+// Returns the alignment, in logical blocks, that the table's partitions are
+// expected to start at, and a description of it. Apple's tools only align
+// partitions to 4KiB.
+func (t *Table) expectedAlignment() (uint64, string) {
+	if t.IsApple() {
+		return max(AppleAlignment/t.BlockSize(), 1), "4KiB"
+	}
+	return max(verifyAlignment/t.BlockSize(), 1), "1MiB"
+}
+
+// Checks that the first and last block of every partition in use can be read.
+func (t *Table) verifyContents(hd io.ReadSeeker, fn ProgressFunc) []error {
+	var errs []error
+	bs := t.BlockSize()
+	block := make([]byte, bs)
+	var total uint64
+	for _, e := range t.Entries {
+		if !e.PartitionType.IsZero() && e.StartingLBA <= e.EndingLBA {
+			total += 2 * bs
+		}
+	}
+	p := newProgress(fn, total)
+	defer p.finish()
+	for i, e := range t.Entries {
+		if e.PartitionType.IsZero() || e.StartingLBA > e.EndingLBA {
+			continue
+		}
+		p.phase(fmt.Sprintf("reading partition %d", i))
+		for _, lba := range []uint64{e.StartingLBA, e.EndingLBA} {
+			p.add(bs)
+			if _, err := hd.Seek(int64(lba*bs), io.SeekStart); err != nil {
+				return append(errs, err)
+			}
+			if _, err := io.ReadFull(hd, block); err != nil {
+				errs = append(errs, entryErrorf(i, CodeUnreadableBlock, "Could not read LBA %d of partition %d: %w", lba, i, err))
+				break
+			}
+		}
+	}
+	return errs
+}
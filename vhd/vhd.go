@@ -0,0 +1,399 @@
+// Package vhd provides access to the virtual disk inside a Microsoft VHD or
+// VHDX image, as used by Hyper-V and Azure, so that the GPT in a virtual
+// machine's disk can be read or modified without converting the image.
+//
+// Fixed and dynamic images are supported. Differencing images (which have a
+// parent) are not.
+package vhd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// The cookies which identify a VHD footer, VHD dynamic disk header, and VHDX
+// file.
+const (
+	FooterCookie  = "conectix"
+	DynamicCookie = "cxsparse"
+	VHDXSignature = "vhdxfile"
+)
+
+// VHD disk types.
+const (
+	diskFixed   = 2
+	diskDynamic = 3
+)
+
+// A BAT entry for a block which is not allocated.
+const unallocated = 0xFFFFFFFF
+
+// The on disk VHD footer. A dynamic disk also has a copy of the footer at
+// the start of the file.
+type footer struct {
+	Cookie             [8]byte
+	Features           uint32
+	FileFormatVersion  uint32
+	DataOffset         uint64
+	TimeStamp          uint32
+	CreatorApplication [4]byte
+	CreatorVersion     uint32
+	CreatorHostOS      uint32
+	OriginalSize       uint64
+	CurrentSize        uint64
+	DiskGeometry       uint32
+	DiskType           uint32
+	Checksum           uint32
+	UniqueID           [16]byte
+	SavedState         uint8
+	Reserved           [427]byte
+}
+
+// The on disk header of a VHD dynamic disk.
+type dynamicHeader struct {
+	Cookie          [8]byte
+	DataOffset      uint64
+	TableOffset     uint64
+	HeaderVersion   uint32
+	MaxTableEntries uint32
+	BlockSize       uint32
+}
+
+// The format specific part of an image.
+type backend interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// An Image is an open VHD or VHDX image.
+type Image struct {
+	f        *os.File
+	writable bool
+	size     int64
+	backend  backend
+	pos      int64
+}
+
+var _ gpt.BlockDevice = (*Image)(nil)
+
+// The VHD and VHDX formats, for registering with gpt.RegisterImageFormat.
+var Format = gpt.ImageFormat{
+	Name:  "vhd",
+	Match: IsImage,
+	Open: func(path string, rw bool) (gpt.BlockDevice, error) {
+		if rw {
+			return OpenRW(path)
+		}
+		return Open(path)
+	},
+}
+
+// Returns true if r, which is size bytes long, contains a VHD or VHDX image.
+// The footer of a fixed VHD is at the end of the file, so both ends of the
+// file are checked.
+func IsImage(r io.ReaderAt, size int64) bool {
+	magic := make([]byte, 8)
+	if _, err := r.ReadAt(magic, 0); err == nil {
+		if s := string(magic); s == VHDXSignature || s == FooterCookie {
+			return true
+		}
+	}
+	if size < 512 {
+		return false
+	}
+	_, err := r.ReadAt(magic, size-512)
+	return err == nil && string(magic) == FooterCookie
+}
+
+// Opens the VHD or VHDX image at path for reading.
+func Open(path string) (*Image, error) {
+	return open(path, os.O_RDONLY)
+}
+
+// Opens the VHD or VHDX image at path for reading and writing.
+func OpenRW(path string) (*Image, error) {
+	return open(path, os.O_RDWR)
+}
+
+func open(path string, flag int) (*Image, error) {
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{f: f, writable: flag != os.O_RDONLY}
+	magic := make([]byte, 8)
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if string(magic) == VHDXSignature {
+		err = img.openVHDX()
+	} else {
+		err = img.openVHD()
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return img, nil
+}
+
+// Opens a VHD image, by reading the footer at the end of the file.
+func (img *Image) openVHD() error {
+	st, err := img.f.Stat()
+	if err != nil {
+		return err
+	}
+	var ft footer
+	if st.Size() < 512 {
+		return fmt.Errorf("Not a VHD image")
+	}
+	if err := binary.Read(io.NewSectionReader(img.f, st.Size()-512, 512), binary.BigEndian, &ft); err != nil {
+		return err
+	}
+	if string(ft.Cookie[:]) != FooterCookie {
+		return fmt.Errorf("Not a VHD image")
+	}
+	img.size = int64(ft.CurrentSize)
+
+	switch ft.DiskType {
+	case diskFixed:
+		img.backend = fixedVHD{img.f}
+		return nil
+	case diskDynamic:
+		d, err := openDynamic(img.f, ft)
+		if err != nil {
+			return err
+		}
+		img.backend = d
+		return nil
+	default:
+		return fmt.Errorf("Unsupported VHD disk type %d", ft.DiskType)
+	}
+}
+
+// A fixed VHD is a raw disk image followed by a footer.
+type fixedVHD struct {
+	f *os.File
+}
+
+func (v fixedVHD) ReadAt(p []byte, off int64) (int, error) {
+	return v.f.ReadAt(p, off)
+}
+
+func (v fixedVHD) WriteAt(p []byte, off int64) (int, error) {
+	return v.f.WriteAt(p, off)
+}
+
+// A dynamic VHD stores the disk in blocks which are allocated as they're
+// written to, located by the block allocation table (BAT.)
+type dynamicVHD struct {
+	f         *os.File
+	footer    footer
+	blockSize int64
+
+	// The size of the sector bitmap preceding each block's data.
+	bitmapSize int64
+
+	tableOffset int64
+	bat         []uint32
+}
+
+// Reads the dynamic disk header and BAT of a dynamic VHD.
+func openDynamic(f *os.File, ft footer) (*dynamicVHD, error) {
+	var h dynamicHeader
+	if err := binary.Read(io.NewSectionReader(f, int64(ft.DataOffset), 1024), binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	if string(h.Cookie[:]) != DynamicCookie {
+		return nil, fmt.Errorf("Invalid VHD dynamic disk header")
+	}
+	if h.BlockSize == 0 || h.BlockSize%512 != 0 {
+		return nil, fmt.Errorf("Invalid VHD block size %d", h.BlockSize)
+	}
+	d := &dynamicVHD{
+		f:           f,
+		footer:      ft,
+		blockSize:   int64(h.BlockSize),
+		tableOffset: int64(h.TableOffset),
+		bat:         make([]uint32, h.MaxTableEntries),
+	}
+	sectors := d.blockSize / 512
+	d.bitmapSize = (sectors/8 + 511) / 512 * 512
+	if err := binary.Read(io.NewSectionReader(f, d.tableOffset, int64(len(d.bat))*4), binary.BigEndian, d.bat); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Calls fn for each part of len(p) bytes at off which is within a single
+// block, with the block index and offset within the block.
+func forBlocks(p []byte, off, blockSize int64, fn func(p []byte, block, inBlock int64) error) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		block, inBlock := pos/blockSize, pos%blockSize
+		chunk := p[n:]
+		if int64(len(chunk)) > blockSize-inBlock {
+			chunk = chunk[:blockSize-inBlock]
+		}
+		if err := fn(chunk, block, inBlock); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+func (d *dynamicVHD) ReadAt(p []byte, off int64) (int, error) {
+	return forBlocks(p, off, d.blockSize, func(p []byte, block, inBlock int64) error {
+		if block >= int64(len(d.bat)) || d.bat[block] == unallocated {
+			clear(p)
+			return nil
+		}
+		_, err := d.f.ReadAt(p, int64(d.bat[block])*512+d.bitmapSize+inBlock)
+		return err
+	})
+}
+
+func (d *dynamicVHD) WriteAt(p []byte, off int64) (int, error) {
+	return forBlocks(p, off, d.blockSize, func(p []byte, block, inBlock int64) error {
+		if block >= int64(len(d.bat)) {
+			return fmt.Errorf("Write outside of the BAT")
+		}
+		if d.bat[block] == unallocated {
+			if isZero(p) {
+				return nil
+			}
+			if err := d.allocate(block); err != nil {
+				return err
+			}
+		}
+		_, err := d.f.WriteAt(p, int64(d.bat[block])*512+d.bitmapSize+inBlock)
+		return err
+	})
+}
+
+// Allocates a block at the end of the file, where the footer currently is,
+// and moves the footer after it.
+func (d *dynamicVHD) allocate(block int64) error {
+	st, err := d.f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := st.Size() - 512
+
+	// The sector bitmap is all ones, since the whole block (initially
+	// zeros) is now part of the disk.
+	data := make([]byte, d.bitmapSize+d.blockSize+512)
+	for i := int64(0); i < d.blockSize/512/8; i++ {
+		data[i] = 0xFF
+	}
+	footer := data[d.bitmapSize+d.blockSize:]
+	if _, err := d.f.ReadAt(footer, offset); err != nil {
+		return err
+	}
+	if _, err := d.f.WriteAt(data, offset); err != nil {
+		return err
+	}
+
+	d.bat[block] = uint32(offset / 512)
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], d.bat[block])
+	_, err = d.f.WriteAt(b[:], d.tableOffset+block*4)
+	return err
+}
+
+// Returns true if every byte of p is zero.
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Closes the image.
+func (img *Image) Close() error {
+	return img.f.Close()
+}
+
+// Returns the size of the virtual disk in bytes.
+func (img *Image) Size() int64 {
+	return img.size
+}
+
+// Flushes the image file to stable storage.
+func (img *Image) Sync() error {
+	return img.f.Sync()
+}
+
+// Reads len(p) bytes of the virtual disk starting at off.
+func (img *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative offset")
+	}
+	if off >= img.size {
+		return 0, io.EOF
+	}
+	var eof error
+	if remaining := img.size - off; int64(len(p)) > remaining {
+		p, eof = p[:remaining], io.EOF
+	}
+	n, err := img.backend.ReadAt(p, off)
+	if err == nil {
+		err = eof
+	}
+	return n, err
+}
+
+// Writes len(p) bytes to the virtual disk starting at off.
+func (img *Image) WriteAt(p []byte, off int64) (int, error) {
+	if !img.writable {
+		return 0, fmt.Errorf("Image is not open for writing")
+	}
+	if off < 0 || off+int64(len(p)) > img.size {
+		return 0, fmt.Errorf("Write outside of the virtual disk")
+	}
+	return img.backend.WriteAt(p, off)
+}
+
+// Reads from the current position in the virtual disk.
+func (img *Image) Read(p []byte) (int, error) {
+	n, err := img.ReadAt(p, img.pos)
+	img.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Writes to the current position in the virtual disk.
+func (img *Image) Write(p []byte) (int, error) {
+	n, err := img.WriteAt(p, img.pos)
+	img.pos += int64(n)
+	return n, err
+}
+
+// Sets the position in the virtual disk for the next Read or Write.
+func (img *Image) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += img.pos
+	case io.SeekEnd:
+		offset += img.size
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("Seek to negative offset %d", offset)
+	}
+	img.pos = offset
+	return offset, nil
+}
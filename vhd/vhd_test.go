@@ -0,0 +1,216 @@
+package vhd_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+	"github.com/driusan/gpt/vhd"
+)
+
+// The size of the virtual disks in the tests, which is two dynamic VHD blocks
+// or four VHDX blocks.
+const diskSize = 4 << 20
+
+// Returns a raw image of the virtual disk with a GPT and one partition.
+func testDisk(t *testing.T) []byte {
+	t.Helper()
+	d := gpttest.Disk{
+		Sectors:    diskSize / 512,
+		Partitions: []gpttest.Partition{{Type: gpt.EFISystemPartition, Start: 2048, End: 4095}},
+	}
+	raw, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// Returns a VHD footer for a disk of the given type.
+func vhdFooter(diskType uint32, dataOffset uint64) []byte {
+	f := make([]byte, 512)
+	copy(f, vhd.FooterCookie)
+	binary.BigEndian.PutUint32(f[12:], 0x00010000)
+	binary.BigEndian.PutUint64(f[16:], dataOffset)
+	binary.BigEndian.PutUint64(f[40:], diskSize)
+	binary.BigEndian.PutUint64(f[48:], diskSize)
+	binary.BigEndian.PutUint32(f[60:], diskType)
+	return f
+}
+
+// Writes the image to a file in a temporary directory, then adds a partition
+// to the GPT of the virtual disk inside it, and checks that the table and the
+// rest of the disk read back after the image is reopened. Returns the image
+// file after the write.
+func roundTrip(t *testing.T, name string, image, raw []byte) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	img, err := vhd.OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Size() != diskSize {
+		t.Errorf("virtual disk is %d bytes, want %d", img.Size(), diskSize)
+	}
+	if _, err := img.WriteAt(raw, 0); err != nil {
+		t.Fatal(err)
+	}
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := table.AddPartition(gpt.LinuxFilesystem, 1<<20, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Write(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = vhd.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+	read, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Equal(table) || read.Entries[i].PartitionType != gpt.LinuxFilesystem {
+		t.Error("the table read back from the image isn't the table that was written")
+	}
+	got := make([]byte, diskSize)
+	if _, err := img.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[34*512:diskSize-33*512], raw[34*512:diskSize-33*512]) {
+		t.Error("the partitions' contents changed")
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return after
+}
+
+func TestFixedVHD(t *testing.T) {
+	image := append(make([]byte, diskSize), vhdFooter(2, 0xFFFFFFFFFFFFFFFF)...)
+	after := roundTrip(t, "fixed.vhd", image, testDisk(t))
+	if len(after) != len(image) || !bytes.Equal(after[diskSize:], image[diskSize:]) {
+		t.Error("the footer of the fixed image moved or changed")
+	}
+}
+
+func TestDynamicVHD(t *testing.T) {
+	// The footer copy, the dynamic disk header, a one sector BAT with no
+	// blocks allocated, and the footer.
+	const tableOffset = 1536
+	footer := vhdFooter(3, 512)
+	image := append([]byte{}, footer...)
+	h := make([]byte, 1024)
+	copy(h, vhd.DynamicCookie)
+	binary.BigEndian.PutUint64(h[8:], 0xFFFFFFFFFFFFFFFF)
+	binary.BigEndian.PutUint64(h[16:], tableOffset)
+	binary.BigEndian.PutUint32(h[24:], 0x00010000)
+	binary.BigEndian.PutUint32(h[28:], 2)
+	binary.BigEndian.PutUint32(h[32:], 2<<20)
+	image = append(image, h...)
+	image = append(image, bytes.Repeat([]byte{0xFF}, 512)...)
+	image = append(image, footer...)
+
+	after := roundTrip(t, "dynamic.vhd", image, testDisk(t))
+	// Both blocks were written, so both are allocated, with the footer
+	// moved after them.
+	for block := 0; block < 2; block++ {
+		if sector := binary.BigEndian.Uint32(after[tableOffset+4*block:]); sector == 0xFFFFFFFF {
+			t.Errorf("block %d isn't allocated", block)
+		}
+	}
+	if !bytes.Equal(after[len(after)-512:], footer) {
+		t.Error("the image doesn't end with the footer")
+	}
+}
+
+// The layout of the VHDX image built by TestVHDX: the metadata region, the
+// BAT, and the four 1MiB payload blocks.
+const (
+	vhdxMetadata  = 1 << 20
+	vhdxBAT       = 2 << 20
+	vhdxPayload   = 3 << 20
+	vhdxBlockSize = 1 << 20
+)
+
+// Writes the fields to b in little endian order, with the VHDX checksum of b
+// at byte 4 if checksum is set.
+func putVHDX(b []byte, checksum bool, fields ...any) {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		binary.Write(&buf, binary.LittleEndian, f)
+	}
+	copy(b, buf.Bytes())
+	if checksum {
+		binary.LittleEndian.PutUint32(b[4:], crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)))
+	}
+}
+
+func mustGUID(t *testing.T, s string) gpt.GUID {
+	g, err := gpt.ParseGUID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestVHDX(t *testing.T) {
+	image := make([]byte, vhdxPayload+diskSize)
+	copy(image, vhd.VHDXSignature)
+	putVHDX(image[64<<10:68<<10], true, []byte("head"), uint32(0), uint64(1), [3]gpt.GUID{}, uint16(0), uint16(1), uint32(1<<20), uint64(1<<20))
+	putVHDX(image[192<<10:256<<10], true, []byte("regi"), uint32(0), uint32(2), uint32(0),
+		mustGUID(t, "2DC27766-F623-4200-9D64-115E9BFD4A08"), uint64(vhdxBAT), uint32(1<<20), uint32(1),
+		mustGUID(t, "8B7CA206-4790-4B9A-B8FE-575F050F886E"), uint64(vhdxMetadata), uint32(1<<20), uint32(1))
+
+	// The metadata table, followed by the file parameters, virtual disk
+	// size and logical sector size items.
+	putVHDX(image[vhdxMetadata:], false, []byte("metadata"), uint16(0), uint16(3), [20]byte{},
+		mustGUID(t, "CAA16737-FA36-4D43-B3B6-33F0AA44E76B"), uint32(64<<10), uint32(8), uint32(4), uint32(0),
+		mustGUID(t, "2FA54224-CD1B-4876-B211-5DBED83BF4B8"), uint32(64<<10+8), uint32(8), uint32(4), uint32(0),
+		mustGUID(t, "8141BF1D-A96F-4709-BA47-F233A8FAAB5F"), uint32(64<<10+16), uint32(4), uint32(4), uint32(0))
+	putVHDX(image[vhdxMetadata+64<<10:], false, uint32(vhdxBlockSize), uint32(0), uint64(diskSize), uint32(512))
+
+	// Every block is allocated, as in a fixed image.
+	for block := 0; block < diskSize/vhdxBlockSize; block++ {
+		offset := uint64(vhdxPayload + block*vhdxBlockSize)
+		binary.LittleEndian.PutUint64(image[vhdxBAT+8*block:], offset|6)
+	}
+	roundTrip(t, "fixed.vhdx", image, testDisk(t))
+
+	// A block which isn't allocated can't be written, since the BAT can
+	// only be updated through the log.
+	binary.LittleEndian.PutUint64(image[vhdxBAT+8:], 0)
+	path := filepath.Join(t.TempDir(), "sparse.vhdx")
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	img, err := vhd.OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+	if _, err := img.WriteAt([]byte("data"), vhdxBlockSize); err == nil {
+		t.Error("wrote to an unallocated VHDX block")
+	}
+	if _, err := img.WriteAt(make([]byte, 512), vhdxBlockSize); err != nil {
+		t.Errorf("writing zeros to an unallocated VHDX block: %v", err)
+	}
+}
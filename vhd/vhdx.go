@@ -0,0 +1,316 @@
+package vhd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/driusan/gpt"
+)
+
+// The offsets of the two VHDX headers and region tables.
+const (
+	vhdxHeader1      = 64 << 10
+	vhdxHeader2      = 128 << 10
+	vhdxRegionTable1 = 192 << 10
+	vhdxRegionTable2 = 256 << 10
+)
+
+// BAT entry states for payload blocks.
+const (
+	payloadNotPresent       = 0
+	payloadUndefined        = 1
+	payloadZero             = 2
+	payloadUnmapped         = 3
+	payloadFullyPresent     = 6
+	payloadPartiallyPresent = 7
+)
+
+// Region and metadata item GUIDs.
+var (
+	vhdxBATRegion       = mustGUID("2DC27766-F623-4200-9D64-115E9BFD4A08")
+	vhdxMetadataRegion  = mustGUID("8B7CA206-4790-4B9A-B8FE-575F050F886E")
+	vhdxFileParameters  = mustGUID("CAA16737-FA36-4D43-B3B6-33F0AA44E76B")
+	vhdxVirtualDiskSize = mustGUID("2FA54224-CD1B-4876-B211-5DBED83BF4B8")
+	vhdxLogicalSector   = mustGUID("8141BF1D-A96F-4709-BA47-F233A8FAAB5F")
+)
+
+func mustGUID(s string) gpt.GUID {
+	g, err := gpt.ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// The on disk VHDX header. GUIDs are stored in the same mixed endian layout
+// as GPT.
+type vhdxHeader struct {
+	Signature      [4]byte
+	Checksum       uint32
+	SequenceNumber uint64
+	FileWriteGUID  gpt.GUID
+	DataWriteGUID  gpt.GUID
+	LogGUID        gpt.GUID
+	LogVersion     uint16
+	Version        uint16
+	LogLength      uint32
+	LogOffset      uint64
+}
+
+type vhdxRegionTableHeader struct {
+	Signature  [4]byte
+	Checksum   uint32
+	EntryCount uint32
+	Reserved   uint32
+}
+
+type vhdxRegionEntry struct {
+	GUID       gpt.GUID
+	FileOffset uint64
+	Length     uint32
+	Required   uint32
+}
+
+type vhdxMetadataHeader struct {
+	Signature  [8]byte
+	Reserved   uint16
+	EntryCount uint16
+	Reserved2  [20]byte
+}
+
+type vhdxMetadataEntry struct {
+	ItemID   gpt.GUID
+	Offset   uint32
+	Length   uint32
+	Flags    uint32
+	Reserved uint32
+}
+
+// A VHDX image stores the disk in payload blocks located by the BAT, which
+// has a sector bitmap entry after every chunk of payload entries.
+type vhdx struct {
+	f          *os.File
+	blockSize  int64
+	chunkRatio int64
+	bat        []uint64
+}
+
+// Reads a 4 KiB structure at off, and checks its CRC-32C, which is stored at
+// byte 4 and computed with the checksum field zeroed.
+func readChecksummed(f *os.File, off int64, size int) ([]byte, error) {
+	buf := make([]byte, size)
+	if _, err := f.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	want := binary.LittleEndian.Uint32(buf[4:])
+	binary.LittleEndian.PutUint32(buf[4:], 0)
+	if crc32.Checksum(buf, castagnoli) != want {
+		return nil, fmt.Errorf("Bad checksum at offset %d", off)
+	}
+	binary.LittleEndian.PutUint32(buf[4:], want)
+	return buf, nil
+}
+
+// Returns the current VHDX header, which is the valid one with the highest
+// sequence number.
+func readVHDXHeader(f *os.File) (vhdxHeader, error) {
+	var cur vhdxHeader
+	found := false
+	for _, off := range []int64{vhdxHeader1, vhdxHeader2} {
+		buf, err := readChecksummed(f, off, 4096)
+		if err != nil {
+			continue
+		}
+		var h vhdxHeader
+		if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &h); err != nil {
+			return cur, err
+		}
+		if string(h.Signature[:]) != "head" {
+			continue
+		}
+		if !found || h.SequenceNumber > cur.SequenceNumber {
+			cur, found = h, true
+		}
+	}
+	if !found {
+		return cur, fmt.Errorf("No valid VHDX header")
+	}
+	return cur, nil
+}
+
+// Returns the entries of the first valid region table.
+func readRegionTable(f *os.File) ([]vhdxRegionEntry, error) {
+	for _, off := range []int64{vhdxRegionTable1, vhdxRegionTable2} {
+		buf, err := readChecksummed(f, off, 64<<10)
+		if err != nil {
+			continue
+		}
+		r := bytes.NewReader(buf)
+		var h vhdxRegionTableHeader
+		if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+			return nil, err
+		}
+		if string(h.Signature[:]) != "regi" || h.EntryCount > 2047 {
+			continue
+		}
+		entries := make([]vhdxRegionEntry, h.EntryCount)
+		if err := binary.Read(r, binary.LittleEndian, entries); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("No valid VHDX region table")
+}
+
+// Opens a VHDX image.
+func (img *Image) openVHDX() error {
+	h, err := readVHDXHeader(img.f)
+	if err != nil {
+		return err
+	}
+	if !h.LogGUID.IsZero() {
+		// The log contains metadata updates which haven't been applied
+		// yet, so the BAT and metadata can't be trusted.
+		return fmt.Errorf("VHDX log must be replayed before the image can be used")
+	}
+	regions, err := readRegionTable(img.f)
+	if err != nil {
+		return err
+	}
+	var bat, meta *vhdxRegionEntry
+	for i := range regions {
+		switch regions[i].GUID {
+		case vhdxBATRegion:
+			bat = &regions[i]
+		case vhdxMetadataRegion:
+			meta = &regions[i]
+		default:
+			if regions[i].Required != 0 {
+				return fmt.Errorf("Unsupported required VHDX region %v", regions[i].GUID)
+			}
+		}
+	}
+	if bat == nil || meta == nil {
+		return fmt.Errorf("VHDX image is missing the BAT or metadata region")
+	}
+
+	v := &vhdx{f: img.f}
+	var sectorSize uint32
+	if err := v.readMetadata(int64(meta.FileOffset), &sectorSize, &img.size); err != nil {
+		return err
+	}
+	if v.blockSize == 0 || sectorSize == 0 {
+		return fmt.Errorf("Invalid VHDX metadata")
+	}
+	v.chunkRatio = (1 << 23) * int64(sectorSize) / v.blockSize
+
+	blocks := (img.size + v.blockSize - 1) / v.blockSize
+	entries := blocks + (blocks-1)/v.chunkRatio
+	if entries*8 > int64(bat.Length) {
+		return fmt.Errorf("VHDX BAT is too small for the virtual disk")
+	}
+	v.bat = make([]uint64, entries)
+	if err := binary.Read(io.NewSectionReader(img.f, int64(bat.FileOffset), entries*8), binary.LittleEndian, v.bat); err != nil {
+		return err
+	}
+	img.backend = v
+	return nil
+}
+
+// Reads the block size, logical sector size and virtual disk size from the
+// metadata region at off.
+func (v *vhdx) readMetadata(off int64, sectorSize *uint32, size *int64) error {
+	r := io.NewSectionReader(v.f, off, 64<<10)
+	var h vhdxMetadataHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	if string(h.Signature[:]) != "metadata" || h.EntryCount > 2047 {
+		return fmt.Errorf("Invalid VHDX metadata region")
+	}
+	entries := make([]vhdxMetadataEntry, h.EntryCount)
+	if err := binary.Read(r, binary.LittleEndian, entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		item := io.NewSectionReader(v.f, off+int64(e.Offset), int64(e.Length))
+		switch e.ItemID {
+		case vhdxFileParameters:
+			var params struct {
+				BlockSize uint32
+				Flags     uint32
+			}
+			if err := binary.Read(item, binary.LittleEndian, &params); err != nil {
+				return err
+			}
+			if params.Flags&2 != 0 {
+				return fmt.Errorf("Differencing VHDX images are not supported")
+			}
+			v.blockSize = int64(params.BlockSize)
+		case vhdxVirtualDiskSize:
+			var s uint64
+			if err := binary.Read(item, binary.LittleEndian, &s); err != nil {
+				return err
+			}
+			*size = int64(s)
+		case vhdxLogicalSector:
+			if err := binary.Read(item, binary.LittleEndian, sectorSize); err != nil {
+				return err
+			}
+		default:
+			// Bit 2 of the flags marks an item as required.
+			if e.Flags&4 != 0 {
+				return fmt.Errorf("Unsupported required VHDX metadata item %v", e.ItemID)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the BAT entry for a payload block.
+func (v *vhdx) entry(block int64) uint64 {
+	i := block + block/v.chunkRatio
+	if i >= int64(len(v.bat)) {
+		return payloadNotPresent
+	}
+	return v.bat[i]
+}
+
+func (v *vhdx) ReadAt(p []byte, off int64) (int, error) {
+	return forBlocks(p, off, v.blockSize, func(p []byte, block, inBlock int64) error {
+		e := v.entry(block)
+		switch e & 7 {
+		case payloadFullyPresent:
+			_, err := v.f.ReadAt(p, int64(e>>20)<<20+inBlock)
+			return err
+		case payloadPartiallyPresent:
+			return fmt.Errorf("Differencing VHDX images are not supported")
+		default:
+			clear(p)
+			return nil
+		}
+	})
+}
+
+// BUG(driusan): Writing to a VHDX image is only supported for blocks which
+// are already allocated, such as in a fixed image, because allocating a
+// block requires updating the BAT through the VHDX log.
+func (v *vhdx) WriteAt(p []byte, off int64) (int, error) {
+	return forBlocks(p, off, v.blockSize, func(p []byte, block, inBlock int64) error {
+		e := v.entry(block)
+		if e&7 != payloadFullyPresent {
+			if e&7 != payloadPartiallyPresent && isZero(p) {
+				return nil
+			}
+			return fmt.Errorf("Can not write to unallocated VHDX block %d", block)
+		}
+		_, err := v.f.WriteAt(p, int64(e>>20)<<20+inBlock)
+		return err
+	})
+}
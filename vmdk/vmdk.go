@@ -0,0 +1,641 @@
+// Package vmdk provides access to the virtual disk inside a VMware VMDK
+// image, so that the GPT in a virtual machine's disk can be read or modified
+// without converting the image.
+//
+// Both descriptor files which reference separate extent files and monolithic
+// sparse images (with an embedded descriptor) are supported, with FLAT, VMFS,
+// SPARSE and ZERO extents. Stream optimized (compressed) sparse extents can be
+// read but not written. Images with a parent (snapshots) are not supported.
+package vmdk
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/driusan/gpt"
+)
+
+// The magic numbers at the start of a sparse extent, and of a text
+// descriptor file.
+const (
+	SparseMagic     = "KDMV"
+	DescriptorMagic = "# Disk DescriptorFile"
+)
+
+// The size of a sector in a VMDK. All offsets and sizes in VMDK metadata are
+// in sectors.
+const sectorSize = 512
+
+// The grain directory offset of a stream optimized extent, which means that
+// the real offset is in the footer at the end of the file.
+const gdAtEnd = 0xFFFFFFFFFFFFFFFF
+
+// Flags in the sparse extent header.
+const (
+	flagRedundantGT = 1 << 1
+	flagCompressed  = 1 << 16
+	flagMarkers     = 1 << 17
+)
+
+// The on disk header of a sparse extent.
+type sparseHeader struct {
+	Magic              [4]byte
+	Version            uint32
+	Flags              uint32
+	Capacity           uint64
+	GrainSize          uint64
+	DescriptorOffset   uint64
+	DescriptorSize     uint64
+	NumGTEsPerGT       uint32
+	RGDOffset          uint64
+	GDOffset           uint64
+	OverHead           uint64
+	UncleanShutdown    uint8
+	SingleEndLineChar  byte
+	NonEndLineChar     byte
+	DoubleEndLineChar1 byte
+	DoubleEndLineChar2 byte
+	CompressAlgorithm  uint16
+}
+
+// An extent is a contiguous range of sectors of the virtual disk, stored
+// in a single file.
+type extent struct {
+	// The range of sectors of the virtual disk stored in this extent.
+	start, sectors uint64
+
+	// The access mode (RW, RDONLY or NOACCESS) and type of extent.
+	access, kind string
+
+	// The file that the extent is stored in, and for flat extents the
+	// sector offset of the extent in the file. nil for ZERO extents.
+	f      *os.File
+	offset uint64
+
+	// The metadata of a sparse extent.
+	sparse *sparseExtent
+}
+
+// The metadata of a sparse extent.
+type sparseExtent struct {
+	hdr sparseHeader
+
+	// The grain directories. Each entry is the sector offset of a grain
+	// table. The redundant directory is nil if the extent doesn't have one.
+	gd, rgd []uint32
+
+	// The grain tables which have been read, keyed by their sector
+	// offset.
+	gtCache map[uint32][]uint32
+}
+
+// An Image is an open VMDK image.
+type Image struct {
+	extents  []*extent
+	files    []*os.File
+	writable bool
+	sectors  uint64
+	pos      int64
+}
+
+var _ gpt.BlockDevice = (*Image)(nil)
+
+// The VMDK format, for registering with gpt.RegisterImageFormat.
+var Format = gpt.ImageFormat{
+	Name: "vmdk",
+	Match: func(r io.ReaderAt, size int64) bool {
+		magic := make([]byte, len(DescriptorMagic))
+		n, _ := r.ReadAt(magic, 0)
+		magic = magic[:n]
+		return bytes.HasPrefix(magic, []byte(SparseMagic)) || bytes.Equal(magic, []byte(DescriptorMagic))
+	},
+	Open: func(path string, rw bool) (gpt.BlockDevice, error) {
+		if rw {
+			return OpenRW(path)
+		}
+		return Open(path)
+	},
+}
+
+// Opens the VMDK image at path, which may be either a descriptor file or a
+// monolithic sparse extent, for reading.
+func Open(path string) (*Image, error) {
+	return open(path, os.O_RDONLY)
+}
+
+// Opens the VMDK image at path, which may be either a descriptor file or a
+// monolithic sparse extent, for reading and writing.
+func OpenRW(path string) (*Image, error) {
+	return open(path, os.O_RDWR)
+}
+
+func open(path string, flag int) (*Image, error) {
+	img := &Image{writable: flag != os.O_RDONLY}
+	if err := img.load(path, flag); err != nil {
+		img.Close()
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return img, nil
+}
+
+// Opens the image's descriptor and extents.
+func (img *Image) load(path string, flag int) error {
+	f, err := img.openFile(path, flag)
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, len(SparseMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return err
+	}
+
+	var descriptor []byte
+	if string(magic) == SparseMagic {
+		// A monolithic sparse image, which should have an embedded
+		// descriptor. If it doesn't, the whole disk is the one
+		// sparse extent.
+		sparse, err := readSparse(f)
+		if err != nil {
+			return err
+		}
+		h := sparse.hdr
+		if h.DescriptorSize == 0 {
+			img.addExtent(&extent{sectors: h.Capacity, access: "RW", kind: "SPARSE", f: f, sparse: sparse})
+			return nil
+		}
+		descriptor = make([]byte, h.DescriptorSize*sectorSize)
+		if _, err := f.ReadAt(descriptor, int64(h.DescriptorOffset*sectorSize)); err != nil {
+			return err
+		}
+		descriptor = bytes.TrimRight(descriptor, "\x00")
+	} else {
+		st, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if st.Size() > 1<<20 {
+			return fmt.Errorf("Not a VMDK image")
+		}
+		if descriptor, err = io.ReadAll(io.NewSectionReader(f, 0, st.Size())); err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(descriptor, []byte(DescriptorMagic)) {
+			return fmt.Errorf("Not a VMDK image")
+		}
+	}
+	return img.parseDescriptor(descriptor, path, flag)
+}
+
+// Parses the text descriptor of the image, and opens each of its extents.
+// path is the file the descriptor was read from.
+func (img *Image) parseDescriptor(descriptor []byte, path string, flag int) error {
+	scanner := bufio.NewScanner(bytes.NewReader(descriptor))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"`)
+			if key == "parentCID" && strings.ToLower(value) != "ffffffff" {
+				return fmt.Errorf("VMDK images with a parent are not supported")
+			}
+			continue
+		}
+		e, name, err := parseExtentLine(line)
+		if err != nil {
+			return err
+		}
+		if e.kind != "ZERO" {
+			if name == "" {
+				return fmt.Errorf("Extent has no file name: %v", line)
+			}
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(filepath.Dir(path), name)
+			}
+			flag := flag
+			if e.access != "RW" {
+				flag = os.O_RDONLY
+			}
+			if e.f, err = img.openFile(name, flag); err != nil {
+				return err
+			}
+		}
+		if e.kind == "SPARSE" || e.kind == "VMFSSPARSE" {
+			if e.sparse, err = readSparse(e.f); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+		}
+		img.addExtent(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(img.extents) == 0 {
+		return fmt.Errorf("Descriptor has no extents")
+	}
+	return nil
+}
+
+// Parses an extent description from a descriptor, such as
+// `RW 4192256 SPARSE "disk-s001.vmdk"`. Returns the extent and the name of
+// its file.
+func parseExtentLine(line string) (*extent, string, error) {
+	var fields []string
+	for rest := line; rest != ""; {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, "", fmt.Errorf("Invalid extent: %v", line)
+			}
+			fields = append(fields, rest[1:end+1])
+			rest = rest[end+2:]
+			continue
+		}
+		field, remaining, _ := strings.Cut(rest, " ")
+		fields = append(fields, field)
+		rest = remaining
+	}
+	if len(fields) < 3 {
+		return nil, "", fmt.Errorf("Invalid extent: %v", line)
+	}
+	sectors, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid extent size: %v", line)
+	}
+	e := &extent{sectors: sectors, access: fields[0], kind: fields[2]}
+	switch e.kind {
+	case "FLAT", "VMFS", "SPARSE", "VMFSSPARSE", "ZERO":
+	default:
+		return nil, "", fmt.Errorf("Unsupported extent type %v", e.kind)
+	}
+	name := ""
+	if len(fields) > 3 {
+		name = fields[3]
+	}
+	if len(fields) > 4 {
+		if e.offset, err = strconv.ParseUint(fields[4], 10, 64); err != nil {
+			return nil, "", fmt.Errorf("Invalid extent offset: %v", line)
+		}
+	}
+	return e, name, nil
+}
+
+// Opens a file which is part of the image, so that it's closed when the
+// image is. A file which is already open is reused.
+func (img *Image) openFile(path string, flag int) (*os.File, error) {
+	for _, f := range img.files {
+		if f.Name() == path {
+			return f, nil
+		}
+	}
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	img.files = append(img.files, f)
+	return f, nil
+}
+
+// Appends an extent to the end of the virtual disk.
+func (img *Image) addExtent(e *extent) {
+	e.start = img.sectors
+	img.sectors += e.sectors
+	img.extents = append(img.extents, e)
+}
+
+// Reads the header and grain directories of the sparse extent in f.
+func readSparse(f *os.File) (*sparseExtent, error) {
+	s := &sparseExtent{gtCache: make(map[uint32][]uint32)}
+	h := &s.hdr
+	if err := binary.Read(io.NewSectionReader(f, 0, sectorSize), binary.LittleEndian, h); err != nil {
+		return nil, err
+	}
+	if string(h.Magic[:]) != SparseMagic {
+		return nil, fmt.Errorf("Invalid sparse extent")
+	}
+	if h.GDOffset == gdAtEnd {
+		// Stream optimized extents store the real header in the
+		// footer, which is followed by an end of stream marker.
+		st, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if err := binary.Read(io.NewSectionReader(f, st.Size()-2*sectorSize, sectorSize), binary.LittleEndian, h); err != nil {
+			return nil, err
+		}
+	}
+	if h.GrainSize == 0 || h.NumGTEsPerGT == 0 {
+		return nil, fmt.Errorf("Invalid sparse extent header")
+	}
+
+	gts := (h.Capacity/h.GrainSize + uint64(h.NumGTEsPerGT) - 1) / uint64(h.NumGTEsPerGT)
+	var err error
+	if s.gd, err = readUint32s(f, h.GDOffset, gts); err != nil {
+		return nil, err
+	}
+	if h.Flags&flagRedundantGT != 0 && h.RGDOffset != 0 {
+		if s.rgd, err = readUint32s(f, h.RGDOffset, gts); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Reads n little endian uint32s from the sector offset of f.
+func readUint32s(f *os.File, sector, n uint64) ([]uint32, error) {
+	buf := make([]byte, n*4)
+	if _, err := f.ReadAt(buf, int64(sector*sectorSize)); err != nil {
+		return nil, err
+	}
+	vals := make([]uint32, n)
+	for i := range vals {
+		vals[i] = binary.LittleEndian.Uint32(buf[i*4:])
+	}
+	return vals, nil
+}
+
+// Closes all of the image's files.
+func (img *Image) Close() error {
+	var err error
+	for _, f := range img.files {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	img.files = nil
+	return err
+}
+
+// Returns the size of the virtual disk in bytes.
+func (img *Image) Size() int64 {
+	return int64(img.sectors * sectorSize)
+}
+
+// Flushes the image's files to stable storage.
+func (img *Image) Sync() error {
+	for _, f := range img.files {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the extent containing the virtual disk byte offset off.
+func (img *Image) extentAt(off uint64) *extent {
+	sector := off / sectorSize
+	for _, e := range img.extents {
+		if sector >= e.start && sector < e.start+e.sectors {
+			return e
+		}
+	}
+	return nil
+}
+
+// Calls fn for each part of the range of len(p) bytes at off which is within
+// a single extent (and within a single grain for sparse extents), with the
+// part of p and the byte offset relative to the start of the extent.
+func (img *Image) split(p []byte, off int64, fn func(e *extent, p []byte, off uint64) error) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		e := img.extentAt(pos)
+		if e == nil {
+			return n, io.EOF
+		}
+		rel := pos - e.start*sectorSize
+		chunk := p[n:]
+		end := e.sectors * sectorSize
+		if e.sparse != nil {
+			grain := e.sparse.hdr.GrainSize * sectorSize
+			end = min(end, (rel/grain+1)*grain)
+		}
+		if uint64(len(chunk)) > end-rel {
+			chunk = chunk[:end-rel]
+		}
+		if err := fn(e, chunk, rel); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// Reads len(p) bytes of the virtual disk starting at off.
+func (img *Image) ReadAt(p []byte, off int64) (int, error) {
+	return img.split(p, off, func(e *extent, p []byte, off uint64) error {
+		switch {
+		case e.access == "NOACCESS":
+			return fmt.Errorf("Extent at sector %d is not accessible", e.start)
+		case e.f == nil:
+			clear(p)
+			return nil
+		case e.sparse == nil:
+			_, err := e.f.ReadAt(p, int64(e.offset*sectorSize+off))
+			return err
+		}
+		return e.readSparse(p, off)
+	})
+}
+
+// Returns the grain table entry for the grain containing the extent byte
+// offset off, and the location of the entry in each grain table.
+func (e *extent) grainEntry(off uint64) (entry uint32, locations []uint64, err error) {
+	s := e.sparse
+	grain := off / (s.hdr.GrainSize * sectorSize)
+	gdIndex := grain / uint64(s.hdr.NumGTEsPerGT)
+	gtIndex := grain % uint64(s.hdr.NumGTEsPerGT)
+	if gdIndex >= uint64(len(s.gd)) || s.gd[gdIndex] == 0 {
+		return 0, nil, nil
+	}
+	gt, ok := s.gtCache[s.gd[gdIndex]]
+	if !ok {
+		if gt, err = readUint32s(e.f, uint64(s.gd[gdIndex]), uint64(s.hdr.NumGTEsPerGT)); err != nil {
+			return 0, nil, err
+		}
+		s.gtCache[s.gd[gdIndex]] = gt
+	}
+	locations = append(locations, uint64(s.gd[gdIndex])*sectorSize+gtIndex*4)
+	if s.rgd != nil && s.rgd[gdIndex] != 0 {
+		locations = append(locations, uint64(s.rgd[gdIndex])*sectorSize+gtIndex*4)
+	}
+	return gt[gtIndex], locations, nil
+}
+
+// Reads p, which must be within a single grain, from the sparse extent at
+// extent byte offset off.
+func (e *extent) readSparse(p []byte, off uint64) error {
+	entry, _, err := e.grainEntry(off)
+	if err != nil {
+		return err
+	}
+	// Entry 0 is an unallocated grain, and 1 is a grain which was
+	// explicitly zeroed.
+	if entry <= 1 {
+		clear(p)
+		return nil
+	}
+	grainSize := e.sparse.hdr.GrainSize * sectorSize
+	inGrain := off % grainSize
+	if e.sparse.hdr.Flags&flagCompressed == 0 {
+		_, err := e.f.ReadAt(p, int64(uint64(entry)*sectorSize+inGrain))
+		return err
+	}
+
+	// Compressed grains start with the LBA (uint64) and compressed size
+	// (uint32) of the grain, followed by zlib compressed data.
+	var marker [12]byte
+	if _, err := e.f.ReadAt(marker[:], int64(entry)*sectorSize); err != nil {
+		return err
+	}
+	size := binary.LittleEndian.Uint32(marker[8:])
+	z, err := zlib.NewReader(io.NewSectionReader(e.f, int64(entry)*sectorSize+12, int64(size)))
+	if err != nil {
+		return err
+	}
+	data := make([]byte, grainSize)
+	if _, err := io.ReadFull(z, data); err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	copy(p, data[inGrain:])
+	return nil
+}
+
+// Writes len(p) bytes to the virtual disk starting at off. Unallocated grains
+// of sparse extents are allocated at the end of the extent file.
+func (img *Image) WriteAt(p []byte, off int64) (int, error) {
+	if !img.writable {
+		return 0, fmt.Errorf("Image is not open for writing")
+	}
+	if off < 0 || uint64(off)+uint64(len(p)) > img.sectors*sectorSize {
+		return 0, fmt.Errorf("Write outside of the virtual disk")
+	}
+	return img.split(p, off, func(e *extent, p []byte, off uint64) error {
+		switch {
+		case e.access != "RW":
+			return fmt.Errorf("Extent at sector %d is read only", e.start)
+		case e.f == nil:
+			return fmt.Errorf("Can not write to a ZERO extent")
+		case e.sparse == nil:
+			_, err := e.f.WriteAt(p, int64(e.offset*sectorSize+off))
+			return err
+		}
+		return e.writeSparse(p, off)
+	})
+}
+
+// Writes p, which must be within a single grain, to the sparse extent at
+// extent byte offset off.
+func (e *extent) writeSparse(p []byte, off uint64) error {
+	s := e.sparse
+	if s.hdr.Flags&flagCompressed != 0 {
+		return fmt.Errorf("Writing to compressed VMDK extents is not supported")
+	}
+	entry, locations, err := e.grainEntry(off)
+	if err != nil {
+		return err
+	}
+	grainSize := s.hdr.GrainSize * sectorSize
+	inGrain := off % grainSize
+	if entry > 1 {
+		_, err := e.f.WriteAt(p, int64(uint64(entry)*sectorSize+inGrain))
+		return err
+	}
+	if entry == 0 && isZero(p) {
+		return nil
+	}
+	if len(locations) == 0 {
+		return fmt.Errorf("Extent has no grain table for offset %d", off)
+	}
+
+	// Allocate a new grain at the end of the file, then point the grain
+	// tables at it.
+	st, err := e.f.Stat()
+	if err != nil {
+		return err
+	}
+	sector := (uint64(st.Size()) + sectorSize - 1) / sectorSize
+	if sector > 0xFFFFFFFF {
+		return fmt.Errorf("Extent file too large to allocate a grain")
+	}
+	data := make([]byte, grainSize)
+	copy(data[inGrain:], p)
+	if _, err := e.f.WriteAt(data, int64(sector*sectorSize)); err != nil {
+		return err
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(sector))
+	for _, loc := range locations {
+		if _, err := e.f.WriteAt(b[:], int64(loc)); err != nil {
+			return err
+		}
+	}
+	grain := off / grainSize
+	gt := s.gtCache[s.gd[grain/uint64(s.hdr.NumGTEsPerGT)]]
+	gt[grain%uint64(s.hdr.NumGTEsPerGT)] = uint32(sector)
+	return nil
+}
+
+// Returns true if every byte of p is zero.
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads from the current position in the virtual disk.
+func (img *Image) Read(p []byte) (int, error) {
+	if img.pos >= img.Size() {
+		return 0, io.EOF
+	}
+	if remaining := img.Size() - img.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := img.ReadAt(p, img.pos)
+	img.pos += int64(n)
+	return n, err
+}
+
+// Writes to the current position in the virtual disk.
+func (img *Image) Write(p []byte) (int, error) {
+	n, err := img.WriteAt(p, img.pos)
+	img.pos += int64(n)
+	return n, err
+}
+
+// Sets the position in the virtual disk for the next Read or Write.
+func (img *Image) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += img.pos
+	case io.SeekEnd:
+		offset += img.Size()
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("Seek to negative offset %d", offset)
+	}
+	img.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,133 @@
+package vmdk_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driusan/gpt"
+	"github.com/driusan/gpt/gpttest"
+	"github.com/driusan/gpt/vmdk"
+)
+
+// The layout of the sparse extents built by testExtent, in sectors: the
+// header, the redundant grain directory and its grain table, then the grain
+// directory and its grain table. One grain table of 512 64KiB grains covers
+// the whole disk.
+const (
+	grainSectors = 128
+	gtEntries    = 512
+	rgdSector    = 1
+	rgtSector    = 2
+	gdSector     = 6
+	gtSector     = 7
+	overhead     = 11
+)
+
+// Returns a monolithic sparse extent, without an embedded descriptor, of a
+// disk of sectors 512 byte sectors with no grains allocated.
+func testExtent(sectors uint64) []byte {
+	img := make([]byte, overhead*512)
+	h := img[:512]
+	copy(h, vmdk.SparseMagic)
+	binary.LittleEndian.PutUint32(h[4:], 1)
+	binary.LittleEndian.PutUint32(h[8:], 1|2) // valid newline test, redundant grain table
+	binary.LittleEndian.PutUint64(h[12:], sectors)
+	binary.LittleEndian.PutUint64(h[20:], grainSectors)
+	binary.LittleEndian.PutUint32(h[44:], gtEntries)
+	binary.LittleEndian.PutUint64(h[48:], rgdSector)
+	binary.LittleEndian.PutUint64(h[56:], gdSector)
+	binary.LittleEndian.PutUint64(h[64:], overhead)
+	binary.LittleEndian.PutUint32(img[rgdSector*512:], rgtSector)
+	binary.LittleEndian.PutUint32(img[gdSector*512:], gtSector)
+	return img
+}
+
+// Returns the grain table entries of the grain tables at sector gt of img.
+func grainTable(img []byte, gt int) []uint32 {
+	entries := make([]uint32, gtEntries)
+	for i := range entries {
+		entries[i] = binary.LittleEndian.Uint32(img[gt*512+i*4:])
+	}
+	return entries
+}
+
+func TestWriteAllocatesGrains(t *testing.T) {
+	d := gpttest.Disk{
+		Sectors:    8192,
+		Partitions: []gpttest.Partition{{Type: gpt.EFISystemPartition, Start: 2048, End: 4095}},
+	}
+	raw, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "disk.vmdk")
+	if err := os.WriteFile(path, testExtent(d.Sectors), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := vmdk.OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := img.WriteAt(raw, 0); err != nil {
+		t.Fatal(err)
+	}
+	table, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := table.AddPartition(gpt.LinuxFilesystem, 1<<20, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Write(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the grains with the tables in them are allocated, and both
+	// grain tables point to them.
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gt, rgt := grainTable(file, gtSector), grainTable(file, rgtSector)
+	allocated := 0
+	for g, sector := range gt {
+		if sector != rgt[g] {
+			t.Errorf("grain %d is at sector %d, but the redundant grain table has %d", g, sector, rgt[g])
+		}
+		if sector != 0 {
+			allocated++
+		}
+	}
+	if gt[0] == 0 || gt[len(raw)/(grainSectors*512)-1] == 0 || allocated != 2 {
+		t.Errorf("%d grains allocated, want the first and last", allocated)
+	}
+
+	img, err = vmdk.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+	read, err := gpt.ReadTable(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Equal(table) || read.Entries[i].PartitionType != gpt.LinuxFilesystem {
+		t.Error("the table read back from the image isn't the table that was written")
+	}
+	got := make([]byte, len(raw))
+	if _, err := img.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Apart from the tables, the disk reads back as it was written.
+	if !bytes.Equal(got[34*512:len(got)-33*512], raw[34*512:len(raw)-33*512]) {
+		t.Error("the partitions' contents changed")
+	}
+}
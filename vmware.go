@@ -0,0 +1,9 @@
+package gpt
+
+// VMware ESXi partition types.
+var (
+	VMwareVMFS     = registerType("AA31E02A-400F-11DB-9590-000C2911D1B8", "VMware VMFS", 0xFB00, OSVMware, CategoryData)
+	VMwareReserved = registerType("9198EFFC-31C0-11DB-8F78-000C2911D1B8", "VMware reserved", 0xFB01, OSVMware, CategoryReserved)
+	VMwareKCore    = registerType("9D275380-40AD-11DB-BF97-000C2911D1B8", "VMware kcore crash protection", 0xFC00, OSVMware, CategoryReserved)
+	VMwareVSAN     = registerType("381CFCCC-7288-11E0-92EE-000C2911D0B2", "VMware Virtual SAN", 0, OSVMware, CategoryData)
+)
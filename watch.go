@@ -0,0 +1,102 @@
+package gpt
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// How often Watch rereads the partition table when it can't be notified of
+// writes to the device.
+const watchPollInterval = 2 * time.Second
+
+// A TableEvent is sent by Watch when the partition table on the device
+// changes.
+type TableEvent struct {
+	// The new partition table, or nil if it could not be read.
+	Table *Table
+
+	// The error reading the new partition table, such as when the GPT
+	// was removed from the device.
+	Err error
+}
+
+// The parts of a table which change whenever it's modified. The CRCs in the
+// headers cover the partition entries.
+type tableState struct {
+	primary, backup GPTHeader
+	err             string
+}
+
+// Watches the device (or image file) dev for changes to its partition table,
+// sending a TableEvent on the returned channel for each change until ctx is
+// cancelled, after which the channel is closed.
+//
+// On Linux the device is watched with inotify, which notices when a program
+// which wrote to the device closes it. Otherwise, or if inotify is not
+// available, the table is reread periodically.
+func Watch(ctx context.Context, dev string) (<-chan TableEvent, error) {
+	_, prev, err := readTableState(dev)
+	if err != nil {
+		return nil, err
+	}
+	notify := watchNotify(ctx, dev)
+	events := make(chan TableEvent)
+	go func() {
+		defer close(events)
+		var poll <-chan time.Time
+		if notify == nil {
+			t := time.NewTicker(watchPollInterval)
+			defer t.Stop()
+			poll = t.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-notify:
+				if !ok {
+					// inotify failed, fall back to polling.
+					notify = nil
+					t := time.NewTicker(watchPollInterval)
+					defer t.Stop()
+					poll = t.C
+					continue
+				}
+			case <-poll:
+			}
+			ev, state, err := readTableState(dev)
+			if err != nil {
+				// The device itself couldn't be opened, which may
+				// be temporary (ie. while it's being replaced.)
+				continue
+			}
+			if state == prev {
+				continue
+			}
+			prev = state
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Reads the partition table of dev, returning it as an event along with its
+// state. An error is only returned if dev can't be opened, errors reading
+// the table are part of the event and state.
+func readTableState(dev string) (TableEvent, tableState, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return TableEvent{}, tableState{}, err
+	}
+	defer f.Close()
+	t, err := ReadTable(f)
+	if err != nil {
+		return TableEvent{Err: err}, tableState{err: err.Error()}, nil
+	}
+	return TableEvent{Table: t}, tableState{primary: t.Primary, backup: t.Backup}, nil
+}
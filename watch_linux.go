@@ -0,0 +1,54 @@
+package gpt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Returns a channel which receives a value whenever dev may have been
+// modified, or nil if dev can't be watched with inotify. The channel is
+// closed if inotify fails.
+func watchNotify(ctx context.Context, dev string) <-chan struct{} {
+	path, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return nil
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil
+	}
+	// udev also relies on IN_CLOSE_WRITE to notice when a partitioning
+	// tool is done with a block device. Image files are also modified or
+	// replaced in place.
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB)
+	if _, err := syscall.InotifyAddWatch(fd, path, mask); err != nil {
+		syscall.Close(fd)
+		return nil
+	}
+	// The file is non-blocking, so closing it interrupts a pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	notify := make(chan struct{}, 1)
+	go func() {
+		defer close(notify)
+		buf := make([]byte, 4096)
+		for {
+			if _, err := f.Read(buf); err != nil {
+				return
+			}
+			// Coalesce events which arrive before the table is
+			// reread.
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return notify
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package gpt
+
+import (
+	"context"
+)
+
+// Returns nil, since devices can only be watched by polling on this operating
+// system.
+func watchNotify(ctx context.Context, dev string) <-chan struct{} {
+	return nil
+}
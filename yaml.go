@@ -0,0 +1,137 @@
+package gpt
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Parses a layout from a subset of YAML, consisting of a "partitions" list
+// whose items each have some of the keys type, name, uuid, size, min-size,
+// max-size and attributes. For example:
+//
+//	partitions:
+//	  - type: esp
+//	    size: 512M
+//	  - type: linux
+//	    name: root
+//	    min-size: 10G
+//
+// The type may be anything accepted by ParseType. Sizes are in bytes, with an
+// optional base 1024 suffix (K, M, G, T, P or E), and size sets both the
+// minimum and maximum size. The attributes are a number, such as 0x1. Values
+// may be quoted with single or double quotes, and comments start with #.
+func ParseLayout(r io.Reader) (Layout, error) {
+	var l Layout
+	var p *PartitionSpec
+	hasType := false
+	inList := false
+	check := func() error {
+		if p != nil && !hasType {
+			return errorf(CodeInvalidArgument, "Partition %d has no type", len(l.Partitions))
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := yamlStripComment(scanner.Text())
+		s := strings.TrimSpace(text)
+		if s == "" || s == "---" {
+			continue
+		}
+		if text[0] != ' ' && text[0] != '-' {
+			if s != "partitions:" {
+				return l, errorf(CodeInvalidArgument, "Line %d: unknown key \"%v\"", line, s)
+			}
+			inList = true
+			continue
+		}
+		if !inList {
+			return l, errorf(CodeInvalidArgument, "Line %d: expected \"partitions:\"", line)
+		}
+		if item, ok := strings.CutPrefix(s, "-"); ok {
+			if err := check(); err != nil {
+				return l, err
+			}
+			l.Partitions = append(l.Partitions, PartitionSpec{})
+			p = &l.Partitions[len(l.Partitions)-1]
+			hasType = false
+			s = strings.TrimSpace(item)
+			if s == "" {
+				continue
+			}
+		}
+		if p == nil {
+			return l, errorf(CodeInvalidArgument, "Line %d: expected a list item", line)
+		}
+
+		key, value, ok := strings.Cut(s, ":")
+		if !ok {
+			return l, errorf(CodeInvalidArgument, "Line %d: invalid setting \"%v\"", line, s)
+		}
+		value, err := yamlUnquote(strings.TrimSpace(value))
+		if err != nil {
+			return l, wrapf(CodeInvalidArgument, err, "Line %d: %w", line, err)
+		}
+		switch strings.TrimSpace(key) {
+		case "type":
+			p.Type, err = ParseType(value)
+			hasType = true
+		case "name":
+			p.Name = value
+		case "uuid":
+			p.UUID, err = ParseGUID(value)
+		case "size":
+			p.MinSize, err = parseRepartSize(value)
+			p.MaxSize = p.MinSize
+		case "min-size":
+			p.MinSize, err = parseRepartSize(value)
+		case "max-size":
+			p.MaxSize, err = parseRepartSize(value)
+		case "attributes":
+			var a uint64
+			a, err = strconv.ParseUint(value, 0, 64)
+			p.Attributes = GPTPartitionAttribute(a)
+		default:
+			err = errorf(CodeInvalidArgument, "Unknown key \"%v\"", strings.TrimSpace(key))
+		}
+		if err != nil {
+			return l, wrapf(CodeInvalidArgument, err, "Line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return l, err
+	}
+	return l, check()
+}
+
+// Removes a # comment from a line of YAML, unless it's quoted.
+func yamlStripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// Removes the quotes from a YAML scalar, if it's quoted.
+func yamlUnquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}